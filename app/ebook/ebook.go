@@ -0,0 +1,224 @@
+// Package ebook recognises EPUB and PDF files and reads the metadata EPUBs
+// describe themselves with.
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	FormatEPUB = "epub"
+	FormatPDF  = "pdf"
+)
+
+// ContentTypes maps formats to their media types.
+var ContentTypes = map[string]string{
+	FormatEPUB: "application/epub+zip",
+	FormatPDF:  "application/pdf",
+}
+
+var (
+	ErrUnsupportedFormat = errors.New("file is neither an EPUB nor a PDF")
+	ErrMalformed         = errors.New("malformed e-book")
+)
+
+// maxMetadataSize bounds the container and package documents read out of
+// an EPUB, so a crafted archive can't inflate them without limit.
+const maxMetadataSize = 1 << 20
+
+// Metadata is what an EPUB's package document says about the book. Cover
+// is the cover image file, if there is one.
+type Metadata struct {
+	Title    string
+	Author   string
+	Language string
+	Cover    *Cover
+}
+
+type Cover struct {
+	Name string
+	Data []byte
+}
+
+// Detect checks a file is a well formed EPUB or PDF and returns its format.
+func Detect(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		if _, err := openEPUB(data); err != nil {
+			return "", err
+		}
+		return FormatEPUB, nil
+	case isPDF(data):
+		return FormatPDF, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// isPDF looks for the header and end of file markers. Readers tolerate
+// some junk around them, so they needn't be at the very start and end.
+func isPDF(data []byte) bool {
+	const window = 1024
+	head, tail := data, data
+	if len(head) > window {
+		head = head[:window]
+	}
+	if len(tail) > window {
+		tail = tail[len(tail)-window:]
+	}
+	return bytes.Contains(head, []byte("%PDF-")) && bytes.Contains(tail, []byte("%%EOF"))
+}
+
+type epub struct {
+	archive *zip.Reader
+	// opf is the path of the package document within the archive.
+	opf string
+}
+
+// openEPUB checks the archive declares itself an EPUB and finds its
+// package document.
+func openEPUB(data []byte) (*epub, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	book := &epub{archive: archive}
+
+	mimetype, err := book.read("mimetype", maxMetadataSize)
+	if err != nil || strings.TrimSpace(string(mimetype)) != ContentTypes[FormatEPUB] {
+		return nil, ErrUnsupportedFormat
+	}
+
+	container, err := book.read("META-INF/container.xml", maxMetadataSize)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var document struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &document); err != nil {
+		return nil, ErrMalformed
+	}
+	for _, rootfile := range document.Rootfiles {
+		if rootfile.MediaType == "application/oebps-package+xml" {
+			book.opf = rootfile.FullPath
+			break
+		}
+	}
+	if book.opf == "" {
+		return nil, ErrMalformed
+	}
+	return book, nil
+}
+
+// read returns a file of the archive, failing if it is larger than limit.
+func (b *epub) read(name string, limit int64) ([]byte, error) {
+	file, err := b.archive.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrMalformed
+	}
+	return data, nil
+}
+
+// packageDocument is the part of an OPF file ReadMetadata needs. EPUB 3
+// marks the cover in the manifest; EPUB 2 points at it from a meta
+// element.
+type packageDocument struct {
+	Titles    []string `xml:"metadata>title"`
+	Creators  []string `xml:"metadata>creator"`
+	Languages []string `xml:"metadata>language"`
+	Metas     []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+// ReadMetadata reads the title, first author, language and cover of an
+// EPUB. Covers larger than maxCoverSize are left out.
+func ReadMetadata(data []byte, maxCoverSize int64) (*Metadata, error) {
+	book, err := openEPUB(data)
+	if err != nil {
+		return nil, err
+	}
+	opf, err := book.read(book.opf, maxMetadataSize)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var document packageDocument
+	if err := xml.Unmarshal(opf, &document); err != nil {
+		return nil, ErrMalformed
+	}
+
+	metadata := &Metadata{
+		Title:    first(document.Titles),
+		Author:   first(document.Creators),
+		Language: first(document.Languages),
+	}
+
+	if href := document.coverHref(); href != "" {
+		// Hrefs are URLs relative to the package document.
+		name, err := url.PathUnescape(href)
+		if err == nil {
+			name = path.Join(path.Dir(book.opf), name)
+			if data, err := book.read(name, maxCoverSize); err == nil {
+				metadata.Cover = &Cover{Name: path.Base(name), Data: data}
+			}
+		}
+	}
+	return metadata, nil
+}
+
+func (d *packageDocument) coverHref() string {
+	for _, item := range d.Items {
+		for _, property := range strings.Fields(item.Properties) {
+			if property == "cover-image" {
+				return item.Href
+			}
+		}
+	}
+	for _, meta := range d.Metas {
+		if meta.Name != "cover" {
+			continue
+		}
+		for _, item := range d.Items {
+			if item.ID == meta.Content && strings.HasPrefix(item.MediaType, "image/") {
+				return item.Href
+			}
+		}
+	}
+	return ""
+}
+
+func first(values []string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,293 @@
+package imaging
+
+import "sort"
+
+const (
+	// maxCodeLength is the longest prefix code VP8L allows, and
+	// maxCodeLengthCodeLength that of the code coding the code lengths.
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+
+	codeLengthCodes = 19
+	// Code length symbols 16 to 18 repeat the previous length or zero.
+	repeatPrevious  = 16
+	repeatZeros     = 17
+	repeatManyZeros = 18
+)
+
+// codeLengthCodeOrder is the order code length code lengths are written
+// in, so the ones rarely used can be left off the end.
+var codeLengthCodeOrder = [codeLengthCodes]int{
+	17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+// prefixCode maps symbols to the bit-reversed codes written for them,
+// since the decoder reads codes from their first bit on.
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (c *prefixCode) write(bits *bitWriter, symbol int) {
+	bits.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writePrefixCode builds a prefix code from a histogram of symbols and
+// writes its description, returning the code to write symbols with.
+func writePrefixCode(bits *bitWriter, histogram []uint32) *prefixCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		// The code is never read from, but still has to be valid.
+		used = []int{0}
+	}
+
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		return writeSimpleCode(bits, len(histogram), used)
+	}
+
+	lengths := huffmanLengths(histogram, maxCodeLength)
+	bits.writeBool(false)
+	writeCodeLengths(bits, lengths)
+	return newPrefixCode(lengths)
+}
+
+// writeSimpleCode writes a code of one or two symbols below 256, which
+// takes a handful of bits. A single symbol is coded with no bits at all.
+func writeSimpleCode(bits *bitWriter, alphabetSize int, symbols []int) *prefixCode {
+	bits.writeBool(true)
+	bits.write(uint32(len(symbols)-1), 1)
+	if symbols[0] < 2 {
+		bits.writeBool(false)
+		bits.write(uint32(symbols[0]), 1)
+	} else {
+		bits.writeBool(true)
+		bits.write(uint32(symbols[0]), 8)
+	}
+
+	code := &prefixCode{
+		lengths: make([]uint8, alphabetSize),
+		codes:   make([]uint32, alphabetSize),
+	}
+	if len(symbols) == 2 {
+		bits.write(uint32(symbols[1]), 8)
+		code.lengths[symbols[0]], code.codes[symbols[0]] = 1, 0
+		code.lengths[symbols[1]], code.codes[symbols[1]] = 1, 1
+	}
+	return code
+}
+
+// writeCodeLengths writes the code lengths of a normal code, themselves
+// prefix coded with runs of repeated lengths collapsed.
+func writeCodeLengths(bits *bitWriter, lengths []uint8) {
+	type codeLengthToken struct {
+		symbol int
+		extra  uint32
+	}
+	var tokens []codeLengthToken
+	for i := 0; i < len(lengths); {
+		length := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == length {
+			run++
+		}
+		i += run
+
+		if length == 0 {
+			for run >= 11 {
+				n := run
+				if n > 138 {
+					n = 138
+				}
+				tokens = append(tokens, codeLengthToken{repeatManyZeros, uint32(n - 11)})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, codeLengthToken{repeatZeros, uint32(run - 3)})
+				run = 0
+			}
+		} else {
+			tokens = append(tokens, codeLengthToken{symbol: int(length)})
+			run--
+			for run >= 3 {
+				n := run
+				if n > 6 {
+					n = 6
+				}
+				tokens = append(tokens, codeLengthToken{repeatPrevious, uint32(n - 3)})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, codeLengthToken{symbol: int(length)})
+		}
+	}
+
+	histogram := make([]uint32, codeLengthCodes)
+	for _, t := range tokens {
+		histogram[t.symbol]++
+	}
+	codeLengthLengths := huffmanLengths(histogram, maxCodeLengthCodeLength)
+
+	count := 4
+	for i := range codeLengthCodeOrder {
+		if codeLengthLengths[codeLengthCodeOrder[i]] != 0 && i+1 > count {
+			count = i + 1
+		}
+	}
+	bits.write(uint32(count-4), 4)
+	for i := 0; i < count; i++ {
+		bits.write(uint32(codeLengthLengths[codeLengthCodeOrder[i]]), 3)
+	}
+	// The lengths of the whole alphabet follow.
+	bits.writeBool(false)
+
+	codeLengthCode := newPrefixCode(codeLengthLengths)
+	for _, t := range tokens {
+		codeLengthCode.write(bits, t.symbol)
+		switch t.symbol {
+		case repeatPrevious:
+			bits.write(t.extra, 2)
+		case repeatZeros:
+			bits.write(t.extra, 3)
+		case repeatManyZeros:
+			bits.write(t.extra, 7)
+		}
+	}
+}
+
+// newPrefixCode assigns the canonical codes for the given lengths. A code
+// with a single symbol is read with no bits, so it is written with none.
+func newPrefixCode(lengths []uint8) *prefixCode {
+	code := &prefixCode{
+		lengths: make([]uint8, len(lengths)),
+		codes:   make([]uint32, len(lengths)),
+	}
+
+	used := 0
+	var counts [maxCodeLength + 1]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			used++
+			counts[length]++
+		}
+	}
+	if used < 2 {
+		return code
+	}
+
+	var next [maxCodeLength + 1]uint32
+	for length, current := 1, uint32(0); length <= maxCodeLength; length++ {
+		current = (current + counts[length-1]) << 1
+		next[length] = current
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		code.lengths[symbol] = length
+		code.codes[symbol] = reverseBits(next[length], length)
+		next[length]++
+	}
+	return code
+}
+
+func reverseBits(value uint32, n uint8) uint32 {
+	reversed := uint32(0)
+	for i := uint8(0); i < n; i++ {
+		reversed = reversed<<1 | value&1
+		value >>= 1
+	}
+	return reversed
+}
+
+// huffmanLengths returns Huffman code lengths for a histogram, no longer
+// than limit. When the optimal code is too deep the counts are flattened
+// until it fits, which costs little since it only happens for very skewed
+// histograms. A histogram of a single symbol gets a length of one.
+func huffmanLengths(histogram []uint32, limit uint8) []uint8 {
+	for shift := uint(0); ; shift++ {
+		lengths := huffmanLengthsOnce(histogram, shift)
+		fits := true
+		for _, length := range lengths {
+			if length > limit {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return lengths
+		}
+	}
+}
+
+func huffmanLengthsOnce(histogram []uint32, shift uint) []uint8 {
+	type node struct {
+		weight uint64
+		// symbol is the symbol of a leaf, or -1 for an internal node.
+		symbol      int
+		left, right int
+	}
+
+	var nodes []node
+	for symbol, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		weight := uint64(count >> shift)
+		if weight == 0 {
+			weight = 1
+		}
+		nodes = append(nodes, node{weight: weight, symbol: symbol})
+	}
+
+	lengths := make([]uint8, len(histogram))
+	switch len(nodes) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	// Merge the two lightest nodes until one is left. Leaves are taken in
+	// order of weight and merged nodes are created in order of weight,
+	// so the lightest node is always at the front of one of the queues.
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+	leaves := len(nodes)
+	nextLeaf, nextMerged := 0, leaves
+	lightest := func() int {
+		if nextLeaf < leaves && (nextMerged >= len(nodes) || nodes[nextLeaf].weight <= nodes[nextMerged].weight) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextMerged++
+		return nextMerged - 1
+	}
+	for merges := 0; merges < leaves-1; merges++ {
+		left := lightest()
+		right := lightest()
+		nodes = append(nodes, node{
+			weight: nodes[left].weight + nodes[right].weight,
+			symbol: -1,
+			left:   left,
+			right:  right,
+		})
+	}
+
+	// Merged nodes come after their children, so walking them backwards
+	// from the root visits each parent before its children.
+	depths := make([]uint8, len(nodes))
+	for i := len(nodes) - 1; i >= leaves; i-- {
+		depths[nodes[i].left] = depths[i] + 1
+		depths[nodes[i].right] = depths[i] + 1
+	}
+	for i := 0; i < leaves; i++ {
+		lengths[nodes[i].symbol] = depths[i]
+	}
+	return lengths
+}
@@ -0,0 +1,122 @@
+// Package imaging resizes, orients and re-encodes uploaded images. It is
+// pure Go, so it needs no native image libraries at build or run time.
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// ErrMalformed is returned for files whose structure can't be parsed.
+var ErrMalformed = errors.New("imaging: malformed image")
+
+// ContentTypes are the content types of the formats Encode writes.
+var ContentTypes = map[string]string{
+	FormatJPEG: "image/jpeg",
+	FormatPNG:  "image/png",
+	FormatWebP: "image/webp",
+}
+
+// Encode writes img in the given format. Nothing but the pixels is
+// written, so metadata of the source such as EXIF never carries over.
+// WebP images are lossless; quality only applies to JPEG.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		return encoder.Encode(w, img)
+	case FormatWebP:
+		return EncodeWebP(w, img)
+	default:
+		return fmt.Errorf("imaging: unknown format %q", format)
+	}
+}
+
+// Fit scales img down so neither side is longer than maxSize, keeping its
+// aspect ratio. Smaller images are returned as they are.
+func Fit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxSize <= 0 || (width <= maxSize && height <= maxSize) {
+		return img
+	}
+
+	if width >= height {
+		height = scaleSide(height, maxSize, width)
+		width = maxSize
+	} else {
+		width = scaleSide(width, maxSize, height)
+		height = maxSize
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// scaleSide scales side by maxSize/longest, rounding to the nearest pixel
+// but never below one.
+func scaleSide(side, maxSize, longest int) int {
+	scaled := (side*maxSize + longest/2) / longest
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// Orient turns an image decoded from a file with the given EXIF
+// orientation (1 to 8) upright. Go's decoders ignore the orientation tag,
+// and it is lost with the rest of the metadata once the image is
+// re-encoded, so it has to be applied to the pixels.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored.
+				sx, sy = width-1-x, y
+			case 3: // Rotated 180°.
+				sx, sy = width-1-x, height-1-y
+			case 4: // Mirrored vertically.
+				sx, sy = x, height-1-y
+			case 5: // Transposed.
+				sx, sy = y, x
+			case 6: // Needs a 90° clockwise turn.
+				sx, sy = y, height-1-x
+			case 7: // Transversed.
+				sx, sy = width-1-y, height-1-x
+			case 8: // Needs a 90° counter-clockwise turn.
+				sx, sy = width-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
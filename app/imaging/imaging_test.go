@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// rotateClockwise turns img a quarter turn clockwise.
+func rotateClockwise(img *image.NRGBA) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, height, width))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Set(height-1-y, x, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func flipHorizontal(img *image.NRGBA) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Set(width-1-x, y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func flipVertical(img *image.NRGBA) *image.NRGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Set(x, height-1-y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func TestOrient(t *testing.T) {
+	// Every pixel of a 3x2 image is distinct, so any misplaced pixel shows.
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			src.Set(x, y, color.NRGBA{uint8(x * 80), uint8(y * 120), uint8(10 + x + 3*y), 255})
+		}
+	}
+
+	// How each EXIF orientation is made upright, built from quarter turns
+	// and flips.
+	want := map[int]*image.NRGBA{
+		1: src,
+		2: flipHorizontal(src),
+		3: rotateClockwise(rotateClockwise(src)),
+		4: flipVertical(src),
+		5: flipHorizontal(rotateClockwise(src)),
+		6: rotateClockwise(src),
+		7: flipVertical(rotateClockwise(src)),
+		8: rotateClockwise(rotateClockwise(rotateClockwise(src))),
+		// Invalid orientations leave the image alone.
+		0: src,
+		9: src,
+	}
+	for orientation, expected := range want {
+		got := toNRGBA(Orient(src, orientation))
+		if got.Bounds() != expected.Bounds() {
+			t.Errorf("orientation %d: bounds %v, want %v", orientation, got.Bounds(), expected.Bounds())
+			continue
+		}
+		for y := 0; y < expected.Bounds().Dy(); y++ {
+			for x := 0; x < expected.Bounds().Dx(); x++ {
+				if got.NRGBAAt(x, y) != expected.NRGBAAt(x, y) {
+					t.Errorf("orientation %d: pixel (%d, %d) = %v, want %v", orientation, x, y, got.NRGBAAt(x, y), expected.NRGBAAt(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestOrientKeepsSourceBoundsOrigin(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	src.Set(1, 1, color.NRGBA{255, 0, 0, 255})
+	sub := src.SubImage(image.Rect(1, 1, 3, 4)).(*image.NRGBA)
+
+	got := toNRGBA(Orient(sub, 6))
+	if got.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("bounds %v, want 3x2", got.Bounds())
+	}
+	// The top left pixel of the sub-image ends up top right.
+	if got.NRGBAAt(2, 0) != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("pixel (2, 0) = %v, want red", got.NRGBAAt(2, 0))
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width, height, maxSize int
+		want                   image.Point
+	}{
+		{100, 50, 200, image.Pt(100, 50)},
+		{100, 50, 0, image.Pt(100, 50)},
+		{400, 200, 100, image.Pt(100, 50)},
+		{200, 400, 100, image.Pt(50, 100)},
+		{1000, 1, 100, image.Pt(100, 1)},
+	}
+	for _, tt := range tests {
+		got := Fit(image.NewNRGBA(image.Rect(0, 0, tt.width, tt.height)), tt.maxSize).Bounds().Size()
+		if got != tt.want {
+			t.Errorf("Fit(%dx%d, %d) = %v, want %v", tt.width, tt.height, tt.maxSize, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// orientationTag is the EXIF tag holding the orientation.
+const orientationTag = 0x0112
+
+// jpegMetadataMarkers are the JPEG segments dropped by StripMetadata:
+// APP1 (EXIF, GPS and XMP), APP13 (IPTC) and comments. Segments the image
+// needs to render the same, such as ICC profiles in APP2, are kept.
+var jpegMetadataMarkers = map[byte]bool{
+	0xe1: true,
+	0xed: true,
+	0xfe: true,
+}
+
+// pngMetadataChunks are the PNG chunks dropped by StripMetadata.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// webPMetadataChunks are the WebP chunks dropped by StripMetadata.
+var webPMetadataChunks = map[string]bool{
+	"EXIF": true,
+	"XMP ": true,
+}
+
+// webPMetadataFlags are the VP8X header flags announcing EXIF and XMP
+// chunks.
+const webPMetadataFlags = 0x08 | 0x04
+
+// StripMetadata removes EXIF (including GPS positions), XMP, IPTC and text
+// metadata from an encoded image without decoding it, so the pixels are
+// left untouched. GIFs, which carry no EXIF, are returned as they are.
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+// Orientation reads the EXIF orientation of a JPEG, from 1 (upright) to 8.
+// Anything without a readable orientation counts as upright.
+func Orientation(data []byte) int {
+	segments, _, err := jpegSegments(data)
+	if err != nil {
+		return 1
+	}
+	for _, segment := range segments {
+		payload := data[segment.start+4 : segment.end]
+		if segment.marker == 0xe1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return exifOrientation(payload[6:])
+		}
+	}
+	return 1
+}
+
+// exifOrientation looks the orientation up in the first IFD of the TIFF
+// structure EXIF data is stored in.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int64(order.Uint32(tiff[4:8]))
+	if offset+2 > int64(len(tiff)) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := int(offset) + 2 + 12*i
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+	return 1
+}
+
+type jpegSegment struct {
+	marker     byte
+	start, end int
+}
+
+// jpegSegments lists the marker segments of a JPEG up to its image data,
+// and returns the offset the image data starts at.
+func jpegSegments(data []byte) ([]jpegSegment, int, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, 0, ErrMalformed
+	}
+
+	var segments []jpegSegment
+	for i := 2; ; {
+		if i+2 > len(data) || data[i] != 0xff {
+			return nil, 0, ErrMalformed
+		}
+		marker := data[i+1]
+		switch marker {
+		case 0xff:
+			// Fill byte before a marker.
+			i++
+			continue
+		case 0xda:
+			// Start of scan: the rest is image data.
+			return segments, i, nil
+		}
+
+		if i+4 > len(data) {
+			return nil, 0, ErrMalformed
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, ErrMalformed
+		}
+		segments = append(segments, jpegSegment{marker: marker, start: i, end: end})
+		i = end
+	}
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	segments, scan, err := jpegSegments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	stripped := make([]byte, 0, len(data))
+	stripped = append(stripped, data[:2]...)
+	for _, segment := range segments {
+		if !jpegMetadataMarkers[segment.marker] {
+			stripped = append(stripped, data[segment.start:segment.end]...)
+		}
+	}
+	return append(stripped, data[scan:]...), nil
+}
+
+// stripPNG also drops anything after the IEND chunk.
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, ErrMalformed
+	}
+
+	stripped := make([]byte, 0, len(data))
+	stripped = append(stripped, signature...)
+	for i := len(signature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, ErrMalformed
+		}
+		end := int64(i) + 12 + int64(binary.BigEndian.Uint32(data[i:]))
+		if end > int64(len(data)) {
+			return nil, ErrMalformed
+		}
+		chunkType := string(data[i+4 : i+8])
+		if !pngMetadataChunks[chunkType] {
+			stripped = append(stripped, data[i:end]...)
+		}
+		if chunkType == "IEND" {
+			break
+		}
+		i = int(end)
+	}
+	return stripped, nil
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+	riffEnd := int64(8) + int64(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > int64(len(data)) {
+		riffEnd = int64(len(data))
+	}
+
+	stripped := make([]byte, 0, len(data))
+	stripped = append(stripped, data[:12]...)
+	vp8x := -1
+	for i := int64(12); i+8 <= riffEnd; {
+		fourCC := string(data[i : i+4])
+		size := int64(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+size > riffEnd {
+			return nil, ErrMalformed
+		}
+		// Chunks are padded to an even size.
+		end := i + 8 + size + size&1
+		if end > riffEnd {
+			end = riffEnd
+		}
+
+		if !webPMetadataChunks[fourCC] {
+			if fourCC == "VP8X" && size > 0 {
+				vp8x = len(stripped) + 8
+			}
+			stripped = append(stripped, data[i:end]...)
+		}
+		i = end
+	}
+
+	if vp8x >= 0 {
+		stripped[vp8x] &^= webPMetadataFlags
+	}
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
@@ -0,0 +1,97 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// exifSegment builds an APP1 segment whose first IFD holds the given
+// orientation after a dummy tag, in the given byte order.
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+2*12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 2)
+	// ImageWidth, SHORT, 1 value.
+	order.PutUint16(tiff[10:], 0x0100)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], 8)
+	// Orientation, SHORT, 1 value.
+	order.PutUint16(tiff[22:], orientationTag)
+	order.PutUint16(tiff[24:], 3)
+	order.PutUint32(tiff[26:], 1)
+	order.PutUint16(tiff[30:], orientation)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// jpegWith inserts segments right after the start of image marker of a
+// small JPEG.
+func jpegWith(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	result := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		result = append(result, segment...)
+	}
+	return append(result, data[2:]...)
+}
+
+func TestOrientation(t *testing.T) {
+	type test struct {
+		name string
+		data []byte
+		want int
+	}
+	comment := []byte{0xff, 0xfe, 0, 6, 'h', 'e', 'l', 'o'}
+	tests := []test{
+		{"no EXIF", jpegWith(t), 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+		{"out of range", jpegWith(t, exifSegment(binary.LittleEndian, 9)), 1},
+		{"zero", jpegWith(t, exifSegment(binary.BigEndian, 0)), 1},
+		{"after a comment", jpegWith(t, comment, exifSegment(binary.BigEndian, 6)), 6},
+		{"truncated EXIF", jpegWith(t, exifSegment(binary.LittleEndian, 6)[:20]), 1},
+	}
+	for orientation := 1; orientation <= 8; orientation++ {
+		tests = append(tests,
+			test{"little endian", jpegWith(t, exifSegment(binary.LittleEndian, uint16(orientation))), orientation},
+			test{"big endian", jpegWith(t, exifSegment(binary.BigEndian, uint16(orientation))), orientation},
+		)
+	}
+	for _, tt := range tests {
+		if got := Orientation(tt.data); got != tt.want {
+			t.Errorf("%s: Orientation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStripMetadataDropsOrientation(t *testing.T) {
+	data := jpegWith(t, exifSegment(binary.LittleEndian, 6))
+	stripped, err := StripMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if got := Orientation(stripped); got != 1 {
+		t.Errorf("Orientation after stripping = %d, want 1", got)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped JPEG doesn't decode: %v", err)
+	}
+}
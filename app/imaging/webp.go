@@ -0,0 +1,443 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// The encoder writes the lossless WebP format (VP8L) described in RFC 9649,
+// which is far simpler to produce than lossy VP8. It uses the subtract
+// green and predictor transforms and codes repeated pixels as backward
+// references, which is enough for the small renditions it is used for.
+const (
+	vp8lSignature = 0x2f
+	// maxWebPSide is the longest side VP8L can describe.
+	maxWebPSide = 1 << 14
+
+	transformPredictor     = 0
+	transformSubtractGreen = 2
+
+	// predictorBits is the log-2 size of the tiles each predictor is
+	// chosen for.
+	predictorBits = 4
+	// predictorModes is the number of predictors VP8L defines.
+	predictorModes = 14
+
+	// minCopyLength and maxCopyLength bound the backward references.
+	minCopyLength = 3
+	maxCopyLength = 4096
+
+	// Distance codes of the pixels above and to the left, from the
+	// distance map in section 4.2.2 of the RFC.
+	distanceCodeAbove = 1
+	distanceCodeLeft  = 2
+
+	literalCodes  = 256
+	lengthCodes   = 24
+	distanceCodes = 40
+)
+
+// EncodeWebP writes img as a lossless WebP.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > maxWebPSide || height > maxWebPSide {
+		return fmt.Errorf("imaging: a %dx%d image can't be encoded as WebP", width, height)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	pix := nrgba.Pix
+	hasAlpha := false
+	for p := 3; p < len(pix); p += 4 {
+		if pix[p] != 0xff {
+			hasAlpha = true
+			break
+		}
+	}
+
+	bits := &bitWriter{}
+	bits.write(vp8lSignature, 8)
+	bits.write(uint32(width-1), 14)
+	bits.write(uint32(height-1), 14)
+	bits.writeBool(hasAlpha)
+	bits.write(0, 3)
+
+	// Transforms are listed in the order they are applied; the decoder
+	// undoes them in reverse.
+	subtractGreen(pix)
+	bits.writeBool(true)
+	bits.write(transformSubtractGreen, 2)
+
+	modes, residuals := predict(pix, width, height)
+	bits.writeBool(true)
+	bits.write(transformPredictor, 2)
+	bits.write(predictorBits-2, 3)
+	writeImage(bits, modes, tiles(width), false)
+	bits.writeBool(false)
+
+	writeImage(bits, residuals, width, true)
+	data := bits.bytes()
+
+	padding := len(data) & 1
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(data)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding != 0 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// tiles is the number of predictor tiles needed to cover size pixels.
+func tiles(size int) int {
+	return (size + 1<<predictorBits - 1) >> predictorBits
+}
+
+// subtractGreen subtracts each pixel's green from its red and blue, which
+// are usually correlated with it.
+func subtractGreen(pix []byte) {
+	for p := 0; p < len(pix); p += 4 {
+		pix[p+0] -= pix[p+1]
+		pix[p+2] -= pix[p+1]
+	}
+}
+
+// predict picks the predictor that fits each tile best and returns the
+// tile image recording the choices along with the residuals, the
+// difference between each pixel and its prediction. pix is in RGBA order,
+// as are both results.
+func predict(pix []byte, width, height int) ([]byte, []byte) {
+	tilesWide, tilesHigh := tiles(width), tiles(height)
+	modes := make([]byte, 4*tilesWide*tilesHigh)
+	for tileY := 0; tileY < tilesHigh; tileY++ {
+		for tileX := 0; tileX < tilesWide; tileX++ {
+			mode := bestPredictor(pix, width, height, tileX, tileY)
+			t := 4 * (tileY*tilesWide + tileX)
+			modes[t+1] = mode
+			modes[t+3] = 0xff
+		}
+	}
+
+	residuals := make([]byte, len(pix))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := 4 * (y*width + x)
+			var prediction [4]byte
+			switch {
+			case x == 0 && y == 0:
+				prediction = [4]byte{0, 0, 0, 0xff}
+			case y == 0:
+				prediction = predictPixel(1, pix, p, width)
+			case x == 0:
+				prediction = predictPixel(2, pix, p, width)
+			default:
+				mode := modes[4*((y>>predictorBits)*tilesWide+x>>predictorBits)+1]
+				prediction = predictPixel(mode, pix, p, width)
+			}
+			for c := 0; c < 4; c++ {
+				residuals[p+c] = pix[p+c] - prediction[c]
+			}
+		}
+	}
+	return modes, residuals
+}
+
+// bestPredictor returns the predictor leaving the smallest residuals over
+// a tile. The first row and column always use fixed predictors, so they
+// are left out.
+func bestPredictor(pix []byte, width, height, tileX, tileY int) byte {
+	startX, startY := tileX<<predictorBits, tileY<<predictorBits
+	endX, endY := startX+1<<predictorBits, startY+1<<predictorBits
+	if endX > width {
+		endX = width
+	}
+	if endY > height {
+		endY = height
+	}
+	if startX == 0 {
+		startX = 1
+	}
+	if startY == 0 {
+		startY = 1
+	}
+
+	best, bestCost := byte(0), -1
+	for mode := byte(0); mode < predictorModes; mode++ {
+		cost := 0
+		for y := startY; y < endY; y++ {
+			for x := startX; x < endX; x++ {
+				p := 4 * (y*width + x)
+				prediction := predictPixel(mode, pix, p, width)
+				for c := 0; c < 4; c++ {
+					residual := int(int8(pix[p+c] - prediction[c]))
+					if residual < 0 {
+						residual = -residual
+					}
+					cost += residual
+				}
+			}
+		}
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = mode, cost
+		}
+	}
+	return best
+}
+
+// predictPixel predicts the pixel at offset p from its decoded neighbours
+// with one of the predictors of section 4.1 of the RFC. The pixel to the
+// top right of the last column is the first pixel of the current row.
+func predictPixel(mode byte, pix []byte, p, width int) [4]byte {
+	top := p - 4*width
+	var prediction [4]byte
+	for c := 0; c < 4; c++ {
+		left := pix[p-4+c]
+		var above, aboveLeft, aboveRight byte
+		if top >= 0 {
+			above = pix[top+c]
+			aboveRight = pix[top+4+c]
+			if top >= 4 {
+				aboveLeft = pix[top-4+c]
+			}
+		}
+
+		switch mode {
+		case 0:
+			if c == 3 {
+				prediction[c] = 0xff
+			}
+		case 1:
+			prediction[c] = left
+		case 2:
+			prediction[c] = above
+		case 3:
+			prediction[c] = aboveRight
+		case 4:
+			prediction[c] = aboveLeft
+		case 5:
+			prediction[c] = average(average(left, aboveRight), above)
+		case 6:
+			prediction[c] = average(left, aboveLeft)
+		case 7:
+			prediction[c] = average(left, above)
+		case 8:
+			prediction[c] = average(aboveLeft, above)
+		case 9:
+			prediction[c] = average(above, aboveRight)
+		case 10:
+			prediction[c] = average(average(left, aboveLeft), average(above, aboveRight))
+		case 12:
+			prediction[c] = clamp(int(left) + int(above) - int(aboveLeft))
+		case 13:
+			mean := average(left, above)
+			prediction[c] = clamp(int(mean) + (int(mean)-int(aboveLeft))/2)
+		}
+	}
+
+	if mode == 11 {
+		// Select picks whichever of the left and above pixels is closer
+		// to the gradient they form with the one above left.
+		leftDistance, aboveDistance := 0, 0
+		for c := 0; c < 4; c++ {
+			aboveLeft := int(pix[top-4+c])
+			leftDistance += abs(aboveLeft - int(pix[top+c]))
+			aboveDistance += abs(aboveLeft - int(pix[p-4+c]))
+		}
+		if leftDistance < aboveDistance {
+			copy(prediction[:], pix[p-4:p])
+		} else {
+			copy(prediction[:], pix[top:top+4])
+		}
+	}
+	return prediction
+}
+
+func average(a, b byte) byte {
+	return byte((int(a) + int(b)) / 2)
+}
+
+func clamp(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// token is a literal pixel or, when length is set, a copy of length
+// pixels from the distance given by distanceCode.
+type token struct {
+	pixel        [4]byte
+	length       int
+	distanceCode int
+}
+
+// writeImage entropy codes an image of RGBA pixels. Only the main image
+// may declare meta prefix codes, which this encoder never uses.
+func writeImage(bits *bitWriter, pix []byte, width int, main bool) {
+	tokens := tokenize(pix, width)
+
+	var green [literalCodes + lengthCodes]uint32
+	var red, blue, alpha [literalCodes]uint32
+	var distance [distanceCodes]uint32
+	for _, t := range tokens {
+		if t.length > 0 {
+			lengthSymbol, _, _ := prefixEncode(t.length)
+			distanceSymbol, _, _ := prefixEncode(t.distanceCode)
+			green[literalCodes+lengthSymbol]++
+			distance[distanceSymbol]++
+			continue
+		}
+		red[t.pixel[0]]++
+		green[t.pixel[1]]++
+		blue[t.pixel[2]]++
+		alpha[t.pixel[3]]++
+	}
+
+	// No color cache.
+	bits.writeBool(false)
+	if main {
+		// No meta prefix codes.
+		bits.writeBool(false)
+	}
+	greenCode := writePrefixCode(bits, green[:])
+	redCode := writePrefixCode(bits, red[:])
+	blueCode := writePrefixCode(bits, blue[:])
+	alphaCode := writePrefixCode(bits, alpha[:])
+	distanceCode := writePrefixCode(bits, distance[:])
+
+	for _, t := range tokens {
+		if t.length > 0 {
+			symbol, extraBits, extra := prefixEncode(t.length)
+			greenCode.write(bits, literalCodes+symbol)
+			bits.write(extra, extraBits)
+			symbol, extraBits, extra = prefixEncode(t.distanceCode)
+			distanceCode.write(bits, symbol)
+			bits.write(extra, extraBits)
+			continue
+		}
+		greenCode.write(bits, int(t.pixel[1]))
+		redCode.write(bits, int(t.pixel[0]))
+		blueCode.write(bits, int(t.pixel[2]))
+		alphaCode.write(bits, int(t.pixel[3]))
+	}
+}
+
+// tokenize turns runs of pixels repeating the one to the left or the row
+// above into backward references.
+func tokenize(pix []byte, width int) []token {
+	n := len(pix) / 4
+	tokens := make([]token, 0, n)
+	for i := 0; i < n; {
+		length, distanceCode := 0, 0
+		if i >= 1 {
+			length, distanceCode = matchLength(pix, i, 1), distanceCodeLeft
+		}
+		if i >= width {
+			if above := matchLength(pix, i, width); above > length {
+				length, distanceCode = above, distanceCodeAbove
+			}
+		}
+
+		if length >= minCopyLength {
+			tokens = append(tokens, token{length: length, distanceCode: distanceCode})
+			i += length
+			continue
+		}
+		var t token
+		copy(t.pixel[:], pix[4*i:4*i+4])
+		tokens = append(tokens, t)
+		i++
+	}
+	return tokens
+}
+
+// matchLength counts the pixels from i on that equal those distance
+// pixels earlier.
+func matchLength(pix []byte, i, distance int) int {
+	n := len(pix) / 4
+	length := 0
+	for i+length < n && length < maxCopyLength {
+		p, q := 4*(i+length), 4*(i+length-distance)
+		if pix[p] != pix[q] || pix[p+1] != pix[q+1] || pix[p+2] != pix[q+2] || pix[p+3] != pix[q+3] {
+			break
+		}
+		length++
+	}
+	return length
+}
+
+// prefixEncode splits a backward reference length or distance code into
+// the symbol and the extra bits following it, as in section 5.2.2 of the
+// RFC.
+func prefixEncode(value int) (symbol int, extraBits uint, extra uint32) {
+	value--
+	if value < 4 {
+		return value, 0, 0
+	}
+	highest := uint(0)
+	for v := value; v > 1; v >>= 1 {
+		highest++
+	}
+	second := (value >> (highest - 1)) & 1
+	extraBits = highest - 1
+	return int(2*highest) + second, extraBits, uint32(value) & (1<<extraBits - 1)
+}
+
+// bitWriter packs values into bytes starting from the least significant
+// bit, as VP8L streams are read.
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.bits |= uint64(value) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *bitWriter) writeBool(b bool) {
+	if b {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+}
+
+// bytes flushes the last partial byte and returns the stream.
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nBits = 0, 0
+	}
+	return w.buf
+}
@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	return nrgba
+}
+
+func filled(width, height int, fn func(x, y int) color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, fn(x, y))
+		}
+	}
+	return img
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"single pixel", filled(1, 1, func(x, y int) color.Color { return color.NRGBA{10, 20, 30, 255} })},
+		{"flat colour", filled(40, 30, func(x, y int) color.Color { return color.NRGBA{200, 100, 50, 255} })},
+		{"gradient", filled(67, 45, func(x, y int) color.Color {
+			return color.NRGBA{uint8(x * 3), uint8(y * 5), uint8(x + y), 255}
+		})},
+		{"stripes", filled(33, 17, func(x, y int) color.Color {
+			if (x/3+y)%2 == 0 {
+				return color.NRGBA{255, 255, 255, 255}
+			}
+			return color.NRGBA{0, 0, 0, 255}
+		})},
+		{"noise", filled(31, 29, func(x, y int) color.Color {
+			return color.NRGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255}
+		})},
+		{"noise with alpha", filled(20, 21, func(x, y int) color.Color {
+			return color.NRGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256))}
+		})},
+		{"transparent border", filled(16, 16, func(x, y int) color.Color {
+			if x == 0 || y == 0 || x == 15 || y == 15 {
+				return color.NRGBA{}
+			}
+			return color.NRGBA{0, 128, 255, 255}
+		})},
+		{"tall", filled(1, 300, func(x, y int) color.Color { return color.NRGBA{uint8(y), 0, 0, 255} })},
+		{"wide", filled(300, 1, func(x, y int) color.Color { return color.NRGBA{0, uint8(x), 0, 255} })},
+		{"long repeats", filled(5000, 2, func(x, y int) color.Color { return color.NRGBA{1, 2, 3, 255} })},
+		{"gray", func() image.Image {
+			img := image.NewGray(image.Rect(0, 0, 9, 7))
+			for i := range img.Pix {
+				img.Pix[i] = uint8(i * 4)
+			}
+			return img
+		}()},
+		{"offset bounds", toNRGBA(filled(12, 12, func(x, y int) color.Color {
+			return color.NRGBA{uint8(x * 20), uint8(y * 20), 0, 255}
+		})).SubImage(image.Rect(3, 2, 10, 11))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, tt.img); err != nil {
+				t.Fatalf("EncodeWebP: %v", err)
+			}
+
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("webp.Decode: %v", err)
+			}
+			want, got := toNRGBA(tt.img), toNRGBA(decoded)
+			if want.Bounds() != got.Bounds() {
+				t.Fatalf("decoded bounds %v, want %v", got.Bounds(), want.Bounds())
+			}
+			if !bytes.Equal(want.Pix, got.Pix) {
+				for i := range want.Pix {
+					if want.Pix[i] != got.Pix[i] {
+						t.Fatalf("pixel %d channel %d = %d, want %d", i/4, i%4, got.Pix[i], want.Pix[i])
+					}
+				}
+			}
+
+			config, err := webp.DecodeConfig(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("webp.DecodeConfig: %v", err)
+			}
+			if config.Width != want.Bounds().Dx() || config.Height != want.Bounds().Dy() {
+				t.Errorf("config %dx%d, want %v", config.Width, config.Height, want.Bounds().Size())
+			}
+		})
+	}
+}
+
+func TestEncodeWebPRejectsBadSizes(t *testing.T) {
+	for _, rect := range []image.Rectangle{
+		image.Rect(0, 0, 0, 10),
+		image.Rect(0, 0, maxWebPSide+1, 1),
+	} {
+		if err := EncodeWebP(&bytes.Buffer{}, image.NewNRGBA(rect)); err == nil {
+			t.Errorf("EncodeWebP of %v succeeded", rect)
+		}
+	}
+}
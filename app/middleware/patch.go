@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/1rhino/clean_architecture/app/patch"
+	"github.com/gin-gonic/gin"
+)
+
+// BindPatch reads the body of a PATCH request. JSON bodies are returned as
+// a patch to apply to the stored record, plain application/json being
+// treated as a merge patch. Form bodies are bound into form instead, where
+// fields that are absent stay nil. It writes the error response itself
+// when the body can't be read.
+func BindPatch(c *gin.Context, form interface{}) (*patch.Patch, bool) {
+	var patchType string
+	switch c.ContentType() {
+	case gin.MIMEJSON, patch.MergePatchType:
+		patchType = patch.MergePatchType
+	case patch.JSONPatchType:
+		patchType = patch.JSONPatchType
+	case gin.MIMEMultipartPOSTForm, gin.MIMEPOSTForm:
+		if err := c.ShouldBind(form); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		return nil, true
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + patch.MergePatchType + ", " + patch.JSONPatchType + " or a form"})
+		return nil, false
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &patch.Patch{Type: patchType, Body: body}, true
+}
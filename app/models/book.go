@@ -1,22 +1,31 @@
 package models
 
 import (
+	"math"
+	"strings"
 	"time"
 
+	"github.com/1rhino/clean_architecture/app/patch"
 	"gorm.io/gorm"
 )
 
 type Book struct {
 	gorm.Model
-	Name        string       `gorm:"type:varchar(255)" json:"name"`
-	Image       string       `gorm:"type:varchar(255)" json:"image"`
-	Author      string       `gorm:"type:varchar(255)" json:"author"`
-	PublicDate  time.Time    `json:"public_date"`
-	Description string       `gorm:"type:varchar(255)" json:"description"`
-	CategoryID  uint         `json:"category_id"`
-	Category    BookCategory `json:"category"`
-	UserID      uint         `json:"user_id"`
-	User        User         `json:"user"`
+	Name            string          `gorm:"type:varchar(255)" json:"name"`
+	Image           string          `gorm:"type:varchar(255)" json:"image"`
+	ImageRenditions ImageRenditions `gorm:"type:jsonb" json:"image_renditions"`
+	Author          string          `gorm:"type:varchar(255)" json:"author"`
+	PublicDate      time.Time       `json:"public_date"`
+	Description     string          `gorm:"type:varchar(255)" json:"description"`
+	Tags            string          `gorm:"type:varchar(255)" json:"tags"`
+	CategoryID      *uint           `json:"category_id"`
+	Category        BookCategory    `json:"category"`
+	UserID          uint            `json:"user_id"`
+	User            User            `json:"user"`
+	RatingCount     int64           `gorm:"not null;default:0" json:"rating_count"`
+	RatingSum       int64           `gorm:"not null;default:0" json:"rating_sum"`
+	FavoritesCount  int64           `gorm:"not null;default:0;index" json:"favorites_count"`
+	Version         int64           `gorm:"not null;default:1" json:"version"`
 }
 
 func (Book) TableName() string {
@@ -24,48 +33,186 @@ func (Book) TableName() string {
 }
 
 type BookInput struct {
-	Name        string    `form:"name" json:"name" binding:"required"`
-	Image       string    `file:"image" json:"image"`
-	Author      string    `form:"author" json:"author"`
-	CategoryID  uint      `form:"category_id" json:"category_id"`
-	PublicDate  time.Time `form:"public_date" json:"public_date" time_format:"02-01-2006"`
-	Description string    `form:"description" json:"description"`
+	Name            string          `form:"name" json:"name" binding:"required"`
+	Image           string          `file:"image" json:"image"`
+	ImageURL        string          `form:"image_url" json:"image_url"`
+	ImageVisibility string          `form:"image_visibility" json:"image_visibility" binding:"omitempty,oneof=public signed proxy"`
+	ImageRenditions ImageRenditions `form:"-" json:"-"`
+	Author          string          `form:"author" json:"author"`
+	CategoryID      *uint           `form:"category_id" json:"category_id"`
+	PublicDate      time.Time       `form:"public_date" json:"public_date" time_format:"02-01-2006"`
+	Description     string          `form:"description" json:"description"`
+	Tags            string          `form:"tags" json:"tags"`
 }
 
+// UpdateBook is a partial update. Form fields that are absent stay nil and
+// leave the book unchanged; JSON bodies arrive as a Patch instead.
 type UpdateBook struct {
-	ID          uint      `form:"id" json:"id"`
-	Name        string    `form:"name" json:"name" binding:"required"`
-	Image       string    `file:"image" json:"image"`
-	Author      string    `form:"author" json:"author"`
-	CategoryID  uint      `form:"category_id" json:"category_id"`
-	PublicDate  time.Time `form:"public_date" json:"public_date" time_format:"02-01-2006"`
-	Description string    `form:"description" json:"description"`
+	ID              uint            `form:"-"`
+	Version         int64           `form:"-"`
+	Patch           *patch.Patch    `form:"-"`
+	Name            *string         `form:"name"`
+	Image           string          `form:"-"`
+	ImageURL        string          `form:"image_url"`
+	ImageVisibility string          `form:"image_visibility" binding:"omitempty,oneof=public signed proxy"`
+	ImageRenditions ImageRenditions `form:"-"`
+	Author          *string         `form:"author"`
+	CategoryID      *uint           `form:"category_id"`
+	PublicDate      *time.Time      `form:"public_date" time_format:"02-01-2006"`
+	Description     *string         `form:"description"`
+	Tags            *string         `form:"tags"`
+}
+
+// Apply changes the book to match the update. Patches are applied to the
+// book's snapshot, so they can address the same fields a revision records.
+func (input *UpdateBook) Apply(book *Book) error {
+	if input.Patch != nil {
+		snapshot := NewBookSnapshot(book)
+		if err := input.Patch.ApplyExcept(snapshot, ImagePatchMembers...); err != nil {
+			return err
+		}
+		snapshot.Apply(book)
+	}
+
+	if input.Name != nil {
+		book.Name = *input.Name
+	}
+	if input.Author != nil {
+		book.Author = *input.Author
+	}
+	if input.CategoryID != nil {
+		// Forms can't send null, so category_id=0 files the book under no
+		// category.
+		book.CategoryID = nil
+		if *input.CategoryID != 0 {
+			book.CategoryID = input.CategoryID
+		}
+	}
+	if input.PublicDate != nil {
+		book.PublicDate = *input.PublicDate
+	}
+	if input.Description != nil {
+		book.Description = *input.Description
+	}
+	if input.Tags != nil {
+		book.Tags = *input.Tags
+	}
+	if input.Image != "" {
+		book.Image = input.Image
+		book.ImageRenditions = input.ImageRenditions
+	}
+	book.Tags = NormalizeTags(book.Tags)
+	return nil
+}
+
+const (
+	BookSortNewest  = "newest"
+	BookSortPopular = "popular"
+)
+
+type BookQuery struct {
+	Sort string `form:"sort"`
+}
+
+// OrderClause translates the sort parameter into an ORDER BY clause. The
+// popular sort reads the stored favorites count, so it needs no per-row
+// query.
+func (q *BookQuery) OrderClause() string {
+	switch q.Sort {
+	case BookSortNewest:
+		return "created_at DESC, id DESC"
+	case BookSortPopular:
+		return "favorites_count DESC, id DESC"
+	default:
+		return "id ASC"
+	}
+}
+
+// NormalizeTags turns a comma separated tag list into the stored form:
+// trimmed, lower case and without duplicates.
+func NormalizeTags(raw string) string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return strings.Join(tags, ",")
+}
+
+func (b *Book) TagList() []string {
+	if b.Tags == "" {
+		return []string{}
+	}
+	return strings.Split(b.Tags, ",")
+}
+
+type RatingSummary struct {
+	Average float64 `json:"average"`
+	Count   int64   `json:"count"`
 }
 
 type BookResponse struct {
-	ID          uint      `json:"id,omitempty"`
-	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
-	Author      string    `json:"author"`
-	CategoryID  uint      `json:"category_id"`
-	UserID      uint      `json:"user_id"`
-	Description string    `json:"description"`
-	Image       string    `json:"image"`
-	PublicDate  time.Time `json:"public_date"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              uint              `json:"id,omitempty"`
+	Name            string            `json:"name" gorm:"type:varchar(100);not null"`
+	Author          string            `json:"author"`
+	CategoryID      *uint             `json:"category_id"`
+	UserID          uint              `json:"user_id"`
+	Description     string            `json:"description"`
+	Image           string            `json:"image"`
+	ImageRenditions ImageRenditions   `json:"image_renditions,omitempty"`
+	Tags            []string          `json:"tags"`
+	PublicDate      time.Time         `json:"public_date"`
+	Rating          RatingSummary     `json:"rating"`
+	Availability    *BookAvailability `json:"availability,omitempty"`
+	FavoritesCount  int64             `json:"favorites_count"`
+	IsFavorited     bool              `json:"is_favorited"`
+	Version         int64             `json:"version"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"`
+}
+
+func bookRatingSummary(book *Book) RatingSummary {
+	if book.RatingCount == 0 {
+		return RatingSummary{}
+	}
+	average := float64(book.RatingSum) / float64(book.RatingCount)
+	return RatingSummary{
+		Average: math.Round(average*100) / 100,
+		Count:   book.RatingCount,
+	}
 }
 
 func FilterBookRecord(books *Book) *BookResponse {
 	return &BookResponse{
-		ID:          books.ID,
-		Name:        books.Name,
-		Author:      books.Author,
-		CategoryID:  books.CategoryID,
-		UserID:      books.UserID,
-		PublicDate:  books.PublicDate,
-		Description: books.Description,
-		Image:       books.Image,
-		CreatedAt:   books.CreatedAt,
-		UpdatedAt:   books.UpdatedAt,
+		ID:              books.ID,
+		Name:            books.Name,
+		Author:          books.Author,
+		CategoryID:      books.CategoryID,
+		UserID:          books.UserID,
+		PublicDate:      books.PublicDate,
+		Description:     books.Description,
+		Image:           books.Image,
+		ImageRenditions: books.ImageRenditions,
+		Tags:            books.TagList(),
+		Rating:          bookRatingSummary(books),
+		FavoritesCount:  books.FavoritesCount,
+		Version:         books.Version,
+		CreatedAt:       books.CreatedAt,
+		UpdatedAt:       books.UpdatedAt,
+		DeletedAt:       deletedAt(books.DeletedAt),
+	}
+}
+
+// deletedAt exposes the soft delete time of records listed from the trash.
+func deletedAt(deleted gorm.DeletedAt) *time.Time {
+	if !deleted.Valid {
+		return nil
 	}
+	return &deleted.Time
 }
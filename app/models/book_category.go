@@ -1,19 +1,23 @@
 package models
 
 import (
+	"strings"
 	"time"
 
+	"github.com/1rhino/clean_architecture/app/patch"
 	"gorm.io/gorm"
 )
 
 type BookCategory struct {
 	gorm.Model
-	Name        string `gorm:"type:varchar(255)" json:"name"`
-	Image       string `gorm:"type:varchar(255)" json:"image"`
-	Description string `gorm:"type:varchar(255)" json:"description"`
-	Books       []Book `json:"books" gorm:"foreignKey:CategoryID"`
-	UserID      uint   `json:"user_id"`
-	User        User   `json:"user"`
+	Name            string          `gorm:"type:varchar(255)" json:"name"`
+	Image           string          `gorm:"type:varchar(255)" json:"image"`
+	ImageRenditions ImageRenditions `gorm:"type:jsonb" json:"image_renditions"`
+	Description     string          `gorm:"type:varchar(255)" json:"description"`
+	Books           []Book          `json:"books" gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	UserID          uint            `json:"user_id"`
+	User            User            `json:"user"`
+	Version         int64           `gorm:"not null;default:1" json:"version"`
 }
 
 func (BookCategory) TableName() string {
@@ -21,34 +25,121 @@ func (BookCategory) TableName() string {
 }
 
 type BookCategoryInput struct {
-	Name        string `form:"name" json:"name" binding:"required"`
-	Image       string `file:"image" json:"image"`
-	Description string `form:"description" json:"description"`
+	Name            string          `form:"name" json:"name" binding:"required"`
+	Image           string          `file:"image" json:"image"`
+	ImageURL        string          `form:"image_url" json:"image_url"`
+	ImageVisibility string          `form:"image_visibility" json:"image_visibility" binding:"omitempty,oneof=public signed proxy"`
+	ImageRenditions ImageRenditions `form:"-" json:"-"`
+	Description     string          `form:"description" json:"description"`
 }
 
+// UpdateBookCategory is a partial update, following the same rules as
+// UpdateBook.
 type UpdateBookCategory struct {
-	ID          uint   `form:"id" json:"id"`
-	Name        string `form:"name" json:"name" binding:"required"`
-	Image       string `file:"image" json:"image"`
-	Description string `form:"description" json:"description"`
+	ID              uint            `form:"-"`
+	Version         int64           `form:"-"`
+	Patch           *patch.Patch    `form:"-"`
+	Name            *string         `form:"name"`
+	Image           string          `form:"-"`
+	ImageURL        string          `form:"image_url"`
+	ImageVisibility string          `form:"image_visibility" binding:"omitempty,oneof=public signed proxy"`
+	ImageRenditions ImageRenditions `form:"-"`
+	Description     *string         `form:"description"`
+}
+
+func (input *UpdateBookCategory) Apply(bookCategory *BookCategory) error {
+	if input.Patch != nil {
+		snapshot := NewBookCategorySnapshot(bookCategory)
+		if err := input.Patch.ApplyExcept(snapshot, ImagePatchMembers...); err != nil {
+			return err
+		}
+		snapshot.Apply(bookCategory)
+	}
+
+	if input.Name != nil {
+		bookCategory.Name = *input.Name
+	}
+	if input.Description != nil {
+		bookCategory.Description = *input.Description
+	}
+	if input.Image != "" {
+		bookCategory.Image = input.Image
+		bookCategory.ImageRenditions = input.ImageRenditions
+	}
+	return nil
+}
+
+type MergeBookCategoryInput struct {
+	TargetID uint `form:"target_id" json:"target_id" binding:"required"`
+}
+
+const (
+	IncludeBookCount   = "book_count"
+	IncludeLatestBooks = "latest_books"
+	IncludeBooks       = "books"
+
+	DefaultLatestBooks = 5
+	MaxLatestBooks     = 50
+)
+
+// BookCategoryQuery holds the optional ?include=book_count,latest_books,books
+// expansions. Paginated books are only embedded on the detail endpoint.
+type BookCategoryQuery struct {
+	Include string `form:"include"`
+	Latest  int    `form:"latest"`
+	PaginationInput
+}
+
+func (q *BookCategoryQuery) Includes(name string) bool {
+	for _, include := range strings.Split(q.Include, ",") {
+		if strings.TrimSpace(include) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize clamps the latest books limit and the embedded books page.
+func (q *BookCategoryQuery) Normalize() {
+	if q.Latest < 1 {
+		q.Latest = DefaultLatestBooks
+	}
+	if q.Latest > MaxLatestBooks {
+		q.Latest = MaxLatestBooks
+	}
+	q.PaginationInput.Normalize()
+}
+
+type BookCategoryBooks struct {
+	Data []*BookResponse `json:"data"`
+	Meta PaginationMeta  `json:"meta"`
 }
 
 type BookCategoryResponse struct {
-	ID          uint      `json:"id,omitempty"`
-	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
-	Description string    `form:"description" json:"description"`
-	Image       string    `json:"image"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              uint               `json:"id,omitempty"`
+	Name            string             `json:"name" gorm:"type:varchar(100);not null"`
+	Description     string             `form:"description" json:"description"`
+	Image           string             `json:"image"`
+	ImageRenditions ImageRenditions    `json:"image_renditions,omitempty"`
+	BookCount       *int64             `json:"book_count,omitempty"`
+	LatestBooks     []*BookResponse    `json:"latest_books,omitempty"`
+	Books           *BookCategoryBooks `json:"books,omitempty"`
+	Version         int64              `json:"version"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	DeletedAt       *time.Time         `json:"deleted_at,omitempty"`
 }
 
 func FilterBookCategoryRecord(book_categories *BookCategory) *BookCategoryResponse {
 	return &BookCategoryResponse{
-		ID:          book_categories.ID,
-		Name:        book_categories.Name,
-		Description: book_categories.Description,
-		Image:       book_categories.Image,
-		CreatedAt:   book_categories.CreatedAt,
-		UpdatedAt:   book_categories.UpdatedAt,
+		ID:              book_categories.ID,
+		Name:            book_categories.Name,
+		Description:     book_categories.Description,
+		Image:           book_categories.Image,
+		ImageRenditions: book_categories.ImageRenditions,
+		Version:         book_categories.Version,
+		CreatedAt:       book_categories.CreatedAt,
+		UpdatedAt:       book_categories.UpdatedAt,
+		DeletedAt:       deletedAt(book_categories.DeletedAt),
 	}
 }
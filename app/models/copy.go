@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CopyStatusAvailable = "available"
+	CopyStatusOnLoan    = "on_loan"
+	CopyStatusOnHold    = "on_hold"
+	CopyStatusWithdrawn = "withdrawn"
+)
+
+// Copy is a physical copy of a book held by the library.
+type Copy struct {
+	gorm.Model
+	BookID    uint   `gorm:"index" json:"book_id"`
+	Book      Book   `json:"book"`
+	Barcode   string `gorm:"type:varchar(64);uniqueIndex:idx_copies_barcode,where:deleted_at IS NULL" json:"barcode"`
+	Condition string `gorm:"type:varchar(50)" json:"condition"`
+	Location  string `gorm:"type:varchar(255)" json:"location"`
+	Status    string `gorm:"type:varchar(20);not null;default:available;index" json:"status"`
+}
+
+func (Copy) TableName() string {
+	return "copies"
+}
+
+type CopyInput struct {
+	BookID    uint   `form:"book_id" json:"book_id" binding:"required"`
+	Barcode   string `form:"barcode" json:"barcode" binding:"required"`
+	Condition string `form:"condition" json:"condition" binding:"omitempty,oneof=new good fair poor damaged"`
+	Location  string `form:"location" json:"location"`
+}
+
+type UpdateCopy struct {
+	ID        uint   `form:"id" json:"id"`
+	Barcode   string `form:"barcode" json:"barcode"`
+	Condition string `form:"condition" json:"condition" binding:"omitempty,oneof=new good fair poor damaged"`
+	Location  string `form:"location" json:"location"`
+	Status    string `form:"status" json:"status" binding:"omitempty,oneof=available withdrawn"`
+}
+
+type CopyResponse struct {
+	ID        uint      `json:"id"`
+	BookID    uint      `json:"book_id"`
+	Barcode   string    `json:"barcode"`
+	Condition string    `json:"condition"`
+	Location  string    `json:"location"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func FilterCopyRecord(bookCopy *Copy) *CopyResponse {
+	return &CopyResponse{
+		ID:        bookCopy.ID,
+		BookID:    bookCopy.BookID,
+		Barcode:   bookCopy.Barcode,
+		Condition: bookCopy.Condition,
+		Location:  bookCopy.Location,
+		Status:    bookCopy.Status,
+		CreatedAt: bookCopy.CreatedAt,
+		UpdatedAt: bookCopy.UpdatedAt,
+	}
+}
+
+type BookAvailability struct {
+	TotalCopies     int64      `json:"total_copies"`
+	AvailableCopies int64      `json:"available_copies"`
+	NextDueAt       *time.Time `json:"next_due_at"`
+}
@@ -0,0 +1,104 @@
+package models
+
+import "time"
+
+// Ebook is a downloadable edition of a book, at most one per format. Title,
+// Author and Language are what the file says about itself, which may
+// differ from the book's.
+type Ebook struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	BookID      uint      `gorm:"uniqueIndex:idx_ebooks_book_format" json:"book_id"`
+	Format      string    `gorm:"type:varchar(10);uniqueIndex:idx_ebooks_book_format" json:"format"`
+	Key         string    `gorm:"type:varchar(255);index" json:"key"`
+	ContentType string    `gorm:"type:varchar(100)" json:"content_type"`
+	Size        int64     `json:"size"`
+	Filename    string    `gorm:"type:varchar(255)" json:"filename"`
+	Title       string    `gorm:"type:varchar(255)" json:"title"`
+	Author      string    `gorm:"type:varchar(255)" json:"author"`
+	Language    string    `gorm:"type:varchar(35)" json:"language"`
+	UserID      uint      `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Ebook) TableName() string {
+	return "ebooks"
+}
+
+// EbookUploadField is the field of a book that references its e-book in
+// the given format.
+func EbookUploadField(format string) string {
+	return "ebook_" + format
+}
+
+// EbookDownload counts how many times a user downloaded an e-book.
+type EbookDownload struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	EbookID          uint      `gorm:"uniqueIndex:idx_ebook_downloads_user" json:"ebook_id"`
+	Ebook            Ebook     `json:"ebook"`
+	UserID           uint      `gorm:"uniqueIndex:idx_ebook_downloads_user;index" json:"user_id"`
+	Count            int64     `gorm:"not null;default:0" json:"count"`
+	LastDownloadedAt time.Time `json:"last_downloaded_at"`
+}
+
+func (EbookDownload) TableName() string {
+	return "ebook_downloads"
+}
+
+type EbookQuery struct {
+	PaginationInput
+}
+
+type EbookResponse struct {
+	ID          uint      `json:"id"`
+	BookID      uint      `json:"book_id"`
+	Format      string    `json:"format"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Filename    string    `json:"filename"`
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Language    string    `json:"language"`
+	DownloadURL string    `json:"download_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func FilterEbookRecord(ebook *Ebook, downloadURL string) *EbookResponse {
+	return &EbookResponse{
+		ID:          ebook.ID,
+		BookID:      ebook.BookID,
+		Format:      ebook.Format,
+		ContentType: ebook.ContentType,
+		Size:        ebook.Size,
+		Filename:    ebook.Filename,
+		Title:       ebook.Title,
+		Author:      ebook.Author,
+		Language:    ebook.Language,
+		DownloadURL: downloadURL,
+		CreatedAt:   ebook.CreatedAt,
+		UpdatedAt:   ebook.UpdatedAt,
+	}
+}
+
+// AttachEbookResponse returns the book too, since attaching an e-book
+// fills in the author and image the book was missing from the file's
+// metadata.
+type AttachEbookResponse struct {
+	Ebook *EbookResponse `json:"ebook"`
+	Book  *BookResponse  `json:"book"`
+}
+
+type EbookDownloadResponse struct {
+	Ebook            *EbookResponse `json:"ebook"`
+	Count            int64          `json:"count"`
+	LastDownloadedAt time.Time      `json:"last_downloaded_at"`
+}
+
+func FilterEbookDownloadRecord(download *EbookDownload, downloadURL string) *EbookDownloadResponse {
+	return &EbookDownloadResponse{
+		Ebook:            FilterEbookRecord(&download.Ebook, downloadURL),
+		Count:            download.Count,
+		LastDownloadedAt: download.LastDownloadedAt,
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type Favorite struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_favorites_user_book" json:"user_id"`
+	BookID    uint      `gorm:"uniqueIndex:idx_favorites_user_book;index" json:"book_id"`
+	Book      Book      `json:"book"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Favorite) TableName() string {
+	return "favorites"
+}
+
+type FavoriteInput struct {
+	BookID uint `form:"book_id" json:"book_id" binding:"required"`
+}
+
+type FavoriteQuery struct {
+	PaginationInput
+}
+
+type FavoriteResponse struct {
+	BookID      uint          `json:"book_id"`
+	Book        *BookResponse `json:"book,omitempty"`
+	FavoritedAt time.Time     `json:"favorited_at"`
+}
+
+func FilterFavoriteRecord(favorite *Favorite) *FavoriteResponse {
+	favoriteResponse := &FavoriteResponse{
+		BookID:      favorite.BookID,
+		FavoritedAt: favorite.CreatedAt,
+	}
+	if favorite.Book.ID != 0 {
+		favoriteResponse.Book = FilterBookRecord(&favorite.Book)
+		favoriteResponse.Book.IsFavorited = true
+	}
+	return favoriteResponse
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FineStatusOpen    = "open"
+	FineStatusSettled = "settled"
+
+	FineTransactionPayment = "payment"
+	FineTransactionWaiver  = "waiver"
+)
+
+// Fine accrues on an overdue loan. All amounts are in cents. Purging a book
+// from the trash keeps its fines, without BookID.
+type Fine struct {
+	gorm.Model
+	LoanID       uint   `gorm:"uniqueIndex" json:"loan_id"`
+	Loan         Loan   `json:"loan"`
+	UserID       uint   `gorm:"index" json:"user_id"`
+	BookID       *uint  `gorm:"index" json:"book_id"`
+	AccruedCents int64  `gorm:"not null;default:0" json:"accrued_cents"`
+	PaidCents    int64  `gorm:"not null;default:0" json:"paid_cents"`
+	WaivedCents  int64  `gorm:"not null;default:0" json:"waived_cents"`
+	Status       string `gorm:"type:varchar(20);not null;index" json:"status"`
+}
+
+func (Fine) TableName() string {
+	return "fines"
+}
+
+func (f *Fine) BalanceCents() int64 {
+	return f.AccruedCents - f.PaidCents - f.WaivedCents
+}
+
+// FineTransaction records a payment or waiver made by staff against a fine.
+type FineTransaction struct {
+	gorm.Model
+	FineID       uint   `gorm:"index" json:"fine_id"`
+	Kind         string `gorm:"type:varchar(20);not null" json:"kind"`
+	AmountCents  int64  `gorm:"not null" json:"amount_cents"`
+	RecordedByID uint   `json:"recorded_by_id"`
+	Note         string `gorm:"type:varchar(255)" json:"note"`
+}
+
+func (FineTransaction) TableName() string {
+	return "fine_transactions"
+}
+
+// FineTransactionInput leaves AmountCents empty to settle the whole balance.
+type FineTransactionInput struct {
+	AmountCents int64  `form:"amount_cents" json:"amount_cents" binding:"omitempty,min=1"`
+	Note        string `form:"note" json:"note"`
+}
+
+type FineResponse struct {
+	ID           uint      `json:"id"`
+	LoanID       uint      `json:"loan_id"`
+	UserID       uint      `json:"user_id"`
+	BookID       *uint     `json:"book_id"`
+	AccruedCents int64     `json:"accrued_cents"`
+	PaidCents    int64     `json:"paid_cents"`
+	WaivedCents  int64     `json:"waived_cents"`
+	BalanceCents int64     `json:"balance_cents"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type FineSummary struct {
+	Fines        []*FineResponse `json:"fines"`
+	BalanceCents int64           `json:"balance_cents"`
+}
+
+func FilterFineRecord(fine *Fine) *FineResponse {
+	return &FineResponse{
+		ID:           fine.ID,
+		LoanID:       fine.LoanID,
+		UserID:       fine.UserID,
+		BookID:       fine.BookID,
+		AccruedCents: fine.AccruedCents,
+		PaidCents:    fine.PaidCents,
+		WaivedCents:  fine.WaivedCents,
+		BalanceCents: fine.BalanceCents(),
+		Status:       fine.Status,
+		CreatedAt:    fine.CreatedAt,
+		UpdatedAt:    fine.UpdatedAt,
+	}
+}
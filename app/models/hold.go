@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusReady     = "ready"
+	HoldStatusFulfilled = "fulfilled"
+	HoldStatusCancelled = "cancelled"
+	HoldStatusExpired   = "expired"
+)
+
+// Hold is a place in the FIFO queue for a book whose copies are all on loan.
+// Once a copy comes back it is set aside for the first waiting hold, which
+// becomes ready for pickup until ExpiresAt.
+type Hold struct {
+	gorm.Model
+	BookID    uint       `gorm:"index:idx_holds_book_status;uniqueIndex:idx_holds_active,where:status IN ('waiting'\\,'ready')" json:"book_id"`
+	Book      Book       `json:"book"`
+	UserID    uint       `gorm:"index;uniqueIndex:idx_holds_active,where:status IN ('waiting'\\,'ready')" json:"user_id"`
+	User      User       `json:"user"`
+	Status    string     `gorm:"type:varchar(20);not null;index:idx_holds_book_status" json:"status"`
+	CopyID    *uint      `gorm:"index" json:"copy_id"`
+	ReadyAt   *time.Time `json:"ready_at"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+}
+
+func (Hold) TableName() string {
+	return "holds"
+}
+
+type HoldInput struct {
+	BookID uint `form:"book_id" json:"book_id" binding:"required"`
+}
+
+type HoldResponse struct {
+	ID        uint       `json:"id"`
+	BookID    uint       `json:"book_id"`
+	UserID    uint       `json:"user_id"`
+	Status    string     `json:"status"`
+	Position  int64      `json:"position,omitempty"`
+	CopyID    *uint      `json:"copy_id"`
+	ReadyAt   *time.Time `json:"ready_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func FilterHoldRecord(hold *Hold) *HoldResponse {
+	return &HoldResponse{
+		ID:        hold.ID,
+		BookID:    hold.BookID,
+		UserID:    hold.UserID,
+		Status:    hold.Status,
+		CopyID:    hold.CopyID,
+		ReadyAt:   hold.ReadyAt,
+		ExpiresAt: hold.ExpiresAt,
+		CreatedAt: hold.CreatedAt,
+		UpdatedAt: hold.UpdatedAt,
+	}
+}
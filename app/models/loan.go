@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LoanStatusActive   = "active"
+	LoanStatusReturned = "returned"
+)
+
+// Loan lends a copy to a member. Purging a book from the trash keeps its
+// returned loans, without CopyID and BookID.
+type Loan struct {
+	gorm.Model
+	CopyID       *uint      `gorm:"index" json:"copy_id"`
+	Copy         Copy       `json:"copy"`
+	BookID       *uint      `gorm:"index" json:"book_id"`
+	UserID       uint       `gorm:"index" json:"user_id"`
+	User         User       `json:"user"`
+	Status       string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `gorm:"index" json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at"`
+	OverdueAt    *time.Time `gorm:"index" json:"overdue_at"`
+	Renewals     int        `gorm:"not null;default:0" json:"renewals"`
+}
+
+func (Loan) TableName() string {
+	return "loans"
+}
+
+// CheckoutInput names either a specific copy or a book, in which case any
+// available copy of it is lent.
+type CheckoutInput struct {
+	CopyID uint `form:"copy_id" json:"copy_id"`
+	BookID uint `form:"book_id" json:"book_id"`
+}
+
+type LoanQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=active returned"`
+}
+
+type LoanResponse struct {
+	ID           uint       `json:"id"`
+	CopyID       *uint      `json:"copy_id"`
+	BookID       *uint      `json:"book_id"`
+	UserID       uint       `json:"user_id"`
+	Status       string     `json:"status"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at"`
+	Overdue      bool       `json:"overdue"`
+	Renewals     int        `json:"renewals"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func FilterLoanRecord(loan *Loan) *LoanResponse {
+	return &LoanResponse{
+		ID:           loan.ID,
+		CopyID:       loan.CopyID,
+		BookID:       loan.BookID,
+		UserID:       loan.UserID,
+		Status:       loan.Status,
+		CheckedOutAt: loan.CheckedOutAt,
+		DueAt:        loan.DueAt,
+		ReturnedAt:   loan.ReturnedAt,
+		Overdue:      loan.OverdueAt != nil,
+		Renewals:     loan.Renewals,
+		CreatedAt:    loan.CreatedAt,
+		UpdatedAt:    loan.UpdatedAt,
+	}
+}
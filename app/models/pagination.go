@@ -0,0 +1,42 @@
+package models
+
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+type PaginationInput struct {
+	Page    int `form:"page" json:"page"`
+	PerPage int `form:"per_page" json:"per_page"`
+}
+
+// Normalize clamps the page and page size to sane values.
+func (p *PaginationInput) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PerPage < 1 {
+		p.PerPage = DefaultPerPage
+	}
+	if p.PerPage > MaxPerPage {
+		p.PerPage = MaxPerPage
+	}
+}
+
+func (p *PaginationInput) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+type PaginationMeta struct {
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+	Total   int64 `json:"total"`
+}
+
+func NewPaginationMeta(p *PaginationInput, total int64) PaginationMeta {
+	return PaginationMeta{
+		Page:    p.Page,
+		PerPage: p.PerPage,
+		Total:   total,
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReadingListPrivate  = "private"
+	ReadingListUnlisted = "unlisted"
+	ReadingListPublic   = "public"
+)
+
+type ReadingList struct {
+	gorm.Model
+	UserID      uint              `gorm:"index" json:"user_id"`
+	User        User              `json:"user"`
+	Name        string            `gorm:"type:varchar(255)" json:"name"`
+	Description string            `gorm:"type:text" json:"description"`
+	Visibility  string            `gorm:"type:varchar(20);not null;default:private;index" json:"visibility"`
+	ShareToken  string            `gorm:"type:varchar(64);uniqueIndex" json:"share_token"`
+	Items       []ReadingListItem `json:"items" gorm:"foreignKey:ReadingListID"`
+}
+
+func (ReadingList) TableName() string {
+	return "reading_lists"
+}
+
+type ReadingListItem struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	ReadingListID uint      `gorm:"uniqueIndex:idx_reading_list_items_list_book" json:"reading_list_id"`
+	BookID        uint      `gorm:"uniqueIndex:idx_reading_list_items_list_book" json:"book_id"`
+	Book          Book      `json:"book"`
+	Position      int       `gorm:"not null" json:"position"`
+	Note          string    `gorm:"type:text" json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (ReadingListItem) TableName() string {
+	return "reading_list_items"
+}
+
+type ReadingListInput struct {
+	Name        string `form:"name" json:"name" binding:"required"`
+	Description string `form:"description" json:"description"`
+	Visibility  string `form:"visibility" json:"visibility" binding:"omitempty,oneof=private unlisted public"`
+}
+
+type UpdateReadingList struct {
+	ID          uint   `form:"id" json:"id"`
+	Name        string `form:"name" json:"name" binding:"required"`
+	Description string `form:"description" json:"description"`
+	Visibility  string `form:"visibility" json:"visibility" binding:"omitempty,oneof=private unlisted public"`
+}
+
+type ReadingListItemInput struct {
+	BookID uint   `form:"book_id" json:"book_id" binding:"required"`
+	Note   string `form:"note" json:"note"`
+}
+
+type UpdateReadingListItem struct {
+	Note string `form:"note" json:"note"`
+}
+
+type ReorderReadingListInput struct {
+	ItemIDs []uint `form:"item_ids" json:"item_ids" binding:"required"`
+}
+
+type ReadingListItemResponse struct {
+	ID        uint          `json:"id"`
+	BookID    uint          `json:"book_id"`
+	Book      *BookResponse `json:"book,omitempty"`
+	Position  int           `json:"position"`
+	Note      string        `json:"note"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type ReadingListResponse struct {
+	ID          uint                       `json:"id"`
+	UserID      uint                       `json:"user_id"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Visibility  string                     `json:"visibility"`
+	ShareToken  string                     `json:"share_token,omitempty"`
+	Items       []*ReadingListItemResponse `json:"items,omitempty"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+}
+
+func FilterReadingListRecord(list *ReadingList) *ReadingListResponse {
+	response := &ReadingListResponse{
+		ID:          list.ID,
+		UserID:      list.UserID,
+		Name:        list.Name,
+		Description: list.Description,
+		Visibility:  list.Visibility,
+		ShareToken:  list.ShareToken,
+		CreatedAt:   list.CreatedAt,
+		UpdatedAt:   list.UpdatedAt,
+	}
+	for i := range list.Items {
+		response.Items = append(response.Items, FilterReadingListItemRecord(&list.Items[i]))
+	}
+	return response
+}
+
+func FilterReadingListItemRecord(item *ReadingListItem) *ReadingListItemResponse {
+	response := &ReadingListItemResponse{
+		ID:        item.ID,
+		BookID:    item.BookID,
+		Position:  item.Position,
+		Note:      item.Note,
+		CreatedAt: item.CreatedAt,
+	}
+	if item.Book.ID != 0 {
+		response.Book = FilterBookRecord(&item.Book)
+	}
+	return response
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// BookSimilarity and UserRecommendation are precomputed by the
+// recommendations refresh job and replaced wholesale on every run.
+type BookSimilarity struct {
+	BookID        uint      `gorm:"primaryKey;autoIncrement:false" json:"book_id"`
+	SimilarBookID uint      `gorm:"primaryKey;autoIncrement:false" json:"similar_book_id"`
+	SimilarBook   Book      `gorm:"foreignKey:SimilarBookID" json:"similar_book"`
+	Score         float64   `gorm:"not null" json:"score"`
+	ComputedAt    time.Time `json:"computed_at"`
+}
+
+func (BookSimilarity) TableName() string {
+	return "book_similarities"
+}
+
+type UserRecommendation struct {
+	UserID     uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	BookID     uint      `gorm:"primaryKey;autoIncrement:false" json:"book_id"`
+	Book       Book      `json:"book"`
+	Score      float64   `gorm:"not null" json:"score"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+func (UserRecommendation) TableName() string {
+	return "user_recommendations"
+}
+
+const (
+	DefaultRecommendationLimit = 10
+	MaxRecommendationLimit     = 50
+)
+
+type RecommendationQuery struct {
+	Limit int `form:"limit"`
+}
+
+func (q *RecommendationQuery) Normalize() {
+	if q.Limit < 1 {
+		q.Limit = DefaultRecommendationLimit
+	}
+	if q.Limit > MaxRecommendationLimit {
+		q.Limit = MaxRecommendationLimit
+	}
+}
+
+type RecommendedBookResponse struct {
+	Score float64       `json:"score"`
+	Book  *BookResponse `json:"book"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Review struct {
+	gorm.Model
+	BookID  uint   `gorm:"uniqueIndex:idx_reviews_book_user" json:"book_id"`
+	Book    Book   `json:"book"`
+	UserID  uint   `gorm:"uniqueIndex:idx_reviews_book_user" json:"user_id"`
+	User    User   `json:"user"`
+	Rating  int    `gorm:"not null" json:"rating"`
+	Title   string `gorm:"type:varchar(255)" json:"title"`
+	Content string `gorm:"type:text" json:"content"`
+}
+
+func (Review) TableName() string {
+	return "reviews"
+}
+
+type ReviewInput struct {
+	BookID  uint   `form:"book_id" json:"book_id" binding:"required"`
+	Rating  int    `form:"rating" json:"rating" binding:"required,min=1,max=5"`
+	Title   string `form:"title" json:"title"`
+	Content string `form:"content" json:"content"`
+}
+
+// UpdateReview is a partial update. Fields that are absent leave the review
+// unchanged.
+type UpdateReview struct {
+	ID      uint    `form:"id" json:"id"`
+	Rating  int     `form:"rating" json:"rating" binding:"omitempty,min=1,max=5"`
+	Title   *string `form:"title" json:"title"`
+	Content *string `form:"content" json:"content"`
+}
+
+const (
+	ReviewSortNewest  = "newest"
+	ReviewSortOldest  = "oldest"
+	ReviewSortHighest = "highest"
+	ReviewSortLowest  = "lowest"
+)
+
+type ReviewQuery struct {
+	Sort string `form:"sort"`
+	PaginationInput
+}
+
+// OrderClause translates the sort parameter into an ORDER BY clause,
+// defaulting to the newest reviews first.
+func (q *ReviewQuery) OrderClause() string {
+	switch q.Sort {
+	case ReviewSortOldest:
+		return "created_at ASC, id ASC"
+	case ReviewSortHighest:
+		return "rating DESC, created_at DESC, id DESC"
+	case ReviewSortLowest:
+		return "rating ASC, created_at DESC, id DESC"
+	default:
+		return "created_at DESC, id DESC"
+	}
+}
+
+type ReviewResponse struct {
+	ID        uint      `json:"id,omitempty"`
+	BookID    uint      `json:"book_id"`
+	UserID    uint      `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func FilterReviewRecord(review *Review) *ReviewResponse {
+	return &ReviewResponse{
+		ID:        review.ID,
+		BookID:    review.BookID,
+		UserID:    review.UserID,
+		Rating:    review.Rating,
+		Title:     review.Title,
+		Content:   review.Content,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	RevisionEntityBook         = "book"
+	RevisionEntityBookCategory = "book_category"
+)
+
+const (
+	// RevisionActionBaseline records the state of a row that existed before
+	// history was kept, so its first recorded change can be reverted.
+	RevisionActionBaseline = "baseline"
+	RevisionActionCreate   = "create"
+	RevisionActionUpdate   = "update"
+	RevisionActionDelete   = "delete"
+	RevisionActionRevert   = "revert"
+	RevisionActionRestore  = "restore"
+	RevisionActionPurge    = "purge"
+)
+
+// Revision is one version of a book or category. Snapshot holds the state
+// after the change and Changes the field-level diff from the previous
+// version, both as JSON.
+type Revision struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	EntityType string    `gorm:"type:varchar(50);uniqueIndex:idx_revisions_entity_version" json:"entity_type"`
+	EntityID   uint      `gorm:"uniqueIndex:idx_revisions_entity_version" json:"entity_id"`
+	Version    int       `gorm:"uniqueIndex:idx_revisions_entity_version" json:"version"`
+	Action     string    `gorm:"type:varchar(20)" json:"action"`
+	ActorID    uint      `json:"actor_id"`
+	Snapshot   string    `gorm:"type:jsonb" json:"snapshot"`
+	Changes    string    `gorm:"type:jsonb" json:"changes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (Revision) TableName() string {
+	return "revisions"
+}
+
+type RevisionChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// BookSnapshot is the versioned part of a book. Aggregates such as ratings
+// and favorites are left out since they aren't edited by hand.
+type BookSnapshot struct {
+	Name            string          `json:"name"`
+	Author          string          `json:"author"`
+	Image           string          `json:"image"`
+	ImageRenditions ImageRenditions `json:"image_renditions"`
+	Description     string          `json:"description"`
+	Tags            string          `json:"tags"`
+	CategoryID      *uint           `json:"category_id"`
+	PublicDate      time.Time       `json:"public_date"`
+}
+
+func NewBookSnapshot(book *Book) *BookSnapshot {
+	return &BookSnapshot{
+		Name:            book.Name,
+		Author:          book.Author,
+		Image:           book.Image,
+		ImageRenditions: book.ImageRenditions,
+		Description:     book.Description,
+		Tags:            book.Tags,
+		CategoryID:      book.CategoryID,
+		PublicDate:      book.PublicDate,
+	}
+}
+
+func (s *BookSnapshot) Apply(book *Book) {
+	book.Name = s.Name
+	book.Author = s.Author
+	book.Image = s.Image
+	book.ImageRenditions = s.ImageRenditions
+	book.Description = s.Description
+	book.Tags = s.Tags
+	book.CategoryID = s.CategoryID
+	// Books without a category were stored with category 0 before the
+	// column became a nullable foreign key.
+	if book.CategoryID != nil && *book.CategoryID == 0 {
+		book.CategoryID = nil
+	}
+	book.PublicDate = s.PublicDate
+}
+
+type BookCategorySnapshot struct {
+	Name            string          `json:"name"`
+	Image           string          `json:"image"`
+	ImageRenditions ImageRenditions `json:"image_renditions"`
+	Description     string          `json:"description"`
+}
+
+func NewBookCategorySnapshot(bookCategory *BookCategory) *BookCategorySnapshot {
+	return &BookCategorySnapshot{
+		Name:            bookCategory.Name,
+		Image:           bookCategory.Image,
+		ImageRenditions: bookCategory.ImageRenditions,
+		Description:     bookCategory.Description,
+	}
+}
+
+func (s *BookCategorySnapshot) Apply(bookCategory *BookCategory) {
+	bookCategory.Name = s.Name
+	bookCategory.Image = s.Image
+	bookCategory.ImageRenditions = s.ImageRenditions
+	bookCategory.Description = s.Description
+}
+
+type RevisionQuery struct {
+	PaginationInput
+}
+
+type RevisionResponse struct {
+	ID         uint             `json:"id"`
+	EntityType string           `json:"entity_type"`
+	EntityID   uint             `json:"entity_id"`
+	Version    int              `json:"version"`
+	Action     string           `json:"action"`
+	ActorID    uint             `json:"actor_id"`
+	Changes    []RevisionChange `json:"changes"`
+	Snapshot   json.RawMessage  `json:"snapshot,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// FilterRevisionRecord leaves the snapshot out, which only the detail
+// endpoint returns.
+func FilterRevisionRecord(revision *Revision) *RevisionResponse {
+	changes := []RevisionChange{}
+	if revision.Changes != "" {
+		_ = json.Unmarshal([]byte(revision.Changes), &changes)
+	}
+
+	return &RevisionResponse{
+		ID:         revision.ID,
+		EntityType: revision.EntityType,
+		EntityID:   revision.EntityID,
+		Version:    revision.Version,
+		Action:     revision.Action,
+		ActorID:    revision.ActorID,
+		Changes:    changes,
+		CreatedAt:  revision.CreatedAt,
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ShelfStatusWantToRead = "want_to_read"
+	ShelfStatusReading    = "reading"
+	ShelfStatusFinished   = "finished"
+)
+
+type ShelfEntry struct {
+	gorm.Model
+	UserID      uint              `gorm:"uniqueIndex:idx_shelf_entries_user_book" json:"user_id"`
+	User        User              `json:"user"`
+	BookID      uint              `gorm:"uniqueIndex:idx_shelf_entries_user_book" json:"book_id"`
+	Book        Book              `json:"book"`
+	Status      string            `gorm:"type:varchar(20);not null;index" json:"status"`
+	CurrentPage int               `json:"current_page"`
+	Percent     float64           `json:"percent"`
+	StartedAt   *time.Time        `json:"started_at"`
+	FinishedAt  *time.Time        `json:"finished_at"`
+	Progress    []ReadingProgress `json:"progress" gorm:"foreignKey:ShelfEntryID"`
+}
+
+func (ShelfEntry) TableName() string {
+	return "shelf_entries"
+}
+
+type ReadingProgress struct {
+	gorm.Model
+	ShelfEntryID uint      `gorm:"index" json:"shelf_entry_id"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	Page         int       `json:"page"`
+	Percent      float64   `json:"percent"`
+	RecordedAt   time.Time `gorm:"index" json:"recorded_at"`
+}
+
+func (ReadingProgress) TableName() string {
+	return "reading_progresses"
+}
+
+type ShelfStatusInput struct {
+	Status     string     `form:"status" json:"status" binding:"required,oneof=want_to_read reading finished"`
+	StartedAt  *time.Time `form:"started_at" json:"started_at" time_format:"02-01-2006"`
+	FinishedAt *time.Time `form:"finished_at" json:"finished_at" time_format:"02-01-2006"`
+}
+
+type ReadingProgressInput struct {
+	Page       *int      `form:"page" json:"page" binding:"omitempty,min=0"`
+	Percent    *float64  `form:"percent" json:"percent" binding:"omitempty,min=0,max=100"`
+	RecordedAt time.Time `form:"recorded_at" json:"recorded_at" time_format:"02-01-2006"`
+}
+
+type ShelfQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=want_to_read reading finished"`
+	PaginationInput
+}
+
+type ReadingStatsQuery struct {
+	Year int `form:"year" binding:"omitempty,min=1900,max=9999"`
+}
+
+type ReadingProgressResponse struct {
+	ID         uint      `json:"id"`
+	Page       int       `json:"page"`
+	Percent    float64   `json:"percent"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+type ShelfEntryResponse struct {
+	ID          uint                       `json:"id"`
+	BookID      uint                       `json:"book_id"`
+	Book        *BookResponse              `json:"book,omitempty"`
+	Status      string                     `json:"status"`
+	CurrentPage int                        `json:"current_page"`
+	Percent     float64                    `json:"percent"`
+	StartedAt   *time.Time                 `json:"started_at"`
+	FinishedAt  *time.Time                 `json:"finished_at"`
+	Progress    []*ReadingProgressResponse `json:"progress,omitempty"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+}
+
+type ReadingStats struct {
+	Year          int   `json:"year"`
+	BooksStarted  int64 `json:"books_started"`
+	BooksFinished int64 `json:"books_finished"`
+	PagesRead     int64 `json:"pages_read"`
+}
+
+func FilterShelfEntryRecord(entry *ShelfEntry) *ShelfEntryResponse {
+	response := &ShelfEntryResponse{
+		ID:          entry.ID,
+		BookID:      entry.BookID,
+		Status:      entry.Status,
+		CurrentPage: entry.CurrentPage,
+		Percent:     entry.Percent,
+		StartedAt:   entry.StartedAt,
+		FinishedAt:  entry.FinishedAt,
+		CreatedAt:   entry.CreatedAt,
+		UpdatedAt:   entry.UpdatedAt,
+	}
+	if entry.Book.ID != 0 {
+		response.Book = FilterBookRecord(&entry.Book)
+	}
+	for _, progress := range entry.Progress {
+		response.Progress = append(response.Progress, &ReadingProgressResponse{
+			ID:         progress.ID,
+			Page:       progress.Page,
+			Percent:    progress.Percent,
+			RecordedAt: progress.RecordedAt,
+		})
+	}
+	return response
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	UploadEntityBook         = "book"
+	UploadEntityBookCategory = "book_category"
+	UploadEntityUser         = "user"
+)
+
+// Upload visibilities, chosen for each upload and defaulting to the one
+// configured for its entity type. Public images are read straight from
+// storage; signed and proxied ones are kept private and read through the
+// API, which redirects to a URL signed on the fly or serves the file
+// itself.
+const (
+	UploadVisibilityPublic = "public"
+	UploadVisibilitySigned = "signed"
+	UploadVisibilityProxy  = "proxy"
+)
+
+// RenditionOriginal names the full size rendition, the uploaded image
+// itself with its metadata stripped.
+const RenditionOriginal = "original"
+
+// ImagePatchMembers can't be set by record patches, since images only
+// change through uploads or imports.
+var ImagePatchMembers = []string{"image", "image_renditions"}
+
+// ImageRenditions maps rendition names such as "thumb" to the storage key
+// or URL of the image at that size. It is stored as a JSON object.
+type ImageRenditions map[string]string
+
+func (r ImageRenditions) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *ImageRenditions) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ImageRenditions", value)
+	}
+	return json.Unmarshal(data, r)
+}
+
+// Upload is a stored file. Its key is derived from the entity type, the
+// owner and a hash of the content, so different files never share a key
+// and the same file uploaded twice by an owner is stored once. The
+// client's filename is only kept for reference. Renditions holds the keys
+// of the scaled down copies of images.
+//
+// An upload no entity references is scheduled for deletion at
+// DeleteAfter, which leaves time for the entity it was uploaded for to be
+// saved, and for it to be referenced again.
+type Upload struct {
+	ID               uint            `gorm:"primarykey" json:"id"`
+	Key              string          `gorm:"type:varchar(255);uniqueIndex" json:"key"`
+	EntityType       string          `gorm:"type:varchar(50);index:idx_uploads_owner" json:"entity_type"`
+	OwnerID          uint            `gorm:"index:idx_uploads_owner" json:"owner_id"`
+	SHA256           string          `gorm:"type:char(64)" json:"sha256"`
+	Size             int64           `json:"size"`
+	ContentType      string          `gorm:"type:varchar(100)" json:"content_type"`
+	OriginalFilename string          `gorm:"type:varchar(255)" json:"original_filename"`
+	Renditions       ImageRenditions `gorm:"type:jsonb" json:"renditions"`
+	DeleteAfter      *time.Time      `gorm:"index" json:"delete_after,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+func (Upload) TableName() string {
+	return "uploads"
+}
+
+// UploadFieldImage is the field of books, book categories and users that
+// holds their image.
+const UploadFieldImage = "image"
+
+// UploadReference records that a field of an entity, such as the image of
+// a book, uses an upload. A field references at most one upload.
+type UploadReference struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	UploadID   uint      `gorm:"index" json:"upload_id"`
+	EntityType string    `gorm:"type:varchar(50);uniqueIndex:idx_upload_references_field" json:"entity_type"`
+	EntityID   uint      `gorm:"uniqueIndex:idx_upload_references_field" json:"entity_id"`
+	Field      string    `gorm:"type:varchar(50);uniqueIndex:idx_upload_references_field" json:"field"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (UploadReference) TableName() string {
+	return "upload_references"
+}
+
+// UploadResponse flags Duplicate when the owner had already uploaded the
+// same file, which was reused instead of stored again. Renditions holds
+// the URL of every size, including the original.
+type UploadResponse struct {
+	ID               uint            `json:"id"`
+	URL              string          `json:"url"`
+	Key              string          `json:"key"`
+	Size             int64           `json:"size"`
+	ContentType      string          `json:"content_type"`
+	OriginalFilename string          `json:"original_filename"`
+	Renditions       ImageRenditions `json:"renditions"`
+	Visibility       string          `json:"visibility"`
+	Duplicate        bool            `json:"duplicate"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+func FilterUploadRecord(upload *Upload, url string, renditions ImageRenditions) *UploadResponse {
+	return &UploadResponse{
+		ID:               upload.ID,
+		URL:              url,
+		Key:              upload.Key,
+		Size:             upload.Size,
+		ContentType:      upload.ContentType,
+		OriginalFilename: upload.OriginalFilename,
+		Renditions:       renditions,
+		CreatedAt:        upload.CreatedAt,
+	}
+}
+
+// PresignUploadInput describes an image the client is about to upload
+// straight to storage, for an entity of EntityType. Visibility defaults to
+// the entity type's.
+type PresignUploadInput struct {
+	EntityType  string `json:"entity_type" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public signed proxy"`
+}
+
+// PresignedUpload tells the client how to upload the file: a Method
+// request to URL with Headers, before ExpiresAt. Key is then passed to the
+// image endpoint of the entity to attach the file to.
+type PresignedUpload struct {
+	Key       string            `json:"key"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// DirectUploadQuery is the signed query of upload URLs pointing at the API.
+type DirectUploadQuery struct {
+	Size      int64  `form:"size"`
+	Expires   int64  `form:"expires"`
+	Signature string `form:"signature"`
+}
+
+type AttachUploadInput struct {
+	Key string `json:"key" binding:"required"`
+}
+
+// SignedFileQuery is the query of the signed URLs private files are read
+// from when the store can't presign them itself.
+type SignedFileQuery struct {
+	Expires   int64  `form:"expires"`
+	Signature string `form:"signature"`
+}
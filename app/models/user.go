@@ -8,12 +8,14 @@ import (
 
 type User struct {
 	gorm.Model
-	Name         string         `gorm:"type:varchar(255)" json:"name"`
-	Email        string         `gorm:"type:varchar(255)" json:"email"`
-	Password     string         `gorm:"type:varchar(255)" json:"password"`
-	Image        string         `gorm:"type:varchar(255)" json:"image"`
-	Books        []Book         `json:"books" gorm:"foreignKey:UserID"`
-	BookCategory []BookCategory `json:"book_categories" gorm:"foreignKey:UserID"`
+	Name            string          `gorm:"type:varchar(255)" json:"name"`
+	Email           string          `gorm:"type:varchar(255)" json:"email"`
+	Password        string          `gorm:"type:varchar(255)" json:"password"`
+	Image           string          `gorm:"type:varchar(255)" json:"image"`
+	ImageRenditions ImageRenditions `gorm:"type:jsonb" json:"image_renditions"`
+	Books           []Book          `json:"books" gorm:"foreignKey:UserID"`
+	BookCategory    []BookCategory  `json:"book_categories" gorm:"foreignKey:UserID"`
+	Version         int64           `gorm:"not null;default:1" json:"version"`
 }
 
 func (User) TableName() string {
@@ -33,12 +35,14 @@ type SignInInput struct {
 }
 
 type UserResponse struct {
-	ID        uint      `json:"id,omitempty"`
-	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
-	Email     string    `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
-	Image     string    `json:"image"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uint            `json:"id,omitempty"`
+	Name            string          `json:"name" gorm:"type:varchar(100);not null"`
+	Email           string          `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Image           string          `json:"image"`
+	ImageRenditions ImageRenditions `json:"image_renditions,omitempty"`
+	Version         int64           `json:"version"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
 type UpdateUser struct {
@@ -55,18 +59,23 @@ type UserProfile struct {
 }
 
 type UserUpdateInput struct {
-	Name  string `form:"name" json:"name" validate:"required"`
-	Email string `form:"email" json:"email" validate:"required"`
-	Image string `file:"image" json:"image"`
+	Name            string          `form:"name" json:"name" validate:"required"`
+	Email           string          `form:"email" json:"email" validate:"required"`
+	Image           string          `file:"image" json:"image"`
+	ImageVisibility string          `form:"image_visibility" json:"image_visibility" binding:"omitempty,oneof=public signed proxy"`
+	ImageRenditions ImageRenditions `form:"-" json:"-"`
+	Version         int64           `form:"-" json:"-"`
 }
 
 func FilterUserRecord(user *User) *UserResponse {
 	return &UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		Image:     user.Image,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		Image:           user.Image,
+		ImageRenditions: user.ImageRenditions,
+		Version:         user.Version,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
 	}
 }
@@ -0,0 +1,12 @@
+package models
+
+// FieldError rejects the value of a single input field. Handlers answer it
+// with 422 and the field's name so clients can point at the bad input.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
@@ -0,0 +1,7 @@
+package models
+
+import "errors"
+
+// ErrVersionConflict is returned when a write expected a version of a book,
+// category or user other than the stored one.
+var ErrVersionConflict = errors.New("the record has been changed since it was read, reload it and try again")
@@ -1,21 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/1rhino/clean_architecture/app/middleware"
 	"github.com/1rhino/clean_architecture/app/models"
 	book_category "github.com/1rhino/clean_architecture/app/modules/book_category/usecase"
+	revisionHandlers "github.com/1rhino/clean_architecture/app/modules/revisions/handlers"
+	uploadHandlers "github.com/1rhino/clean_architecture/app/modules/uploads/handlers"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
+	"github.com/1rhino/clean_architecture/app/patch"
 	"github.com/gin-gonic/gin"
 )
 
 type BookCategoryHandlers struct {
-	bookUseCase book_category.UseCase
+	bookUseCase   book_category.UseCase
+	uploadUseCase upload.UseCase
 }
 
-func NewBookCategoryHandlers(bookUseCase book_category.UseCase) *BookCategoryHandlers {
-	return &BookCategoryHandlers{bookUseCase: bookUseCase}
+func NewBookCategoryHandlers(bookUseCase book_category.UseCase, uploadUseCase upload.UseCase) *BookCategoryHandlers {
+	return &BookCategoryHandlers{bookUseCase: bookUseCase, uploadUseCase: uploadUseCase}
 }
 
 // create a new book category
@@ -33,15 +39,12 @@ func (h *BookCategoryHandlers) CreateBookCategory(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("image")
-	if err == nil {
-		uploadedURL, uploadErr := middleware.HandleUploadImage(file)
-		if uploadErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
-			return
-		}
-		bookCategoryInput.Image = uploadedURL
+	image, renditions, ok := uploadHandlers.InputImage(c, h.uploadUseCase, models.UploadEntityBookCategory, userID, bookCategoryInput.ImageURL, bookCategoryInput.ImageVisibility, nil)
+	if !ok {
+		return
 	}
+	bookCategoryInput.Image = image
+	bookCategoryInput.ImageRenditions = renditions
 
 	createdBookCategory, err := h.bookUseCase.CreateBookCategory(c, &bookCategoryInput, userID)
 	if err != nil {
@@ -60,7 +63,12 @@ func (h *BookCategoryHandlers) GetBookCategories(c *gin.Context) {
 		return
 	}
 
-	bookCategories, err := h.bookUseCase.GetBookCategories(userID)
+	query, ok := bindBookCategoryQuery(c)
+	if !ok {
+		return
+	}
+
+	bookCategories, err := h.bookUseCase.GetBookCategories(userID, query)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -71,7 +79,12 @@ func (h *BookCategoryHandlers) GetBookCategories(c *gin.Context) {
 
 // get all book categories
 func (h *BookCategoryHandlers) GetAllBookCategories(c *gin.Context) {
-	bookCategories, err := h.bookUseCase.GetAllBookCategories()
+	query, ok := bindBookCategoryQuery(c)
+	if !ok {
+		return
+	}
+
+	bookCategories, err := h.bookUseCase.GetAllBookCategories(query)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -88,9 +101,17 @@ func (h *BookCategoryHandlers) GetBookCategoryDetail(c *gin.Context) {
 		return
 	}
 
-	getBookCategory, err := h.bookUseCase.GetBookCategory(uint(bookCategoryID))
+	query, ok := bindBookCategoryQuery(c)
+	if !ok {
+		return
+	}
+
+	getBookCategory, err := h.bookUseCase.GetBookCategory(uint(bookCategoryID), query)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	if middleware.NotModified(c, getBookCategory.Version, getBookCategory) {
 		return
 	}
 
@@ -101,10 +122,11 @@ func (h *BookCategoryHandlers) GetBookCategoryDetail(c *gin.Context) {
 func (h *BookCategoryHandlers) UpdateBookCategory(c *gin.Context) {
 	var bookCategoryInput models.UpdateBookCategory
 
-	if err := c.ShouldBind(&bookCategoryInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	inputPatch, ok := middleware.BindPatch(c, &bookCategoryInput)
+	if !ok {
 		return
 	}
+	bookCategoryInput.Patch = inputPatch
 
 	bookCategoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -112,23 +134,79 @@ func (h *BookCategoryHandlers) UpdateBookCategory(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("image")
-	if err == nil {
-		uploadedURL, uploadErr := middleware.HandleUploadImage(file)
-		if uploadErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
-			return
-		}
-		bookCategoryInput.Image = uploadedURL
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, renditions, ok := uploadHandlers.InputImage(c, h.uploadUseCase, models.UploadEntityBookCategory, userID, bookCategoryInput.ImageURL, bookCategoryInput.ImageVisibility, bookCategoryInput.Patch)
+	if !ok {
+		return
 	}
+	bookCategoryInput.Image = image
+	bookCategoryInput.ImageRenditions = renditions
 
 	bookCategoryInput.ID = uint(bookCategoryID)
-	updatedBookCategory, err := h.bookUseCase.UpdateBookCategory(c, &bookCategoryInput)
+	bookCategoryInput.Version = version
+	updatedBookCategory, err := h.bookUseCase.UpdateBookCategory(c, &bookCategoryInput, userID)
 	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetETag(c, updatedBookCategory.Version)
+	c.JSON(http.StatusOK, gin.H{"data": updatedBookCategory})
+}
+
+// attach an image uploaded straight to storage to a book category
+func (h *BookCategoryHandlers) AttachBookCategoryImage(c *gin.Context) {
+	var input models.AttachUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	bookCategoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book category ID"})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	uploaded, err := h.uploadUseCase.Finalize(c.Request.Context(), models.UploadEntityBookCategory, userID, input.Key)
+	if err != nil {
+		uploadHandlers.WriteError(c, err)
+		return
+	}
+
+	updatedBookCategory, err := h.bookUseCase.UpdateBookCategory(c, &models.UpdateBookCategory{
+		ID:              uint(bookCategoryID),
+		Version:         version,
+		Image:           uploaded.URL,
+		ImageRenditions: uploaded.Renditions,
+	}, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetETag(c, updatedBookCategory.Version)
 	c.JSON(http.StatusOK, gin.H{"data": updatedBookCategory})
 }
 
@@ -140,11 +218,138 @@ func (h *BookCategoryHandlers) DeleteBookCategory(c *gin.Context) {
 		return
 	}
 
-	err = h.bookUseCase.DeleteBookCategory(uint(bookCategoryID))
+	var reassignTo uint64
+	if param := c.Query("reassign_to"); param != "" {
+		reassignTo, err = strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to book category ID"})
+			return
+		}
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	err = h.bookUseCase.DeleteBookCategory(uint(bookCategoryID), uint(reassignTo), userID, version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book category"})
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to delete book category"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "BookCategory deleted successfully"})
 }
+
+// merge a book category into another one
+func (h *BookCategoryHandlers) MergeBookCategory(c *gin.Context) {
+	var mergeInput models.MergeBookCategoryInput
+
+	if err := c.ShouldBind(&mergeInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookCategoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book category ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	err = h.bookUseCase.MergeBookCategory(uint(bookCategoryID), mergeInput.TargetID, userID, version)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to merge book category"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "BookCategory merged successfully"})
+}
+
+// revert a book category to one of its revisions
+func (h *BookCategoryHandlers) RevertBookCategory(c *gin.Context) {
+	bookCategoryID, revision, ok := revisionHandlers.ParseRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	revertedBookCategory, err := h.bookUseCase.RevertBookCategory(bookCategoryID, revision, userID, version)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetETag(c, revertedBookCategory.Version)
+	c.JSON(http.StatusOK, gin.H{"data": revertedBookCategory})
+}
+
+// bindBookCategoryQuery reads the optional include and pagination parameters,
+// writing a 400 response when they are malformed.
+func bindBookCategoryQuery(c *gin.Context) (*models.BookCategoryQuery, bool) {
+	var query models.BookCategoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	query.Normalize()
+	return &query, true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, book_category.ErrBookCategoryNotFound), errors.Is(err, book_category.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, book_category.ErrBookCategoryHasBooks):
+		return http.StatusConflict
+	case errors.Is(err, book_category.ErrMergeIntoItself), errors.Is(err, book_category.ErrNameRequired),
+		errors.Is(err, patch.ErrInvalidDocument):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, patch.ErrMalformedPatch):
+		return http.StatusBadRequest
+	case errors.Is(err, patch.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	default:
+		return fallback
+	}
+}
@@ -1,17 +1,34 @@
 package repository
 
 import (
+	"errors"
+
 	"github.com/1rhino/clean_architecture/app/models"
+	revisionRepository "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrHasBooks is returned by Delete for categories that still have books.
+	ErrHasBooks = errors.New("book category still has books")
+	// ErrNotOwned is returned by LockOwned.
+	ErrNotOwned = errors.New("book category does not exist or belongs to another user")
 )
 
 type BookCategoryRepository interface {
-	Create(bookCategory *models.BookCategory) (*models.BookCategory, error)
+	Create(bookCategory *models.BookCategory, actorID uint) (*models.BookCategory, error)
 	FindByUserID(userID uint) ([]*models.BookCategory, error)
 	FindAll() ([]*models.BookCategory, error)
 	FindByID(id uint) (*models.BookCategory, error)
-	Update(bookCategory *models.BookCategory) (*models.BookCategory, error)
-	Delete(bookCategoryID uint) error
+	Update(bookCategory *models.BookCategory, actorID uint) (*models.BookCategory, error)
+	Revert(bookCategory *models.BookCategory, actorID uint) (*models.BookCategory, error)
+	Delete(bookCategoryID, actorID uint, version int64) error
+	CountBooks(bookCategoryID uint) (int64, error)
+	Merge(sourceID, targetID, actorID uint, version int64) error
+	CountBooksByCategoryIDs(bookCategoryIDs []uint) (map[uint]int64, error)
+	FindLatestBooksByCategoryIDs(bookCategoryIDs []uint, limit int) (map[uint][]*models.Book, error)
+	FindBooks(bookCategoryID uint, limit, offset int) ([]*models.Book, error)
 }
 
 type BookCategoryRepo struct {
@@ -22,8 +39,16 @@ func NewBookCategoryRepo(db *gorm.DB) BookCategoryRepository {
 	return &BookCategoryRepo{DB: db}
 }
 
-func (r *BookCategoryRepo) Create(bookCategory *models.BookCategory) (*models.BookCategory, error) {
-	if err := r.DB.Create(bookCategory).Error; err != nil {
+// Create inserts the category and records it as the first revision.
+func (r *BookCategoryRepo) Create(bookCategory *models.BookCategory, actorID uint) (*models.BookCategory, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bookCategory).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBookCategory, bookCategory.ID, actorID,
+			models.RevisionActionCreate, nil, models.NewBookCategorySnapshot(bookCategory))
+	})
+	if err != nil {
 		return nil, err
 	}
 	return bookCategory, nil
@@ -53,13 +78,201 @@ func (r *BookCategoryRepo) FindByID(id uint) (*models.BookCategory, error) {
 	return &bookCategory, nil
 }
 
-func (r *BookCategoryRepo) Update(bookCategory *models.BookCategory) (*models.BookCategory, error) {
-	if err := r.DB.Save(bookCategory).Error; err != nil {
+func (r *BookCategoryRepo) Update(bookCategory *models.BookCategory, actorID uint) (*models.BookCategory, error) {
+	return r.save(bookCategory, actorID, models.RevisionActionUpdate)
+}
+
+func (r *BookCategoryRepo) Revert(bookCategory *models.BookCategory, actorID uint) (*models.BookCategory, error) {
+	return r.save(bookCategory, actorID, models.RevisionActionRevert)
+}
+
+// save writes the changed columns and the revision if the version matches.
+func (r *BookCategoryRepo) save(bookCategory *models.BookCategory, actorID uint, action string) (*models.BookCategory, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.BookCategory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&previous, bookCategory.ID).Error; err != nil {
+			return err
+		}
+		if previous.Version != bookCategory.Version {
+			return models.ErrVersionConflict
+		}
+
+		before, after := models.NewBookCategorySnapshot(&previous), models.NewBookCategorySnapshot(bookCategory)
+		columns, err := revisionRepository.ChangedFields(before, after)
+		if err != nil || len(columns) == 0 {
+			return err
+		}
+
+		bookCategory.Version++
+		if err := tx.Model(bookCategory).Select(append(columns, "version")).Updates(bookCategory).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBookCategory, bookCategory.ID, actorID, action, before, after)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return bookCategory, nil
 }
 
-func (r *BookCategoryRepo) Delete(id uint) error {
-	return r.DB.Delete(&models.BookCategory{}, id).Error
+// Delete soft-deletes the category unless it has books, counting them
+// under a lock on the category row.
+func (r *BookCategoryRepo) Delete(id, actorID uint, version int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.BookCategory{}, id).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Book{}).Where("category_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrHasBooks
+		}
+		return deleteBookCategory(tx, id, actorID, version)
+	})
+}
+
+func deleteBookCategory(tx *gorm.DB, id, actorID uint, version int64) error {
+	var bookCategory models.BookCategory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bookCategory, id).Error; err != nil {
+		return err
+	}
+	if version != 0 && bookCategory.Version != version {
+		return models.ErrVersionConflict
+	}
+	if err := tx.Delete(&bookCategory).Error; err != nil {
+		return err
+	}
+	return revisionRepository.RecordRevision(tx, models.RevisionEntityBookCategory, bookCategory.ID, actorID,
+		models.RevisionActionDelete, models.NewBookCategorySnapshot(&bookCategory), nil)
+}
+
+func (r *BookCategoryRepo) CountBooks(bookCategoryID uint) (int64, error) {
+	var count int64
+	if err := r.DB.Model(&models.Book{}).Where("category_id = ?", bookCategoryID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Merge moves every book of the source category, soft-deleted ones
+// included, to the target and deletes the source in one transaction. The
+// source is locked first, as Delete does, so no book is filed under it
+// while the books are moved.
+func (r *BookCategoryRepo) Merge(sourceID, targetID, actorID uint, version int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.BookCategory{}, sourceID).Error; err != nil {
+			return err
+		}
+		if err := LockOwned(tx, targetID, actorID); err != nil {
+			return err
+		}
+
+		var books []*models.Book
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("category_id = ?", sourceID).
+			Find(&books).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Model(&models.Book{}).Where("category_id = ?", sourceID).Updates(map[string]interface{}{
+			"category_id": targetID,
+			"version":     gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, book := range books {
+			before := models.NewBookSnapshot(book)
+			book.CategoryID = &targetID
+			err := revisionRepository.RecordRevision(tx, models.RevisionEntityBook, book.ID, actorID,
+				models.RevisionActionUpdate, before, models.NewBookSnapshot(book))
+			if err != nil {
+				return err
+			}
+		}
+
+		return deleteBookCategory(tx, sourceID, actorID, version)
+	})
+}
+
+// LockOwned checks that books can be filed under the category: it must
+// exist and belong to userID, or ErrNotOwned is returned. The category is
+// share-locked until the transaction ends, so it can't be deleted before
+// the books are written.
+func LockOwned(tx *gorm.DB, id, userID uint) error {
+	var bookCategory models.BookCategory
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+		Select("id").
+		Where("id = ? AND user_id = ?", id, userID).
+		Limit(1).
+		Find(&bookCategory).Error
+	if err != nil {
+		return err
+	}
+	if bookCategory.ID == 0 {
+		return ErrNotOwned
+	}
+	return nil
+}
+
+func (r *BookCategoryRepo) CountBooksByCategoryIDs(bookCategoryIDs []uint) (map[uint]int64, error) {
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err := r.DB.Model(&models.Book{}).
+		Select("category_id, COUNT(*) AS count").
+		Where("category_id IN ?", bookCategoryIDs).
+		Group("category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts, nil
+}
+
+// FindLatestBooksByCategoryIDs returns up to limit of the newest books of
+// each category using a single windowed query.
+func (r *BookCategoryRepo) FindLatestBooksByCategoryIDs(bookCategoryIDs []uint, limit int) (map[uint][]*models.Book, error) {
+	var books []*models.Book
+	err := r.DB.Raw(`
+		SELECT * FROM (
+			SELECT books.*, ROW_NUMBER() OVER (PARTITION BY category_id ORDER BY created_at DESC, id DESC) AS book_rank
+			FROM books
+			WHERE category_id IN ? AND deleted_at IS NULL
+		) ranked
+		WHERE book_rank <= ?
+		ORDER BY category_id, book_rank`, bookCategoryIDs, limit).
+		Scan(&books).Error
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[uint][]*models.Book)
+	for _, book := range books {
+		latest[*book.CategoryID] = append(latest[*book.CategoryID], book)
+	}
+	return latest, nil
+}
+
+func (r *BookCategoryRepo) FindBooks(bookCategoryID uint, limit, offset int) ([]*models.Book, error) {
+	var books []*models.Book
+	err := r.DB.Where("category_id = ?", bookCategoryID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
 }
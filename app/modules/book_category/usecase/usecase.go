@@ -1,43 +1,66 @@
 package usecase
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+
 	"github.com/1rhino/clean_architecture/app/models"
 	repository "github.com/1rhino/clean_architecture/app/modules/book_category/repositories"
+	revisionRepository "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookCategoryNotFound = errors.New("book category not found")
+	ErrBookCategoryHasBooks = errors.New("book category still has books, pass reassign_to to move them")
+	ErrMergeIntoItself      = errors.New("cannot merge a book category into itself")
+	ErrRevisionNotFound     = errors.New("revision not found")
+	ErrNameRequired         = errors.New("name is required")
 )
 
 type UseCase interface {
 	CreateBookCategory(ctx *gin.Context, bookCategory *models.BookCategoryInput, userID uint) (*models.BookCategoryResponse, error)
-	GetBookCategories(userID uint) ([]*models.BookCategoryResponse, error)
-	GetAllBookCategories() ([]*models.BookCategoryResponse, error)
-	GetBookCategory(bookCategoryID uint) (*models.BookCategoryResponse, error)
-	UpdateBookCategory(ctx *gin.Context, bookCategoryInput *models.UpdateBookCategory) (*models.BookCategoryResponse, error)
-	DeleteBookCategory(bookCategoryID uint) error
+	GetBookCategories(userID uint, query *models.BookCategoryQuery) ([]*models.BookCategoryResponse, error)
+	GetAllBookCategories(query *models.BookCategoryQuery) ([]*models.BookCategoryResponse, error)
+	GetBookCategory(bookCategoryID uint, query *models.BookCategoryQuery) (*models.BookCategoryResponse, error)
+	UpdateBookCategory(ctx *gin.Context, bookCategoryInput *models.UpdateBookCategory, userID uint) (*models.BookCategoryResponse, error)
+	RevertBookCategory(bookCategoryID uint, revision int, userID uint, version int64) (*models.BookCategoryResponse, error)
+	DeleteBookCategory(bookCategoryID uint, reassignTo uint, userID uint, version int64) error
+	MergeBookCategory(sourceID, targetID uint, userID uint, version int64) error
 }
 
 type BookCategoryUseCase struct {
 	bookCategoryRepo repository.BookCategoryRepository
+	revisionRepo     revisionRepository.RevisionRepository
+	uploadUseCase    upload.UseCase
 }
 
-func NewBookCategoryUseCase(bookCategoryRepo repository.BookCategoryRepository) UseCase {
-	return &BookCategoryUseCase{bookCategoryRepo: bookCategoryRepo}
+func NewBookCategoryUseCase(bookCategoryRepo repository.BookCategoryRepository, revisionRepo revisionRepository.RevisionRepository, uploadUseCase upload.UseCase) UseCase {
+	return &BookCategoryUseCase{bookCategoryRepo: bookCategoryRepo, revisionRepo: revisionRepo, uploadUseCase: uploadUseCase}
 }
 
 func (u *BookCategoryUseCase) CreateBookCategory(ctx *gin.Context, bookCategoryInput *models.BookCategoryInput, userID uint) (*models.BookCategoryResponse, error) {
 	bookCategory := &models.BookCategory{
-		Name:        bookCategoryInput.Name,
-		Image:       bookCategoryInput.Image,
-		Description: bookCategoryInput.Description,
-		UserID:      userID,
+		Name:            bookCategoryInput.Name,
+		Image:           bookCategoryInput.Image,
+		ImageRenditions: bookCategoryInput.ImageRenditions,
+		Description:     bookCategoryInput.Description,
+		UserID:          userID,
 	}
-	createBookCategory, err := u.bookCategoryRepo.Create(bookCategory)
+	createBookCategory, err := u.bookCategoryRepo.Create(bookCategory, userID)
 	if err != nil {
 		return nil, err
 	}
+	if createBookCategory.Image != "" {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBookCategory, createBookCategory.ID, createBookCategory.Image)
+	}
 	return models.FilterBookCategoryRecord(createBookCategory), nil
 }
 
-func (u *BookCategoryUseCase) GetBookCategories(userID uint) ([]*models.BookCategoryResponse, error) {
+func (u *BookCategoryUseCase) GetBookCategories(userID uint, query *models.BookCategoryQuery) ([]*models.BookCategoryResponse, error) {
 	bookCategories, err := u.bookCategoryRepo.FindByUserID(userID)
 	if err != nil {
 		return nil, err
@@ -47,10 +70,13 @@ func (u *BookCategoryUseCase) GetBookCategories(userID uint) ([]*models.BookCate
 	for _, bookCategory := range bookCategories {
 		bookCategoryResponses = append(bookCategoryResponses, models.FilterBookCategoryRecord(bookCategory))
 	}
+	if err := u.includeBookSummaries(bookCategoryResponses, query); err != nil {
+		return nil, err
+	}
 	return bookCategoryResponses, nil
 }
 
-func (u *BookCategoryUseCase) GetAllBookCategories() ([]*models.BookCategoryResponse, error) {
+func (u *BookCategoryUseCase) GetAllBookCategories(query *models.BookCategoryQuery) ([]*models.BookCategoryResponse, error) {
 	bookCategories, err := u.bookCategoryRepo.FindAll()
 	if err != nil {
 		return nil, err
@@ -60,41 +86,209 @@ func (u *BookCategoryUseCase) GetAllBookCategories() ([]*models.BookCategoryResp
 	for _, bookCategory := range bookCategories {
 		bookCategoryResponses = append(bookCategoryResponses, models.FilterBookCategoryRecord(bookCategory))
 	}
+	if err := u.includeBookSummaries(bookCategoryResponses, query); err != nil {
+		return nil, err
+	}
 	return bookCategoryResponses, nil
 }
 
-func (u *BookCategoryUseCase) UpdateBookCategory(ctx *gin.Context, bookCategoryInput *models.UpdateBookCategory) (*models.BookCategoryResponse, error) {
-	bookCategory, err := u.bookCategoryRepo.FindByID(bookCategoryInput.ID)
+func (u *BookCategoryUseCase) UpdateBookCategory(ctx *gin.Context, bookCategoryInput *models.UpdateBookCategory, userID uint) (*models.BookCategoryResponse, error) {
+	bookCategory, err := u.findBookCategory(bookCategoryInput.ID)
 	if err != nil {
 		return nil, err
 	}
+	if bookCategoryInput.Version != 0 && bookCategoryInput.Version != bookCategory.Version {
+		return nil, models.ErrVersionConflict
+	}
 
-	bookCategory.Name = bookCategoryInput.Name
-	bookCategory.Description = bookCategoryInput.Description
-	if bookCategoryInput.Image != "" {
-		bookCategory.Image = bookCategoryInput.Image
+	image := bookCategory.Image
+	if err := bookCategoryInput.Apply(bookCategory); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(bookCategory.Name) == "" {
+		return nil, ErrNameRequired
 	}
 
-	updatedBookCategory, err := u.bookCategoryRepo.Update(bookCategory)
+	updatedBookCategory, err := u.bookCategoryRepo.Update(bookCategory, userID)
 	if err != nil {
 		return nil, err
 	}
+	if updatedBookCategory.Image != image {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBookCategory, updatedBookCategory.ID, updatedBookCategory.Image)
+	}
 
 	return models.FilterBookCategoryRecord(updatedBookCategory), nil
 }
 
-func (u *BookCategoryUseCase) DeleteBookCategory(bookCategoryID uint) error {
-	err := u.bookCategoryRepo.Delete(bookCategoryID)
+func (u *BookCategoryUseCase) RevertBookCategory(bookCategoryID uint, revision int, userID uint, version int64) (*models.BookCategoryResponse, error) {
+	bookCategory, err := u.findBookCategory(bookCategoryID)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && version != bookCategory.Version {
+		return nil, models.ErrVersionConflict
+	}
+
+	bookCategoryRevision, err := u.revisionRepo.FindRevision(models.RevisionEntityBookCategory, bookCategoryID, revision)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot models.BookCategorySnapshot
+	if err := json.Unmarshal([]byte(bookCategoryRevision.Snapshot), &snapshot); err != nil {
+		return nil, err
+	}
+	image := bookCategory.Image
+	snapshot.Apply(bookCategory)
+
+	revertedBookCategory, err := u.bookCategoryRepo.Revert(bookCategory, userID)
 	if err != nil {
+		return nil, err
+	}
+	if revertedBookCategory.Image != image {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBookCategory, revertedBookCategory.ID, revertedBookCategory.Image)
+	}
+
+	return models.FilterBookCategoryRecord(revertedBookCategory), nil
+}
+
+// DeleteBookCategory refuses to delete a category that still has books,
+// unless reassignTo names the category they should be moved to.
+func (u *BookCategoryUseCase) DeleteBookCategory(bookCategoryID uint, reassignTo uint, userID uint, version int64) error {
+	if reassignTo != 0 {
+		return u.MergeBookCategory(bookCategoryID, reassignTo, userID, version)
+	}
+
+	if _, err := u.findOwnedBookCategory(bookCategoryID, userID); err != nil {
 		return err
 	}
+
+	err := u.bookCategoryRepo.Delete(bookCategoryID, userID, version)
+	if errors.Is(err, repository.ErrHasBooks) {
+		return ErrBookCategoryHasBooks
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookCategoryNotFound
+	}
+	return err
+}
+
+func (u *BookCategoryUseCase) MergeBookCategory(sourceID, targetID uint, userID uint, version int64) error {
+	if sourceID == targetID {
+		return ErrMergeIntoItself
+	}
+
+	if _, err := u.findOwnedBookCategory(sourceID, userID); err != nil {
+		return err
+	}
+	if _, err := u.findOwnedBookCategory(targetID, userID); err != nil {
+		return err
+	}
+
+	err := u.bookCategoryRepo.Merge(sourceID, targetID, userID, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, repository.ErrNotOwned) {
+		return ErrBookCategoryNotFound
+	}
+	return err
+}
+
+func (u *BookCategoryUseCase) GetBookCategory(bookCategoryID uint, query *models.BookCategoryQuery) (*models.BookCategoryResponse, error) {
+	bookCategory, err := u.findBookCategory(bookCategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookCategoryResponse := models.FilterBookCategoryRecord(bookCategory)
+	if err := u.includeBookSummaries([]*models.BookCategoryResponse{bookCategoryResponse}, query); err != nil {
+		return nil, err
+	}
+
+	if query != nil && query.Includes(models.IncludeBooks) {
+		total, err := u.bookCategoryRepo.CountBooks(bookCategoryID)
+		if err != nil {
+			return nil, err
+		}
+		books, err := u.bookCategoryRepo.FindBooks(bookCategoryID, query.PerPage, query.Offset())
+		if err != nil {
+			return nil, err
+		}
+
+		bookResponses := make([]*models.BookResponse, 0, len(books))
+		for _, book := range books {
+			bookResponses = append(bookResponses, models.FilterBookRecord(book))
+		}
+		bookCategoryResponse.Books = &models.BookCategoryBooks{
+			Data: bookResponses,
+			Meta: models.NewPaginationMeta(&query.PaginationInput, total),
+		}
+	}
+
+	return bookCategoryResponse, nil
+}
+
+// includeBookSummaries fills in book counts and latest books for all the
+// given categories with one aggregate query each.
+func (u *BookCategoryUseCase) includeBookSummaries(bookCategoryResponses []*models.BookCategoryResponse, query *models.BookCategoryQuery) error {
+	if query == nil || len(bookCategoryResponses) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(bookCategoryResponses))
+	for _, bookCategoryResponse := range bookCategoryResponses {
+		ids = append(ids, bookCategoryResponse.ID)
+	}
+
+	if query.Includes(models.IncludeBookCount) {
+		counts, err := u.bookCategoryRepo.CountBooksByCategoryIDs(ids)
+		if err != nil {
+			return err
+		}
+		for _, bookCategoryResponse := range bookCategoryResponses {
+			count := counts[bookCategoryResponse.ID]
+			bookCategoryResponse.BookCount = &count
+		}
+	}
+
+	if query.Includes(models.IncludeLatestBooks) {
+		latest, err := u.bookCategoryRepo.FindLatestBooksByCategoryIDs(ids, query.Latest)
+		if err != nil {
+			return err
+		}
+		for _, bookCategoryResponse := range bookCategoryResponses {
+			bookResponses := make([]*models.BookResponse, 0, len(latest[bookCategoryResponse.ID]))
+			for _, book := range latest[bookCategoryResponse.ID] {
+				bookResponses = append(bookResponses, models.FilterBookRecord(book))
+			}
+			bookCategoryResponse.LatestBooks = bookResponses
+		}
+	}
+
 	return nil
 }
 
-func (u *BookCategoryUseCase) GetBookCategory(bookCategoryID uint) (*models.BookCategoryResponse, error) {
+// findOwnedBookCategory reports categories of other users as not found,
+// so they can't be probed.
+func (u *BookCategoryUseCase) findOwnedBookCategory(bookCategoryID, userID uint) (*models.BookCategory, error) {
+	bookCategory, err := u.findBookCategory(bookCategoryID)
+	if err != nil {
+		return nil, err
+	}
+	if bookCategory.UserID != userID {
+		return nil, ErrBookCategoryNotFound
+	}
+	return bookCategory, nil
+}
+
+func (u *BookCategoryUseCase) findBookCategory(bookCategoryID uint) (*models.BookCategory, error) {
 	bookCategory, err := u.bookCategoryRepo.FindByID(bookCategoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookCategoryNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	return models.FilterBookCategoryRecord(bookCategory), nil
+	return bookCategory, nil
 }
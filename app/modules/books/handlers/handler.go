@@ -1,21 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/1rhino/clean_architecture/app/middleware"
 	"github.com/1rhino/clean_architecture/app/models"
 	book "github.com/1rhino/clean_architecture/app/modules/books/usecase"
+	revisionHandlers "github.com/1rhino/clean_architecture/app/modules/revisions/handlers"
+	uploadHandlers "github.com/1rhino/clean_architecture/app/modules/uploads/handlers"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
+	"github.com/1rhino/clean_architecture/app/patch"
 	"github.com/gin-gonic/gin"
 )
 
 type BookHandlers struct {
-	bookUseCase book.UseCase
+	bookUseCase   book.UseCase
+	uploadUseCase upload.UseCase
 }
 
-func NewBookHandlers(bookUseCase book.UseCase) *BookHandlers {
-	return &BookHandlers{bookUseCase: bookUseCase}
+func NewBookHandlers(bookUseCase book.UseCase, uploadUseCase upload.UseCase) *BookHandlers {
+	return &BookHandlers{bookUseCase: bookUseCase, uploadUseCase: uploadUseCase}
 }
 
 // create a new book
@@ -33,19 +39,16 @@ func (h *BookHandlers) CreateBook(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("image")
-	if err == nil {
-		uploadedURL, uploadErr := middleware.HandleUploadImage(file)
-		if uploadErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
-			return
-		}
-		bookInput.Image = uploadedURL
+	image, renditions, ok := uploadHandlers.InputImage(c, h.uploadUseCase, models.UploadEntityBook, userID, bookInput.ImageURL, bookInput.ImageVisibility, nil)
+	if !ok {
+		return
 	}
+	bookInput.Image = image
+	bookInput.ImageRenditions = renditions
 
 	createdBook, err := h.bookUseCase.CreateBook(c, &bookInput, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
 		return
 	}
 
@@ -54,7 +57,19 @@ func (h *BookHandlers) CreateBook(c *gin.Context) {
 
 // get list of books
 func (h *BookHandlers) GetAllBooks(c *gin.Context) {
-	books, err := h.bookUseCase.GetAllBooks()
+	var query models.BookQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := h.bookUseCase.GetAllBooks(userID, &query)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -65,13 +80,19 @@ func (h *BookHandlers) GetAllBooks(c *gin.Context) {
 
 // get list books by userID
 func (h *BookHandlers) GetBooks(c *gin.Context) {
+	var query models.BookQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	books, err := h.bookUseCase.GetBooks(userID)
+	books, err := h.bookUseCase.GetBooks(userID, &query)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -88,11 +109,20 @@ func (h *BookHandlers) GetBookDetail(c *gin.Context) {
 		return
 	}
 
-	getBook, err := h.bookUseCase.GetBook(uint(bookID))
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	getBook, err := h.bookUseCase.GetBook(uint(bookID), userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if middleware.NotModified(c, getBook.Version, getBook) {
+		return
+	}
 
 	c.JSON(http.StatusOK, getBook)
 }
@@ -101,10 +131,11 @@ func (h *BookHandlers) GetBookDetail(c *gin.Context) {
 func (h *BookHandlers) UpdateBook(c *gin.Context) {
 	var bookInput models.UpdateBook
 
-	if err := c.ShouldBind(&bookInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	inputPatch, ok := middleware.BindPatch(c, &bookInput)
+	if !ok {
 		return
 	}
+	bookInput.Patch = inputPatch
 
 	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -112,23 +143,79 @@ func (h *BookHandlers) UpdateBook(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("image")
-	if err == nil {
-		uploadedURL, uploadErr := middleware.HandleUploadImage(file)
-		if uploadErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
-			return
-		}
-		bookInput.Image = uploadedURL
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
 	}
 
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, renditions, ok := uploadHandlers.InputImage(c, h.uploadUseCase, models.UploadEntityBook, userID, bookInput.ImageURL, bookInput.ImageVisibility, bookInput.Patch)
+	if !ok {
+		return
+	}
+	bookInput.Image = image
+	bookInput.ImageRenditions = renditions
+
 	bookInput.ID = uint(bookID)
-	updatedBook, err := h.bookUseCase.UpdateBook(c, &bookInput)
+	bookInput.Version = version
+	updatedBook, err := h.bookUseCase.UpdateBook(c, &bookInput, userID)
 	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	middleware.SetETag(c, updatedBook.Version)
+	c.JSON(http.StatusOK, gin.H{"data": updatedBook})
+}
+
+// attach an image uploaded straight to storage to a book
+func (h *BookHandlers) AttachBookImage(c *gin.Context) {
+	var input models.AttachUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	uploaded, err := h.uploadUseCase.Finalize(c.Request.Context(), models.UploadEntityBook, userID, input.Key)
+	if err != nil {
+		uploadHandlers.WriteError(c, err)
+		return
+	}
+
+	updatedBook, err := h.bookUseCase.UpdateBook(c, &models.UpdateBook{
+		ID:              uint(bookID),
+		Version:         version,
+		Image:           uploaded.URL,
+		ImageRenditions: uploaded.Renditions,
+	}, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	middleware.SetETag(c, updatedBook.Version)
 	c.JSON(http.StatusOK, gin.H{"data": updatedBook})
 }
 
@@ -140,11 +227,83 @@ func (h *BookHandlers) DeleteBook(c *gin.Context) {
 		return
 	}
 
-	err = h.bookUseCase.DeleteBook(uint(bookID))
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	err = h.bookUseCase.DeleteBook(uint(bookID), userID, version)
+	if errors.Is(err, book.ErrBookNotFound) || errors.Is(err, models.ErrVersionConflict) {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book category"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "BookCategory deleted successfully"})
-}
\ No newline at end of file
+}
+
+// revert a book to one of its revisions
+func (h *BookHandlers) RevertBook(c *gin.Context) {
+	bookID, revision, ok := revisionHandlers.ParseRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	revertedBook, err := h.bookUseCase.RevertBook(bookID, revision, userID, version)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	middleware.SetETag(c, revertedBook.Version)
+	c.JSON(http.StatusOK, gin.H{"data": revertedBook})
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	var fieldErr *models.FieldError
+	switch {
+	case errors.Is(err, book.ErrBookNotFound), errors.Is(err, book.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, patch.ErrMalformedPatch):
+		return http.StatusBadRequest
+	case errors.Is(err, patch.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, book.ErrNameRequired), errors.Is(err, patch.ErrInvalidDocument), errors.As(err, &fieldErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
+
+// errorBody names the offending field of validation errors.
+func errorBody(err error) gin.H {
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		return gin.H{"error": fieldErr.Message, "field": fieldErr.Field}
+	}
+	return gin.H{"error": err.Error()}
+}
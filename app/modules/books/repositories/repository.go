@@ -2,16 +2,23 @@ package repository
 
 import (
 	"github.com/1rhino/clean_architecture/app/models"
+	bookCategoryRepository "github.com/1rhino/clean_architecture/app/modules/book_category/repositories"
+	revisionRepository "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BookRepository interface {
-	Create(book *models.Book) (*models.Book, error)
-	FindAll() ([]*models.Book, error)
-	FindByUserID(userID uint) ([]*models.Book, error)
+	Create(book *models.Book, actorID uint) (*models.Book, error)
+	FindAll(order string) ([]*models.Book, error)
+	FindByUserID(userID uint, order string) ([]*models.Book, error)
 	FindByID(id uint) (*models.Book, error)
-	Update(book *models.Book) (*models.Book, error)
-	Delete(id uint) error
+	FindCategory(id uint) (*models.BookCategory, error)
+	Update(book *models.Book, actorID uint) (*models.Book, error)
+	Revert(book *models.Book, actorID uint) (*models.Book, error)
+	Delete(id, actorID uint, version int64) error
+	GetAvailability(bookID uint) (*models.BookAvailability, error)
+	FavoritedBookIDs(userID uint, bookIDs []uint) (map[uint]bool, error)
 }
 
 type BookRepo struct {
@@ -22,24 +29,39 @@ func NewBookRepo(db *gorm.DB) BookRepository {
 	return &BookRepo{DB: db}
 }
 
-func (r *BookRepo) Create(book *models.Book) (*models.Book, error) {
-	if err := r.DB.Create(book).Error; err != nil {
+// Create inserts the book and records it as the first revision. A book
+// filed under a category the user doesn't own returns
+// bookCategoryRepository.ErrNotOwned, as do Update and Revert.
+func (r *BookRepo) Create(book *models.Book, actorID uint) (*models.Book, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if book.CategoryID != nil {
+			if err := bookCategoryRepository.LockOwned(tx, *book.CategoryID, book.UserID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(book).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBook, book.ID, actorID,
+			models.RevisionActionCreate, nil, models.NewBookSnapshot(book))
+	})
+	if err != nil {
 		return nil, err
 	}
 	return book, nil
 }
 
-func (r *BookRepo) FindAll() ([]*models.Book, error) {
+func (r *BookRepo) FindAll(order string) ([]*models.Book, error) {
 	var books []*models.Book
-	if err := r.DB.Find(&books).Error; err != nil {
+	if err := r.DB.Order(order).Find(&books).Error; err != nil {
 		return nil, err
 	}
 	return books, nil
 }
 
-func (r *BookRepo) FindByUserID(userID uint) ([]*models.Book, error) {
+func (r *BookRepo) FindByUserID(userID uint, order string) ([]*models.Book, error) {
 	var books []*models.Book
-	if err := r.DB.Where("user_id = ?", userID).Find(&books).Error; err != nil {
+	if err := r.DB.Where("user_id = ?", userID).Order(order).Find(&books).Error; err != nil {
 		return nil, err
 	}
 	return books, nil
@@ -53,13 +75,125 @@ func (r *BookRepo) FindByID(id uint) (*models.Book, error) {
 	return &book, nil
 }
 
-func (r *BookRepo) Update(book *models.Book) (*models.Book, error) {
-	if err := r.DB.Save(book).Error; err != nil {
+func (r *BookRepo) FindCategory(id uint) (*models.BookCategory, error) {
+	var bookCategory models.BookCategory
+	if err := r.DB.First(&bookCategory, id).Error; err != nil {
+		return nil, err
+	}
+	return &bookCategory, nil
+}
+
+func (r *BookRepo) Update(book *models.Book, actorID uint) (*models.Book, error) {
+	return r.save(book, actorID, models.RevisionActionUpdate)
+}
+
+func (r *BookRepo) Revert(book *models.Book, actorID uint) (*models.Book, error) {
+	return r.save(book, actorID, models.RevisionActionRevert)
+}
+
+// save writes the changed snapshot columns and the revision in one
+// transaction if the stored version still matches. Aggregates are not part
+// of the snapshot and are left alone.
+func (r *BookRepo) save(book *models.Book, actorID uint, action string) (*models.Book, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&previous, book.ID).Error; err != nil {
+			return err
+		}
+		if previous.Version != book.Version {
+			return models.ErrVersionConflict
+		}
+
+		before, after := models.NewBookSnapshot(&previous), models.NewBookSnapshot(book)
+		columns, err := revisionRepository.ChangedFields(before, after)
+		if err != nil || len(columns) == 0 {
+			return err
+		}
+		for _, column := range columns {
+			if column == "category_id" && book.CategoryID != nil {
+				if err := bookCategoryRepository.LockOwned(tx, *book.CategoryID, previous.UserID); err != nil {
+					return err
+				}
+			}
+		}
+
+		book.Version++
+		if err := tx.Model(book).Select(append(columns, "version")).Updates(book).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBook, book.ID, actorID, action, before, after)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return book, nil
 }
 
-func (r *BookRepo) Delete(id uint) error {
-	return r.DB.Delete(&models.Book{}, id).Error
+// Delete soft-deletes the book, checking version when it is non-zero.
+func (r *BookRepo) Delete(id, actorID uint, version int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id).Error; err != nil {
+			return err
+		}
+		if version != 0 && book.Version != version {
+			return models.ErrVersionConflict
+		}
+		if err := tx.Delete(&book).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBook, book.ID, actorID,
+			models.RevisionActionDelete, models.NewBookSnapshot(&book), nil)
+	})
+}
+
+// GetAvailability summarises the lendable copies of a book and, when none
+// are on the shelf, the earliest date one is due back.
+func (r *BookRepo) GetAvailability(bookID uint) (*models.BookAvailability, error) {
+	availability := &models.BookAvailability{}
+
+	err := r.DB.Model(&models.Copy{}).
+		Select("COUNT(*) AS total_copies, COUNT(*) FILTER (WHERE status = ?) AS available_copies", models.CopyStatusAvailable).
+		Where("book_id = ? AND status <> ?", bookID, models.CopyStatusWithdrawn).
+		Scan(availability).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if availability.TotalCopies > 0 && availability.AvailableCopies == 0 {
+		var loan models.Loan
+		err := r.DB.Where("book_id = ? AND status = ?", bookID, models.LoanStatusActive).
+			Order("due_at ASC").
+			Limit(1).
+			Find(&loan).Error
+		if err != nil {
+			return nil, err
+		}
+		if loan.ID != 0 {
+			availability.NextDueAt = &loan.DueAt
+		}
+	}
+
+	return availability, nil
+}
+
+// FavoritedBookIDs reports which of the given books the user has favorited,
+// in a single query.
+func (r *BookRepo) FavoritedBookIDs(userID uint, bookIDs []uint) (map[uint]bool, error) {
+	favorited := make(map[uint]bool)
+	if len(bookIDs) == 0 {
+		return favorited, nil
+	}
+
+	var ids []uint
+	err := r.DB.Model(&models.Favorite{}).
+		Where("user_id = ? AND book_id IN ?", userID, bookIDs).
+		Pluck("book_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		favorited[id] = true
+	}
+	return favorited, nil
 }
@@ -1,106 +1,263 @@
 package usecase
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+
 	"github.com/1rhino/clean_architecture/app/models"
+	bookCategoryRepository "github.com/1rhino/clean_architecture/app/modules/book_category/repositories"
 	repository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	revisionRepository "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound     = errors.New("book not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrNameRequired     = errors.New("name is required")
 )
 
 type UseCase interface {
 	CreateBook(ctx *gin.Context, bookInput *models.BookInput, userID uint) (*models.BookResponse, error)
-	GetAllBooks() ([]*models.BookResponse, error)
-	GetBooks(userID uint) ([]*models.BookResponse, error)
-	GetBook(bookID uint) (*models.BookResponse, error)
-	UpdateBook(ctx *gin.Context, bookInput *models.UpdateBook) (*models.BookResponse, error)
-	DeleteBook(bookID uint) error
+	GetAllBooks(userID uint, query *models.BookQuery) ([]*models.BookResponse, error)
+	GetBooks(userID uint, query *models.BookQuery) ([]*models.BookResponse, error)
+	GetBook(bookID, userID uint) (*models.BookResponse, error)
+	UpdateBook(ctx *gin.Context, bookInput *models.UpdateBook, userID uint) (*models.BookResponse, error)
+	RevertBook(bookID uint, revision int, userID uint, version int64) (*models.BookResponse, error)
+	DeleteBook(bookID, userID uint, version int64) error
 }
 
 type BookUseCase struct {
-	bookRepo repository.BookRepository
+	bookRepo      repository.BookRepository
+	revisionRepo  revisionRepository.RevisionRepository
+	uploadUseCase upload.UseCase
 }
 
-func NewBookUseCase(bookRepo repository.BookRepository) UseCase {
-	return &BookUseCase{bookRepo: bookRepo}
+func NewBookUseCase(bookRepo repository.BookRepository, revisionRepo revisionRepository.RevisionRepository, uploadUseCase upload.UseCase) UseCase {
+	return &BookUseCase{bookRepo: bookRepo, revisionRepo: revisionRepo, uploadUseCase: uploadUseCase}
 }
 
 func (u *BookUseCase) CreateBook(ctx *gin.Context, bookInput *models.BookInput, userID uint) (*models.BookResponse, error) {
+	if bookInput.CategoryID != nil && *bookInput.CategoryID == 0 {
+		bookInput.CategoryID = nil
+	}
+	if err := u.checkCategory(bookInput.CategoryID, userID); err != nil {
+		return nil, err
+	}
+
 	book := &models.Book{
-		Name:        bookInput.Name,
-		Image:       bookInput.Image,
-		Author:      bookInput.Author,
-		CategoryID:  bookInput.CategoryID,
-		UserID:      userID,
-		PublicDate:  bookInput.PublicDate,
-		Description: bookInput.Description,
+		Name:            bookInput.Name,
+		Image:           bookInput.Image,
+		ImageRenditions: bookInput.ImageRenditions,
+		Author:          bookInput.Author,
+		CategoryID:      bookInput.CategoryID,
+		UserID:          userID,
+		PublicDate:      bookInput.PublicDate,
+		Description:     bookInput.Description,
+		Tags:            models.NormalizeTags(bookInput.Tags),
 	}
 
-	createBook, err := u.bookRepo.Create(book)
+	createBook, err := u.bookRepo.Create(book, userID)
 	if err != nil {
-		return nil, err
+		return nil, categoryError(err)
+	}
+	if createBook.Image != "" {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBook, createBook.ID, createBook.Image)
 	}
 	return models.FilterBookRecord(createBook), nil
 }
 
-func (u *BookUseCase) GetAllBooks() ([]*models.BookResponse, error) {
-	bookCategories, err := u.bookRepo.FindAll()
+func (u *BookUseCase) GetAllBooks(userID uint, query *models.BookQuery) ([]*models.BookResponse, error) {
+	books, err := u.bookRepo.FindAll(query.OrderClause())
 	if err != nil {
 		return nil, err
 	}
+	return u.filterBooks(books, userID)
+}
 
-	var bookResponses []*models.BookResponse
-	for _, book := range bookCategories {
-		bookResponses = append(bookResponses, models.FilterBookRecord(book))
+func (u *BookUseCase) GetBooks(userID uint, query *models.BookQuery) ([]*models.BookResponse, error) {
+	books, err := u.bookRepo.FindByUserID(userID, query.OrderClause())
+	if err != nil {
+		return nil, err
 	}
-	return bookResponses, nil
+	return u.filterBooks(books, userID)
 }
 
-func (u *BookUseCase) GetBooks(userID uint) ([]*models.BookResponse, error) {
-	books, err := u.bookRepo.FindByUserID(userID)
+func (u *BookUseCase) GetBook(bookID, userID uint) (*models.BookResponse, error) {
+	book, err := u.bookRepo.FindByID(bookID)
 	if err != nil {
 		return nil, err
 	}
 
-	var bookResponses []*models.BookResponse
-	for _, book := range books {
-		bookResponses = append(bookResponses, models.FilterBookRecord(book))
+	availability, err := u.bookRepo.GetAvailability(bookID)
+	if err != nil {
+		return nil, err
 	}
-	return bookResponses, nil
+
+	bookResponses, err := u.filterBooks([]*models.Book{book}, userID)
+	if err != nil {
+		return nil, err
+	}
+	bookResponses[0].Availability = availability
+	return bookResponses[0], nil
 }
 
-func (u *BookUseCase) GetBook(bookID uint) (*models.BookResponse, error) {
-	book, err := u.bookRepo.FindByID(bookID)
+func (u *BookUseCase) UpdateBook(ctx *gin.Context, bookInput *models.UpdateBook, userID uint) (*models.BookResponse, error) {
+	book, err := u.findBook(bookInput.ID)
 	if err != nil {
 		return nil, err
 	}
-	return models.FilterBookRecord(book), nil
+	if bookInput.Version != 0 && bookInput.Version != book.Version {
+		return nil, models.ErrVersionConflict
+	}
+
+	categoryID, image := book.CategoryID, book.Image
+	if err := bookInput.Apply(book); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(book.Name) == "" {
+		return nil, ErrNameRequired
+	}
+	if !sameCategory(categoryID, book.CategoryID) {
+		if err := u.checkCategory(book.CategoryID, book.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	updatedBook, err := u.bookRepo.Update(book, userID)
+	if err != nil {
+		return nil, categoryError(err)
+	}
+	if updatedBook.Image != image {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBook, updatedBook.ID, updatedBook.Image)
+	}
+
+	return models.FilterBookRecord(updatedBook), nil
 }
 
-func (u *BookUseCase) UpdateBook(ctx *gin.Context, bookInput *models.UpdateBook) (*models.BookResponse, error) {
-	book, err := u.bookRepo.FindByID(bookInput.ID)
+// RevertBook saves the state recorded in the given revision as a new one.
+func (u *BookUseCase) RevertBook(bookID uint, revision int, userID uint, version int64) (*models.BookResponse, error) {
+	book, err := u.findBook(bookID)
 	if err != nil {
 		return nil, err
 	}
+	if version != 0 && version != book.Version {
+		return nil, models.ErrVersionConflict
+	}
 
-	book.Name = bookInput.Name
-	book.Author = bookInput.Author
-	book.PublicDate = bookInput.PublicDate
-	book.Description = bookInput.Description
-	if bookInput.Image != "" {
-		book.Image = bookInput.Image
+	bookRevision, err := u.revisionRepo.FindRevision(models.RevisionEntityBook, bookID, revision)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	updatedBook, err := u.bookRepo.Update(book)
+	var snapshot models.BookSnapshot
+	if err := json.Unmarshal([]byte(bookRevision.Snapshot), &snapshot); err != nil {
+		return nil, err
+	}
+	categoryID, image := book.CategoryID, book.Image
+	snapshot.Apply(book)
+	if !sameCategory(categoryID, book.CategoryID) {
+		if err := u.checkCategory(book.CategoryID, book.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	revertedBook, err := u.bookRepo.Revert(book, userID)
+	if err != nil {
+		return nil, categoryError(err)
+	}
+	if revertedBook.Image != image {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBook, revertedBook.ID, revertedBook.Image)
+	}
+
+	return models.FilterBookRecord(revertedBook), nil
+}
+
+// filterBooks builds the responses and flags the books the user has
+// favorited, looking them all up at once.
+func (u *BookUseCase) filterBooks(books []*models.Book, userID uint) ([]*models.BookResponse, error) {
+	bookIDs := make([]uint, 0, len(books))
+	for _, book := range books {
+		bookIDs = append(bookIDs, book.ID)
+	}
+
+	favorited, err := u.bookRepo.FavoritedBookIDs(userID, bookIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	return models.FilterBookRecord(updatedBook), nil
+	var bookResponses []*models.BookResponse
+	for _, book := range books {
+		bookResponse := models.FilterBookRecord(book)
+		bookResponse.IsFavorited = favorited[book.ID]
+		bookResponses = append(bookResponses, bookResponse)
+	}
+	return bookResponses, nil
 }
 
-func (u *BookUseCase) DeleteBook(bookID uint) error {
-	err := u.bookRepo.Delete(bookID)
+func (u *BookUseCase) DeleteBook(bookID, userID uint, version int64) error {
+	err := u.bookRepo.Delete(bookID, userID, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookNotFound
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// checkCategory makes sure a book is filed under an existing category owned
+// by the book's owner, which the repository checks again when it writes the
+// book. It is only checked when the category is set or changed, so books
+// filed before the check existed can still be edited.
+func (u *BookUseCase) checkCategory(categoryID *uint, userID uint) error {
+	if categoryID == nil {
+		return nil
+	}
+
+	bookCategory, err := u.bookRepo.FindCategory(*categoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.FieldError{Field: "category_id", Message: "book category does not exist"}
+	}
+	if err != nil {
+		return err
+	}
+	if bookCategory.UserID != userID {
+		return &models.FieldError{Field: "category_id", Message: "book category belongs to another user"}
+	}
+	return nil
+}
+
+// categoryError reports a category the repository refused to file the book
+// under, having been deleted or given away since checkCategory, the same
+// way checkCategory does.
+func categoryError(err error) error {
+	if errors.Is(err, bookCategoryRepository.ErrNotOwned) {
+		return &models.FieldError{Field: "category_id", Message: "book category does not exist"}
+	}
+	return err
+}
+
+func sameCategory(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (u *BookUseCase) findBook(bookID uint) (*models.Book, error) {
+	book, err := u.bookRepo.FindByID(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
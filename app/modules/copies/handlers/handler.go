@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	copyUseCase "github.com/1rhino/clean_architecture/app/modules/copies/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type CopyHandlers struct {
+	copyUseCase copyUseCase.UseCase
+}
+
+func NewCopyHandlers(copyUseCase copyUseCase.UseCase) *CopyHandlers {
+	return &CopyHandlers{copyUseCase: copyUseCase}
+}
+
+// create a new copy of a book
+func (h *CopyHandlers) CreateCopy(c *gin.Context) {
+	var copyInput models.CopyInput
+
+	if err := c.ShouldBind(&copyInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdCopy, err := h.copyUseCase.CreateCopy(&copyInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": createdCopy})
+}
+
+// get list copies of a book
+func (h *CopyHandlers) GetBookCopies(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	copies, err := h.copyUseCase.GetBookCopies(uint(bookID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": copies})
+}
+
+// get copy detail
+func (h *CopyHandlers) GetCopyDetail(c *gin.Context) {
+	copyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid copy ID"})
+		return
+	}
+
+	getCopy, err := h.copyUseCase.GetCopy(uint(copyID))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, getCopy)
+}
+
+// update copy
+func (h *CopyHandlers) UpdateCopy(c *gin.Context) {
+	var copyInput models.UpdateCopy
+
+	if err := c.ShouldBind(&copyInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	copyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid copy ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	copyInput.ID = uint(copyID)
+	updatedCopy, err := h.copyUseCase.UpdateCopy(&copyInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": updatedCopy})
+}
+
+// delete copy
+func (h *CopyHandlers) DeleteCopy(c *gin.Context) {
+	copyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid copy ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.copyUseCase.DeleteCopy(uint(copyID), userID)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to delete copy"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Copy deleted successfully"})
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, copyUseCase.ErrBookNotFound), errors.Is(err, copyUseCase.ErrCopyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, copyUseCase.ErrCopyForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, copyUseCase.ErrBarcodeTaken),
+		errors.Is(err, copyUseCase.ErrCopyOnLoan),
+		errors.Is(err, copyUseCase.ErrInvalidCopyState):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
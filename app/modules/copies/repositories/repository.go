@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+)
+
+type CopyRepository interface {
+	Create(bookCopy *models.Copy) (*models.Copy, error)
+	FindByID(id uint) (*models.Copy, error)
+	FindByBarcode(barcode string) (*models.Copy, error)
+	FindByBookID(bookID uint) ([]*models.Copy, error)
+	Update(bookCopy *models.Copy) (*models.Copy, error)
+	Delete(id uint) error
+}
+
+type CopyRepo struct {
+	DB *gorm.DB
+}
+
+func NewCopyRepo(db *gorm.DB) CopyRepository {
+	return &CopyRepo{DB: db}
+}
+
+func (r *CopyRepo) Create(bookCopy *models.Copy) (*models.Copy, error) {
+	if err := r.DB.Omit("Book").Create(bookCopy).Error; err != nil {
+		return nil, err
+	}
+	return bookCopy, nil
+}
+
+func (r *CopyRepo) FindByID(id uint) (*models.Copy, error) {
+	var bookCopy models.Copy
+	if err := r.DB.First(&bookCopy, id).Error; err != nil {
+		return nil, err
+	}
+	return &bookCopy, nil
+}
+
+func (r *CopyRepo) FindByBarcode(barcode string) (*models.Copy, error) {
+	var bookCopy models.Copy
+	if err := r.DB.Where("barcode = ?", barcode).First(&bookCopy).Error; err != nil {
+		return nil, err
+	}
+	return &bookCopy, nil
+}
+
+func (r *CopyRepo) FindByBookID(bookID uint) ([]*models.Copy, error) {
+	var copies []*models.Copy
+	if err := r.DB.Where("book_id = ?", bookID).Order("id ASC").Find(&copies).Error; err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+func (r *CopyRepo) Update(bookCopy *models.Copy) (*models.Copy, error) {
+	if err := r.DB.Omit("Book").Save(bookCopy).Error; err != nil {
+		return nil, err
+	}
+	return bookCopy, nil
+}
+
+func (r *CopyRepo) Delete(id uint) error {
+	return r.DB.Delete(&models.Copy{}, id).Error
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/copies/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound     = errors.New("book not found")
+	ErrCopyNotFound     = errors.New("copy not found")
+	ErrCopyForbidden    = errors.New("only the owner of the book can manage its copies")
+	ErrBarcodeTaken     = errors.New("barcode is already in use")
+	ErrCopyOnLoan       = errors.New("copy is on loan")
+	ErrInvalidCopyState = errors.New("copy status can't be changed while it is on loan")
+)
+
+type UseCase interface {
+	CreateCopy(copyInput *models.CopyInput, userID uint) (*models.CopyResponse, error)
+	GetBookCopies(bookID uint) ([]*models.CopyResponse, error)
+	GetCopy(copyID uint) (*models.CopyResponse, error)
+	UpdateCopy(copyInput *models.UpdateCopy, userID uint) (*models.CopyResponse, error)
+	DeleteCopy(copyID, userID uint) error
+}
+
+// CopyUseCase manages the physical holdings of a book. The user who owns the
+// book record acts as the library staff for its copies.
+type CopyUseCase struct {
+	copyRepo repository.CopyRepository
+	bookRepo bookRepository.BookRepository
+}
+
+func NewCopyUseCase(copyRepo repository.CopyRepository, bookRepo bookRepository.BookRepository) UseCase {
+	return &CopyUseCase{copyRepo: copyRepo, bookRepo: bookRepo}
+}
+
+func (u *CopyUseCase) CreateCopy(copyInput *models.CopyInput, userID uint) (*models.CopyResponse, error) {
+	if err := u.checkBookOwner(copyInput.BookID, userID); err != nil {
+		return nil, err
+	}
+	if err := u.checkBarcode(copyInput.Barcode, 0); err != nil {
+		return nil, err
+	}
+
+	bookCopy := &models.Copy{
+		BookID:    copyInput.BookID,
+		Barcode:   copyInput.Barcode,
+		Condition: copyInput.Condition,
+		Location:  copyInput.Location,
+		Status:    models.CopyStatusAvailable,
+	}
+
+	createdCopy, err := u.copyRepo.Create(bookCopy)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterCopyRecord(createdCopy), nil
+}
+
+func (u *CopyUseCase) GetBookCopies(bookID uint) ([]*models.CopyResponse, error) {
+	copies, err := u.copyRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	var copyResponses []*models.CopyResponse
+	for _, bookCopy := range copies {
+		copyResponses = append(copyResponses, models.FilterCopyRecord(bookCopy))
+	}
+	return copyResponses, nil
+}
+
+func (u *CopyUseCase) GetCopy(copyID uint) (*models.CopyResponse, error) {
+	bookCopy, err := u.findCopy(copyID)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterCopyRecord(bookCopy), nil
+}
+
+func (u *CopyUseCase) UpdateCopy(copyInput *models.UpdateCopy, userID uint) (*models.CopyResponse, error) {
+	bookCopy, err := u.findCopy(copyInput.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkBookOwner(bookCopy.BookID, userID); err != nil {
+		return nil, err
+	}
+
+	if copyInput.Barcode != "" && copyInput.Barcode != bookCopy.Barcode {
+		if err := u.checkBarcode(copyInput.Barcode, bookCopy.ID); err != nil {
+			return nil, err
+		}
+		bookCopy.Barcode = copyInput.Barcode
+	}
+	if copyInput.Condition != "" {
+		bookCopy.Condition = copyInput.Condition
+	}
+	if copyInput.Location != "" {
+		bookCopy.Location = copyInput.Location
+	}
+	if copyInput.Status != "" && copyInput.Status != bookCopy.Status {
+		if bookCopy.Status != models.CopyStatusAvailable && bookCopy.Status != models.CopyStatusWithdrawn {
+			return nil, ErrInvalidCopyState
+		}
+		bookCopy.Status = copyInput.Status
+	}
+
+	updatedCopy, err := u.copyRepo.Update(bookCopy)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterCopyRecord(updatedCopy), nil
+}
+
+func (u *CopyUseCase) DeleteCopy(copyID, userID uint) error {
+	bookCopy, err := u.findCopy(copyID)
+	if err != nil {
+		return err
+	}
+	if err := u.checkBookOwner(bookCopy.BookID, userID); err != nil {
+		return err
+	}
+	if bookCopy.Status == models.CopyStatusOnLoan {
+		return ErrCopyOnLoan
+	}
+	return u.copyRepo.Delete(bookCopy.ID)
+}
+
+func (u *CopyUseCase) findCopy(copyID uint) (*models.Copy, error) {
+	bookCopy, err := u.copyRepo.FindByID(copyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCopyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bookCopy, nil
+}
+
+func (u *CopyUseCase) checkBookOwner(bookID, userID uint) error {
+	book, err := u.bookRepo.FindByID(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if book.UserID != userID {
+		return ErrCopyForbidden
+	}
+	return nil
+}
+
+func (u *CopyUseCase) checkBarcode(barcode string, copyID uint) error {
+	existing, err := u.copyRepo.FindByBarcode(barcode)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != copyID {
+		return ErrBarcodeTaken
+	}
+	return nil
+}
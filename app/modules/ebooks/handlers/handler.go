@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/1rhino/clean_architecture/app/ebook"
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	ebookUseCase "github.com/1rhino/clean_architecture/app/modules/ebooks/usecase"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type EbookHandlers struct {
+	ebookUseCase ebookUseCase.UseCase
+}
+
+func NewEbookHandlers(ebookUseCase ebookUseCase.UseCase) *EbookHandlers {
+	return &EbookHandlers{ebookUseCase: ebookUseCase}
+}
+
+// attach an EPUB or PDF file to a book
+func (h *EbookHandlers) AttachEbook(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	attached, err := h.ebookUseCase.AttachEbook(c.Request.Context(), uint(bookID), userID, version, file)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to upload e-book"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.SetETag(c, attached.Book.Version)
+	c.JSON(http.StatusCreated, gin.H{"data": attached})
+}
+
+// get the e-books of a book
+func (h *EbookHandlers) GetBookEbooks(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	ebooks, err := h.ebookUseCase.GetBookEbooks(uint(bookID))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ebooks})
+}
+
+// download an e-book, in full or in ranges
+func (h *EbookHandlers) DownloadEbook(c *gin.Context) {
+	ebookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid e-book ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resumed downloads and readers fetching parts of the file ask for
+	// ranges past the start, which aren't downloads of their own.
+	rangeHeader := c.GetHeader("Range")
+	counted := c.Request.Method == http.MethodGet && (rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-"))
+
+	file, content, err := h.ebookUseCase.OpenEbook(c.Request.Context(), uint(ebookID), userID, counted)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	c.Header("Cache-Control", "private")
+	http.ServeContent(c.Writer, c.Request, file.Filename, file.UpdatedAt, content)
+}
+
+// delete an e-book of a book
+func (h *EbookHandlers) DeleteEbook(c *gin.Context) {
+	ebookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid e-book ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.ebookUseCase.DeleteEbook(uint(ebookID), userID); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "E-book deleted successfully"})
+}
+
+// get paginated e-books the user downloaded, with download counts
+func (h *EbookHandlers) GetDownloads(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query models.EbookQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Normalize()
+
+	downloads, meta, err := h.ebookUseCase.GetDownloads(userID, &query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": downloads, "meta": meta})
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, ebookUseCase.ErrBookNotFound), errors.Is(err, ebookUseCase.ErrEbookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ebookUseCase.ErrEbookForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ebookUseCase.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ebook.ErrUnsupportedFormat), errors.Is(err, ebook.ErrMalformed):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, upload.ErrInfected), errors.Is(err, upload.ErrUnscannable):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EbookRepository interface {
+	Save(ebook *models.Ebook) (*models.Ebook, error)
+	FindByID(id uint) (*models.Ebook, error)
+	FindByBookID(bookID uint) ([]*models.Ebook, error)
+	Delete(id uint) error
+	RecordDownload(ebookID, userID uint, at time.Time) error
+	FindDownloadsByUserID(userID uint, limit, offset int) ([]*models.EbookDownload, error)
+	CountDownloadsByUserID(userID uint) (int64, error)
+}
+
+type EbookRepo struct {
+	DB *gorm.DB
+}
+
+func NewEbookRepo(db *gorm.DB) EbookRepository {
+	return &EbookRepo{DB: db}
+}
+
+// Save stores the e-book in place of the book's file in the same format,
+// if it has one. Download counts carry over to the new file.
+func (r *EbookRepo) Save(ebook *models.Ebook) (*models.Ebook, error) {
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "book_id"}, {Name: "format"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"key", "content_type", "size", "filename", "title", "author", "language", "user_id", "updated_at",
+		}),
+	}).Create(ebook).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.FindByID(ebook.ID)
+}
+
+func (r *EbookRepo) FindByID(id uint) (*models.Ebook, error) {
+	var ebook models.Ebook
+	if err := r.DB.First(&ebook, id).Error; err != nil {
+		return nil, err
+	}
+	return &ebook, nil
+}
+
+func (r *EbookRepo) FindByBookID(bookID uint) ([]*models.Ebook, error) {
+	var ebooks []*models.Ebook
+	if err := r.DB.Where("book_id = ?", bookID).Order("format").Find(&ebooks).Error; err != nil {
+		return nil, err
+	}
+	return ebooks, nil
+}
+
+// Delete removes the e-book along with its download counts.
+func (r *EbookRepo) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ebook_id = ?", id).Delete(&models.EbookDownload{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Ebook{}, id).Error
+	})
+}
+
+// RecordDownload counts one more download of the e-book by the user.
+func (r *EbookRepo) RecordDownload(ebookID, userID uint, at time.Time) error {
+	download := &models.EbookDownload{
+		EbookID:          ebookID,
+		UserID:           userID,
+		Count:            1,
+		LastDownloadedAt: at,
+	}
+	return r.DB.Omit("Ebook").Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "ebook_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":              gorm.Expr("ebook_downloads.count + 1"),
+			"last_downloaded_at": at,
+		}),
+	}).Create(download).Error
+}
+
+// FindDownloadsByUserID returns the e-books the user downloaded, most
+// recently downloaded first.
+func (r *EbookRepo) FindDownloadsByUserID(userID uint, limit, offset int) ([]*models.EbookDownload, error) {
+	var downloads []*models.EbookDownload
+	err := r.DB.Preload("Ebook").
+		Where("user_id = ?", userID).
+		Order("last_downloaded_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&downloads).Error
+	if err != nil {
+		return nil, err
+	}
+	return downloads, nil
+}
+
+func (r *EbookRepo) CountDownloadsByUserID(userID uint) (int64, error) {
+	var count int64
+	if err := r.DB.Model(&models.EbookDownload{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,284 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/ebook"
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/ebooks/repositories"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
+	"github.com/1rhino/clean_architecture/app/storage"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+// DownloadRoute is where e-books are downloaded from, followed by their ID.
+const DownloadRoute = "/api/v1/ebooks/download"
+
+var (
+	ErrBookNotFound   = errors.New("book not found")
+	ErrEbookNotFound  = errors.New("e-book not found")
+	ErrEbookForbidden = errors.New("only the owner of the book can manage its e-books")
+	ErrFileTooLarge   = errors.New("file is too large")
+)
+
+type UseCase interface {
+	AttachEbook(ctx context.Context, bookID, userID uint, version int64, file *multipart.FileHeader) (*models.AttachEbookResponse, error)
+	GetBookEbooks(bookID uint) ([]*models.EbookResponse, error)
+	OpenEbook(ctx context.Context, ebookID, userID uint, counted bool) (*models.Ebook, *storage.RangeReader, error)
+	DeleteEbook(ebookID, userID uint) error
+	GetDownloads(userID uint, query *models.EbookQuery) ([]*models.EbookDownloadResponse, *models.PaginationMeta, error)
+}
+
+type EbookUseCase struct {
+	ebookRepo     repository.EbookRepository
+	bookRepo      bookRepository.BookRepository
+	uploadUseCase upload.UseCase
+	store         storage.Store
+	config        config.EbooksConfig
+	clock         clock.Clock
+}
+
+func NewEbookUseCase(ebookRepo repository.EbookRepository, bookRepo bookRepository.BookRepository, uploadUseCase upload.UseCase, store storage.Store, cfg config.EbooksConfig, clk clock.Clock) UseCase {
+	return &EbookUseCase{ebookRepo: ebookRepo, bookRepo: bookRepo, uploadUseCase: uploadUseCase, store: store, config: cfg, clock: clk}
+}
+
+// AttachEbook stores an EPUB or PDF as the book's e-book in that format,
+// replacing the one it had. The author and image the book is missing are
+// filled in from the EPUB's metadata and cover.
+func (u *EbookUseCase) AttachEbook(ctx context.Context, bookID, userID uint, version int64, file *multipart.FileHeader) (*models.AttachEbookResponse, error) {
+	book, err := u.findBook(bookID)
+	if err != nil {
+		return nil, err
+	}
+	if book.UserID != userID {
+		return nil, ErrEbookForbidden
+	}
+	if version != 0 && version != book.Version {
+		return nil, models.ErrVersionConflict
+	}
+
+	data, err := u.read(file)
+	if err != nil {
+		return nil, err
+	}
+	format, err := ebook.Detect(data)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &ebook.Metadata{}
+	if format == ebook.FormatEPUB {
+		if metadata, err = ebook.ReadMetadata(data, u.config.MaxBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	contentType := ebook.ContentTypes[format]
+	stored, err := u.uploadUseCase.StoreFile(ctx, models.UploadEntityBook, userID, data, contentType, file.Filename)
+	if err != nil {
+		return nil, err
+	}
+	savedEbook, err := u.ebookRepo.Save(&models.Ebook{
+		BookID:      book.ID,
+		Format:      format,
+		Key:         stored.Key,
+		ContentType: contentType,
+		Size:        stored.Size,
+		Filename:    file.Filename,
+		Title:       metadata.Title,
+		Author:      metadata.Author,
+		Language:    metadata.Language,
+		UserID:      userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.uploadUseCase.ReferenceFile(models.UploadEntityBook, book.ID, models.EbookUploadField(format), stored.Key)
+
+	book, err = u.prefill(ctx, book, metadata, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AttachEbookResponse{
+		Ebook: u.response(savedEbook),
+		Book:  models.FilterBookRecord(book),
+	}, nil
+}
+
+// prefill fills in the author and image of the book from the metadata of
+// its e-book when it has none. Covers that aren't acceptable images are
+// skipped, since the e-book is already attached.
+func (u *EbookUseCase) prefill(ctx context.Context, book *models.Book, metadata *ebook.Metadata, userID uint) (*models.Book, error) {
+	changed := false
+	if book.Author == "" && metadata.Author != "" {
+		book.Author = metadata.Author
+		changed = true
+	}
+	if book.Image == "" && metadata.Cover != nil {
+		cover, err := u.uploadUseCase.SaveImage(ctx, models.UploadEntityBook, userID, metadata.Cover.Data, metadata.Cover.Name)
+		if err != nil {
+			log.Printf("failed to save cover of book %d: %v", book.ID, err)
+		} else {
+			book.Image = cover.URL
+			book.ImageRenditions = cover.Renditions
+			changed = true
+		}
+	}
+	if !changed {
+		return book, nil
+	}
+
+	updatedBook, err := u.bookRepo.Update(book, userID)
+	if err != nil {
+		return nil, err
+	}
+	if updatedBook.Image != "" {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityBook, updatedBook.ID, updatedBook.Image)
+	}
+	return updatedBook, nil
+}
+
+func (u *EbookUseCase) GetBookEbooks(bookID uint) ([]*models.EbookResponse, error) {
+	if _, err := u.findBook(bookID); err != nil {
+		return nil, err
+	}
+
+	ebooks, err := u.ebookRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	ebookResponses := []*models.EbookResponse{}
+	for _, ebook := range ebooks {
+		ebookResponses = append(ebookResponses, u.response(ebook))
+	}
+	return ebookResponses, nil
+}
+
+// OpenEbook opens an e-book of a book that isn't in the trash for reading
+// from any offset. Counted downloads are added to the user's count, which
+// callers use to leave out requests resuming a download.
+func (u *EbookUseCase) OpenEbook(ctx context.Context, ebookID, userID uint, counted bool) (*models.Ebook, *storage.RangeReader, error) {
+	ebook, err := u.findEbook(ebookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := u.findBook(ebook.BookID); errors.Is(err, ErrBookNotFound) {
+		return nil, nil, ErrEbookNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	if counted {
+		if err := u.ebookRepo.RecordDownload(ebook.ID, userID, u.clock.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ebook, storage.NewRangeReader(ctx, u.store, ebook.Key, ebook.Size), nil
+}
+
+// DeleteEbook removes the e-book and its download counts. Its file is
+// deleted once nothing else uses it.
+func (u *EbookUseCase) DeleteEbook(ebookID, userID uint) error {
+	ebook, err := u.findEbook(ebookID)
+	if err != nil {
+		return err
+	}
+	book, err := u.findBook(ebook.BookID)
+	if err != nil {
+		return err
+	}
+	if book.UserID != userID {
+		return ErrEbookForbidden
+	}
+
+	if err := u.ebookRepo.Delete(ebook.ID); err != nil {
+		return err
+	}
+	u.uploadUseCase.ReferenceFile(models.UploadEntityBook, ebook.BookID, models.EbookUploadField(ebook.Format), "")
+	return nil
+}
+
+// GetDownloads lists the e-books the user downloaded and how many times.
+func (u *EbookUseCase) GetDownloads(userID uint, query *models.EbookQuery) ([]*models.EbookDownloadResponse, *models.PaginationMeta, error) {
+	total, err := u.ebookRepo.CountDownloadsByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	downloads, err := u.ebookRepo.FindDownloadsByUserID(userID, query.PerPage, query.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	downloadResponses := []*models.EbookDownloadResponse{}
+	for _, download := range downloads {
+		downloadResponses = append(downloadResponses, models.FilterEbookDownloadRecord(download, downloadURL(download.EbookID)))
+	}
+
+	meta := models.NewPaginationMeta(&query.PaginationInput, total)
+	return downloadResponses, &meta, nil
+}
+
+// read reads the uploaded file, failing when it exceeds MaxBytes.
+func (u *EbookUseCase) read(file *multipart.FileHeader) ([]byte, error) {
+	if file.Size > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, errors.New("failed to open file")
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, u.config.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	return data, nil
+}
+
+func (u *EbookUseCase) errFileTooLarge() error {
+	return fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, u.config.MaxBytes)
+}
+
+func (u *EbookUseCase) response(ebook *models.Ebook) *models.EbookResponse {
+	return models.FilterEbookRecord(ebook, downloadURL(ebook.ID))
+}
+
+func downloadURL(ebookID uint) string {
+	return fmt.Sprintf("%s/%d", DownloadRoute, ebookID)
+}
+
+func (u *EbookUseCase) findBook(bookID uint) (*models.Book, error) {
+	book, err := u.bookRepo.FindByID(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+func (u *EbookUseCase) findEbook(ebookID uint) (*models.Ebook, error) {
+	ebook, err := u.ebookRepo.FindByID(ebookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEbookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ebook, nil
+}
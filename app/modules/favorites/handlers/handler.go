@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	favorite "github.com/1rhino/clean_architecture/app/modules/favorites/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type FavoriteHandlers struct {
+	favoriteUseCase favorite.UseCase
+}
+
+func NewFavoriteHandlers(favoriteUseCase favorite.UseCase) *FavoriteHandlers {
+	return &FavoriteHandlers{favoriteUseCase: favoriteUseCase}
+}
+
+// add a book to the user's favorites
+func (h *FavoriteHandlers) AddFavorite(c *gin.Context) {
+	var favoriteInput models.FavoriteInput
+
+	if err := c.ShouldBind(&favoriteInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdFavorite, err := h.favoriteUseCase.AddFavorite(&favoriteInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": createdFavorite})
+}
+
+// get paginated favorites of the user
+func (h *FavoriteHandlers) GetFavorites(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query models.FavoriteQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Normalize()
+
+	favorites, meta, err := h.favoriteUseCase.GetFavorites(userID, &query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": favorites, "meta": meta})
+}
+
+// remove a book from the user's favorites
+func (h *FavoriteHandlers) RemoveFavorite(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.favoriteUseCase.RemoveFavorite(uint(bookID), userID); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Favorite removed successfully"})
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, favorite.ErrBookNotFound), errors.Is(err, favorite.ErrFavoriteNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, favorite.ErrFavoriteExists):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
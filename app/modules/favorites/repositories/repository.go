@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FavoriteRepository interface {
+	Create(favorite *models.Favorite) (*models.Favorite, error)
+	FindByUserID(userID uint, limit, offset int) ([]*models.Favorite, error)
+	CountByUserID(userID uint) (int64, error)
+	Delete(userID, bookID uint) error
+}
+
+type FavoriteRepo struct {
+	DB *gorm.DB
+}
+
+func NewFavoriteRepo(db *gorm.DB) FavoriteRepository {
+	return &FavoriteRepo{DB: db}
+}
+
+// Create inserts the favorite and bumps the book's favorites count in the
+// same transaction. Favoriting a book twice returns gorm.ErrDuplicatedKey.
+func (r *FavoriteRepo) Create(favorite *models.Favorite) (*models.Favorite, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Book").Create(favorite).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Book{}).Where("id = ?", favorite.BookID).
+			UpdateColumn("favorites_count", gorm.Expr("favorites_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return favorite, nil
+}
+
+// FindByUserID returns the user's favorites with their books, most recent
+// first.
+func (r *FavoriteRepo) FindByUserID(userID uint, limit, offset int) ([]*models.Favorite, error) {
+	var favorites []*models.Favorite
+	err := r.DB.Preload("Book").
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&favorites).Error
+	if err != nil {
+		return nil, err
+	}
+	return favorites, nil
+}
+
+func (r *FavoriteRepo) CountByUserID(userID uint) (int64, error) {
+	var count int64
+	if err := r.DB.Model(&models.Favorite{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Delete removes the favorite and takes it off the book's favorites count.
+// It returns gorm.ErrRecordNotFound when the book wasn't favorited.
+func (r *FavoriteRepo) Delete(userID, bookID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var favorite models.Favorite
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND book_id = ?", userID, bookID).
+			First(&favorite).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&favorite).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Book{}).Where("id = ?", bookID).
+			UpdateColumn("favorites_count", gorm.Expr("favorites_count - 1")).Error
+	})
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/favorites/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound     = errors.New("book not found")
+	ErrFavoriteExists   = errors.New("you have already favorited this book")
+	ErrFavoriteNotFound = errors.New("book is not in your favorites")
+)
+
+type UseCase interface {
+	AddFavorite(favoriteInput *models.FavoriteInput, userID uint) (*models.FavoriteResponse, error)
+	GetFavorites(userID uint, query *models.FavoriteQuery) ([]*models.FavoriteResponse, *models.PaginationMeta, error)
+	RemoveFavorite(bookID, userID uint) error
+}
+
+type FavoriteUseCase struct {
+	favoriteRepo repository.FavoriteRepository
+	bookRepo     bookRepository.BookRepository
+}
+
+func NewFavoriteUseCase(favoriteRepo repository.FavoriteRepository, bookRepo bookRepository.BookRepository) UseCase {
+	return &FavoriteUseCase{favoriteRepo: favoriteRepo, bookRepo: bookRepo}
+}
+
+func (u *FavoriteUseCase) AddFavorite(favoriteInput *models.FavoriteInput, userID uint) (*models.FavoriteResponse, error) {
+	book, err := u.bookRepo.FindByID(favoriteInput.BookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	favorite := &models.Favorite{
+		UserID: userID,
+		BookID: book.ID,
+	}
+
+	createdFavorite, err := u.favoriteRepo.Create(favorite)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrFavoriteExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	book.FavoritesCount++
+	createdFavorite.Book = *book
+	return models.FilterFavoriteRecord(createdFavorite), nil
+}
+
+func (u *FavoriteUseCase) GetFavorites(userID uint, query *models.FavoriteQuery) ([]*models.FavoriteResponse, *models.PaginationMeta, error) {
+	total, err := u.favoriteRepo.CountByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	favorites, err := u.favoriteRepo.FindByUserID(userID, query.PerPage, query.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	favoriteResponses := []*models.FavoriteResponse{}
+	for _, favorite := range favorites {
+		favoriteResponses = append(favoriteResponses, models.FilterFavoriteRecord(favorite))
+	}
+
+	meta := models.NewPaginationMeta(&query.PaginationInput, total)
+	return favoriteResponses, &meta, nil
+}
+
+func (u *FavoriteUseCase) RemoveFavorite(bookID, userID uint) error {
+	err := u.favoriteRepo.Delete(userID, bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrFavoriteNotFound
+	}
+	return err
+}
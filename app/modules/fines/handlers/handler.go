@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	fine "github.com/1rhino/clean_architecture/app/modules/fines/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type FineHandlers struct {
+	fineUseCase fine.UseCase
+}
+
+func NewFineHandlers(fineUseCase fine.UseCase) *FineHandlers {
+	return &FineHandlers{fineUseCase: fineUseCase}
+}
+
+// get the user's fines and outstanding balance
+func (h *FineHandlers) GetFines(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.fineUseCase.GetFines(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+// get fine detail
+func (h *FineHandlers) GetFineDetail(c *gin.Context) {
+	userID, fineID, ok := userAndFineID(c)
+	if !ok {
+		return
+	}
+
+	getFine, err := h.fineUseCase.GetFine(fineID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, getFine)
+}
+
+// record a payment against a fine
+func (h *FineHandlers) RecordPayment(c *gin.Context) {
+	h.recordTransaction(c, models.FineTransactionPayment)
+}
+
+// waive a fine
+func (h *FineHandlers) WaiveFine(c *gin.Context) {
+	h.recordTransaction(c, models.FineTransactionWaiver)
+}
+
+func (h *FineHandlers) recordTransaction(c *gin.Context, kind string) {
+	var transactionInput models.FineTransactionInput
+
+	if err := c.ShouldBind(&transactionInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, fineID, ok := userAndFineID(c)
+	if !ok {
+		return
+	}
+
+	updatedFine, err := h.fineUseCase.RecordTransaction(fineID, userID, kind, &transactionInput)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": updatedFine})
+}
+
+func userAndFineID(c *gin.Context) (uint, uint, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	fineID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fine ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(fineID), true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, fine.ErrFineNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, fine.ErrFineForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, fine.ErrFineSettled):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
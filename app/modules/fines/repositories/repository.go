@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FineRepository interface {
+	FindOverdueLoans(now time.Time, afterID uint, limit int) ([]*models.Loan, error)
+	Accrue(loan *models.Loan, amountCents int64, now time.Time) error
+	FindByID(id uint) (*models.Fine, error)
+	FindByUserID(userID uint) ([]*models.Fine, error)
+	OutstandingBalance(userID uint) (int64, error)
+	RecordTransaction(fineID uint, transaction *models.FineTransaction) (*models.Fine, error)
+}
+
+type FineRepo struct {
+	DB *gorm.DB
+}
+
+func NewFineRepo(db *gorm.DB) FineRepository {
+	return &FineRepo{DB: db}
+}
+
+// FindOverdueLoans pages through active loans past their due date in id
+// order, starting after afterID.
+func (r *FineRepo) FindOverdueLoans(now time.Time, afterID uint, limit int) ([]*models.Loan, error) {
+	var loans []*models.Loan
+	err := r.DB.Where("status = ? AND due_at < ? AND id > ?", models.LoanStatusActive, now, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&loans).Error
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+func (r *FineRepo) Accrue(loan *models.Loan, amountCents int64, now time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return AccrueFine(tx, loan, amountCents, now)
+	})
+}
+
+func (r *FineRepo) FindByID(id uint) (*models.Fine, error) {
+	var fine models.Fine
+	if err := r.DB.First(&fine, id).Error; err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+func (r *FineRepo) FindByUserID(userID uint) ([]*models.Fine, error) {
+	var fines []*models.Fine
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&fines).Error; err != nil {
+		return nil, err
+	}
+	return fines, nil
+}
+
+func (r *FineRepo) OutstandingBalance(userID uint) (int64, error) {
+	return Balance(r.DB, userID)
+}
+
+// Balance sums what the user still owes on open fines. Checkout calls it
+// inside its own transaction.
+func Balance(db *gorm.DB, userID uint) (int64, error) {
+	var balance int64
+	err := db.Model(&models.Fine{}).
+		Select("COALESCE(SUM(accrued_cents - paid_cents - waived_cents), 0)").
+		Where("user_id = ? AND status = ?", userID, models.FineStatusOpen).
+		Scan(&balance).Error
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// RecordTransaction applies a payment or waiver to an open fine, settling it
+// once nothing is left to pay. A zero amount covers the whole balance and
+// larger amounts are capped at it. It returns gorm.ErrRecordNotFound when
+// the fine is not open.
+func (r *FineRepo) RecordTransaction(fineID uint, transaction *models.FineTransaction) (*models.Fine, error) {
+	var fine models.Fine
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", fineID, models.FineStatusOpen).
+			First(&fine).Error
+		if err != nil {
+			return err
+		}
+
+		balance := fine.BalanceCents()
+		if transaction.AmountCents == 0 || transaction.AmountCents > balance {
+			transaction.AmountCents = balance
+		}
+
+		if transaction.Kind == models.FineTransactionWaiver {
+			fine.WaivedCents += transaction.AmountCents
+		} else {
+			fine.PaidCents += transaction.AmountCents
+		}
+		if fine.BalanceCents() <= 0 {
+			fine.Status = models.FineStatusSettled
+		}
+
+		if err := tx.Omit("Loan").Save(&fine).Error; err != nil {
+			return err
+		}
+
+		transaction.FineID = fine.ID
+		return tx.Create(transaction).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+// AccrueFine marks the loan as overdue and raises its fine to amountCents.
+// Fines never go down, so running it again for the same day is harmless. It
+// must run inside a transaction.
+func AccrueFine(tx *gorm.DB, loan *models.Loan, amountCents int64, now time.Time) error {
+	err := tx.Model(&models.Loan{}).
+		Where("id = ? AND overdue_at IS NULL", loan.ID).
+		UpdateColumn("overdue_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	fine := &models.Fine{
+		LoanID:       loan.ID,
+		UserID:       loan.UserID,
+		BookID:       loan.BookID,
+		AccruedCents: amountCents,
+		Status:       models.FineStatusOpen,
+	}
+	return tx.Omit("Loan").Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "loan_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"accrued_cents": gorm.Expr("GREATEST(fines.accrued_cents, excluded.accrued_cents)"),
+			"status": gorm.Expr("CASE WHEN GREATEST(fines.accrued_cents, excluded.accrued_cents) > fines.paid_cents + fines.waived_cents THEN ? ELSE ? END",
+				models.FineStatusOpen, models.FineStatusSettled),
+			"updated_at": now,
+		}),
+	}).Create(fine).Error
+}
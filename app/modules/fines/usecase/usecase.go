@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/fines/repositories"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFineNotFound  = errors.New("fine not found")
+	ErrFineForbidden = errors.New("only the owner of the book can record payments or waivers")
+	ErrFineSettled   = errors.New("fine is already settled")
+)
+
+const overdueScanBatch = 200
+
+type UseCase interface {
+	FineFor(loan *models.Loan, at time.Time) int64
+	ScanOverdue(ctx context.Context) error
+	OutstandingBalance(userID uint) (int64, error)
+	GetFines(userID uint) (*models.FineSummary, error)
+	GetFine(fineID, userID uint) (*models.FineResponse, error)
+	RecordTransaction(fineID, staffID uint, kind string, transactionInput *models.FineTransactionInput) (*models.FineResponse, error)
+}
+
+// FineUseCase charges a fixed amount per started day past the due date, up
+// to a cap. The owner of the book acts as the library staff for its fines.
+type FineUseCase struct {
+	fineRepo repository.FineRepository
+	bookRepo bookRepository.BookRepository
+	config   config.FinesConfig
+	clock    clock.Clock
+}
+
+func NewFineUseCase(fineRepo repository.FineRepository, bookRepo bookRepository.BookRepository, cfg config.FinesConfig, clk clock.Clock) UseCase {
+	return &FineUseCase{fineRepo: fineRepo, bookRepo: bookRepo, config: cfg, clock: clk}
+}
+
+// FineFor is the fine owed on the loan at the given time.
+func (u *FineUseCase) FineFor(loan *models.Loan, at time.Time) int64 {
+	if !at.After(loan.DueAt) {
+		return 0
+	}
+
+	day := 24 * time.Hour
+	days := int64((at.Sub(loan.DueAt) + day - 1) / day)
+	amount := days * u.config.PerDay
+	if u.config.Cap > 0 && amount > u.config.Cap {
+		amount = u.config.Cap
+	}
+	return amount
+}
+
+// ScanOverdue is run by the scheduler to mark active loans past their due
+// date as overdue and bring their fines up to date.
+func (u *FineUseCase) ScanOverdue(ctx context.Context) error {
+	now := u.clock.Now()
+	scanned := 0
+
+	var afterID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		loans, err := u.fineRepo.FindOverdueLoans(now, afterID, overdueScanBatch)
+		if err != nil {
+			return err
+		}
+		for _, loan := range loans {
+			if err := u.fineRepo.Accrue(loan, u.FineFor(loan, now), now); err != nil {
+				return err
+			}
+			afterID = loan.ID
+			scanned++
+		}
+		if len(loans) < overdueScanBatch {
+			break
+		}
+	}
+
+	if scanned > 0 {
+		log.Printf("accrued fines on %d overdue loans", scanned)
+	}
+	return nil
+}
+
+func (u *FineUseCase) OutstandingBalance(userID uint) (int64, error) {
+	return u.fineRepo.OutstandingBalance(userID)
+}
+
+func (u *FineUseCase) GetFines(userID uint) (*models.FineSummary, error) {
+	fines, err := u.fineRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.FineSummary{Fines: []*models.FineResponse{}}
+	for _, fine := range fines {
+		summary.Fines = append(summary.Fines, models.FilterFineRecord(fine))
+		if fine.Status == models.FineStatusOpen {
+			summary.BalanceCents += fine.BalanceCents()
+		}
+	}
+	return summary, nil
+}
+
+// GetFine is visible to the member who owes it and to the book owner.
+func (u *FineUseCase) GetFine(fineID, userID uint) (*models.FineResponse, error) {
+	fine, err := u.findFine(fineID)
+	if err != nil {
+		return nil, err
+	}
+	if fine.UserID != userID {
+		if err := u.checkStaff(fine, userID); err != nil {
+			return nil, err
+		}
+	}
+	return models.FilterFineRecord(fine), nil
+}
+
+func (u *FineUseCase) RecordTransaction(fineID, staffID uint, kind string, transactionInput *models.FineTransactionInput) (*models.FineResponse, error) {
+	fine, err := u.findFine(fineID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkStaff(fine, staffID); err != nil {
+		return nil, err
+	}
+	if fine.Status != models.FineStatusOpen {
+		return nil, ErrFineSettled
+	}
+
+	updatedFine, err := u.fineRepo.RecordTransaction(fine.ID, &models.FineTransaction{
+		Kind:         kind,
+		AmountCents:  transactionInput.AmountCents,
+		RecordedByID: staffID,
+		Note:         transactionInput.Note,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFineSettled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterFineRecord(updatedFine), nil
+}
+
+func (u *FineUseCase) findFine(fineID uint) (*models.Fine, error) {
+	fine, err := u.fineRepo.FindByID(fineID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fine, nil
+}
+
+func (u *FineUseCase) checkStaff(fine *models.Fine, userID uint) error {
+	// Fines of purged books no longer name the book or its owner.
+	if fine.BookID == nil {
+		return ErrFineForbidden
+	}
+	book, err := u.bookRepo.FindByID(*fine.BookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrFineForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if book.UserID != userID {
+		return ErrFineForbidden
+	}
+	return nil
+}
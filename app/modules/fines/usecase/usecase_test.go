@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	repository "github.com/1rhino/clean_architecture/app/modules/fines/repositories"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+var start = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// fakeFineRepo keeps active loans in memory and records the latest fine
+// accrued on each of them.
+type fakeFineRepo struct {
+	repository.FineRepository
+	loans []*models.Loan
+	fines map[uint]int64
+}
+
+func (r *fakeFineRepo) FindOverdueLoans(now time.Time, afterID uint, limit int) ([]*models.Loan, error) {
+	var loans []*models.Loan
+	for _, loan := range r.loans {
+		if loan.Status == models.LoanStatusActive && loan.DueAt.Before(now) && loan.ID > afterID && len(loans) < limit {
+			loans = append(loans, loan)
+		}
+	}
+	return loans, nil
+}
+
+func (r *fakeFineRepo) Accrue(loan *models.Loan, amountCents int64, now time.Time) error {
+	if loan.OverdueAt == nil {
+		loan.OverdueAt = &now
+	}
+	if amountCents > r.fines[loan.ID] {
+		r.fines[loan.ID] = amountCents
+	}
+	return nil
+}
+
+func TestFineFor(t *testing.T) {
+	dueAt := start
+	tests := []struct {
+		name string
+		cap  int64
+		at   time.Time
+		want int64
+	}{
+		{"before due date", 500, dueAt.Add(-time.Hour), 0},
+		{"at due date", 500, dueAt, 0},
+		{"just after due date", 500, dueAt.Add(time.Second), 25},
+		{"one full day", 500, dueAt.Add(24 * time.Hour), 25},
+		{"second day started", 500, dueAt.Add(24*time.Hour + time.Second), 50},
+		{"ten days", 500, dueAt.Add(10 * 24 * time.Hour), 250},
+		{"capped", 500, dueAt.Add(30 * 24 * time.Hour), 500},
+		{"no cap", 0, dueAt.Add(30 * 24 * time.Hour), 750},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fineUseCase := NewFineUseCase(nil, nil, config.FinesConfig{PerDay: 25, Cap: tt.cap}, clock.New())
+			got := fineUseCase.FineFor(&models.Loan{DueAt: dueAt}, tt.at)
+			if got != tt.want {
+				t.Errorf("FineFor = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanOverdueAccruesDailyUpToCap(t *testing.T) {
+	clk := clock.NewFake(start)
+	fineRepo := &fakeFineRepo{
+		loans: []*models.Loan{
+			{Model: gorm.Model{ID: 1}, Status: models.LoanStatusActive, DueAt: start.Add(12 * time.Hour)},
+			{Model: gorm.Model{ID: 2}, Status: models.LoanStatusActive, DueAt: start.Add(5 * 24 * time.Hour)},
+		},
+		fines: map[uint]int64{},
+	}
+	fineUseCase := NewFineUseCase(fineRepo, nil, config.FinesConfig{PerDay: 100, Cap: 350}, clk)
+
+	// Day 0 is before either due date, day 1 is the first started day late
+	// for loan 1 and the cap is reached on day 4.
+	wantFirst := []int64{0, 100, 200, 300, 350, 350, 350}
+	for day, want := range wantFirst {
+		if err := fineUseCase.ScanOverdue(context.Background()); err != nil {
+			t.Fatalf("day %d: ScanOverdue: %v", day, err)
+		}
+		if got := fineRepo.fines[1]; got != want {
+			t.Errorf("day %d: loan 1 fine = %d, want %d", day, got, want)
+		}
+		clk.Advance(24 * time.Hour)
+	}
+
+	if got := fineRepo.fines[2]; got != 100 {
+		t.Errorf("loan 2 fine = %d, want 100", got)
+	}
+	if fineRepo.loans[0].OverdueAt == nil || !fineRepo.loans[0].OverdueAt.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("loan 1 overdue at %v, want %v", fineRepo.loans[0].OverdueAt, start.Add(24*time.Hour))
+	}
+}
+
+func TestScanOverdueSkipsReturnedLoans(t *testing.T) {
+	clk := clock.NewFake(start.Add(10 * 24 * time.Hour))
+	fineRepo := &fakeFineRepo{
+		loans: []*models.Loan{{Model: gorm.Model{ID: 1}, Status: models.LoanStatusReturned, DueAt: start}},
+		fines: map[uint]int64{},
+	}
+	fineUseCase := NewFineUseCase(fineRepo, nil, config.FinesConfig{PerDay: 100}, clk)
+
+	if err := fineUseCase.ScanOverdue(context.Background()); err != nil {
+		t.Fatalf("ScanOverdue: %v", err)
+	}
+	if len(fineRepo.fines) != 0 {
+		t.Errorf("fines = %v, want none", fineRepo.fines)
+	}
+}
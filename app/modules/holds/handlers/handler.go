@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	hold "github.com/1rhino/clean_architecture/app/modules/holds/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type HoldHandlers struct {
+	holdUseCase hold.UseCase
+}
+
+func NewHoldHandlers(holdUseCase hold.UseCase) *HoldHandlers {
+	return &HoldHandlers{holdUseCase: holdUseCase}
+}
+
+// place a hold on a book
+func (h *HoldHandlers) PlaceHold(c *gin.Context) {
+	var holdInput models.HoldInput
+
+	if err := c.ShouldBind(&holdInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdHold, err := h.holdUseCase.PlaceHold(&holdInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": createdHold})
+}
+
+// get list holds by userID
+func (h *HoldHandlers) GetHolds(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	holds, err := h.holdUseCase.GetHolds(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": holds})
+}
+
+// get hold detail with its queue position
+func (h *HoldHandlers) GetHoldDetail(c *gin.Context) {
+	userID, holdID, ok := userAndHoldID(c)
+	if !ok {
+		return
+	}
+
+	getHold, err := h.holdUseCase.GetHold(holdID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, getHold)
+}
+
+// cancel a hold
+func (h *HoldHandlers) CancelHold(c *gin.Context) {
+	userID, holdID, ok := userAndHoldID(c)
+	if !ok {
+		return
+	}
+
+	cancelledHold, err := h.holdUseCase.CancelHold(holdID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cancelledHold})
+}
+
+func userAndHoldID(c *gin.Context) (uint, uint, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	holdID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(holdID), true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, hold.ErrBookNotFound), errors.Is(err, hold.ErrHoldNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, hold.ErrHoldForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, hold.ErrHoldExists),
+		errors.Is(err, hold.ErrCopyAvailable),
+		errors.Is(err, hold.ErrNoCopies),
+		errors.Is(err, hold.ErrHoldNotActive):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
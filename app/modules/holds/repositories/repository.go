@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HoldRepository interface {
+	Create(hold *models.Hold) (*models.Hold, error)
+	FindByID(id uint) (*models.Hold, error)
+	FindByUserID(userID uint) ([]*models.Hold, error)
+	FindActive(bookID, userID uint) (*models.Hold, error)
+	Position(hold *models.Hold) (int64, error)
+	Cancel(holdID uint, now time.Time, pickupTTL time.Duration) (*models.Hold, error)
+	ExpireReady(now time.Time, pickupTTL time.Duration) (int, error)
+}
+
+type HoldRepo struct {
+	DB *gorm.DB
+}
+
+func NewHoldRepo(db *gorm.DB) HoldRepository {
+	return &HoldRepo{DB: db}
+}
+
+// Create queues the hold. A second active hold by the same user for the
+// same book returns gorm.ErrDuplicatedKey.
+func (r *HoldRepo) Create(hold *models.Hold) (*models.Hold, error) {
+	if err := r.DB.Omit("Book", "User").Create(hold).Error; err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (r *HoldRepo) FindByID(id uint) (*models.Hold, error) {
+	var hold models.Hold
+	if err := r.DB.First(&hold, id).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *HoldRepo) FindByUserID(userID uint) ([]*models.Hold, error) {
+	var holds []*models.Hold
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+// FindActive returns the user's waiting or ready hold on the book.
+func (r *HoldRepo) FindActive(bookID, userID uint) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.DB.Where("book_id = ? AND user_id = ? AND status IN ?", bookID, userID,
+		[]string{models.HoldStatusWaiting, models.HoldStatusReady}).
+		First(&hold).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// Position is the 1-based place of a waiting hold in its book's queue.
+func (r *HoldRepo) Position(hold *models.Hold) (int64, error) {
+	var ahead int64
+	err := r.DB.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ?", hold.BookID, models.HoldStatusWaiting).
+		Where("(created_at, id) < (?, ?)", hold.CreatedAt, hold.ID).
+		Count(&ahead).Error
+	if err != nil {
+		return 0, err
+	}
+	return ahead + 1, nil
+}
+
+// Cancel withdraws a waiting or ready hold. A copy set aside for a ready hold
+// moves on to the next hold in the queue. It returns gorm.ErrRecordNotFound
+// when the hold is no longer active.
+func (r *HoldRepo) Cancel(holdID uint, now time.Time, pickupTTL time.Duration) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status IN ?", holdID, []string{models.HoldStatusWaiting, models.HoldStatusReady}).
+			First(&hold).Error
+		if err != nil {
+			return err
+		}
+
+		wasReady := hold.Status == models.HoldStatusReady
+		hold.Status = models.HoldStatusCancelled
+		if err := tx.Omit("Book", "User").Save(&hold).Error; err != nil {
+			return err
+		}
+
+		if wasReady && hold.CopyID != nil {
+			return ReleaseCopy(tx, *hold.CopyID, hold.BookID, now, pickupTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ExpireReady expires ready holds whose pickup window has passed and hands
+// their copies to the next hold in line. Rows are claimed with SKIP LOCKED so
+// concurrent runs never process the same hold twice.
+func (r *HoldRepo) ExpireReady(now time.Time, pickupTTL time.Duration) (int, error) {
+	expired := 0
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var holds []*models.Hold
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND expires_at < ?", models.HoldStatusReady, now).
+			Order("expires_at ASC").
+			Limit(100).
+			Find(&holds).Error
+		if err != nil {
+			return err
+		}
+
+		for _, hold := range holds {
+			hold.Status = models.HoldStatusExpired
+			if err := tx.Omit("Book", "User").Save(hold).Error; err != nil {
+				return err
+			}
+			if hold.CopyID != nil {
+				if err := ReleaseCopy(tx, *hold.CopyID, hold.BookID, now, pickupTTL); err != nil {
+					return err
+				}
+			}
+			expired++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
+// ReleaseCopy hands a copy that has come back to the library to the first
+// waiting hold on its book, or puts it back on the shelf when nobody is
+// waiting. It must run inside the transaction that freed the copy. The next
+// hold is claimed with FOR UPDATE SKIP LOCKED, so two copies returned at the
+// same time are always given to two different holds.
+func ReleaseCopy(tx *gorm.DB, copyID, bookID uint, now time.Time, pickupTTL time.Duration) error {
+	var hold models.Hold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("book_id = ? AND status = ?", bookID, models.HoldStatusWaiting).
+		Order("created_at ASC, id ASC").
+		Limit(1).
+		Find(&hold).Error
+	if err != nil {
+		return err
+	}
+
+	if hold.ID == 0 {
+		return tx.Model(&models.Copy{}).Where("id = ?", copyID).Update("status", models.CopyStatusAvailable).Error
+	}
+
+	expiresAt := now.Add(pickupTTL)
+	hold.Status = models.HoldStatusReady
+	hold.CopyID = &copyID
+	hold.ReadyAt = &now
+	hold.ExpiresAt = &expiresAt
+	if err := tx.Omit("Book", "User").Save(&hold).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Copy{}).Where("id = ?", copyID).Update("status", models.CopyStatusOnHold).Error
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/holds/repositories"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound  = errors.New("book not found")
+	ErrHoldNotFound  = errors.New("hold not found")
+	ErrHoldForbidden = errors.New("you can only manage your own holds")
+	ErrHoldExists    = errors.New("you already have a hold on this book")
+	ErrCopyAvailable = errors.New("a copy is available, check it out instead")
+	ErrNoCopies      = errors.New("the library holds no copies of this book")
+	ErrHoldNotActive = errors.New("hold is no longer active")
+)
+
+type UseCase interface {
+	PlaceHold(holdInput *models.HoldInput, userID uint) (*models.HoldResponse, error)
+	GetHolds(userID uint) ([]*models.HoldResponse, error)
+	GetHold(holdID, userID uint) (*models.HoldResponse, error)
+	CancelHold(holdID, userID uint) (*models.HoldResponse, error)
+	ExpireHolds(ctx context.Context) error
+}
+
+type HoldUseCase struct {
+	holdRepo repository.HoldRepository
+	bookRepo bookRepository.BookRepository
+	config   config.LibraryConfig
+	clock    clock.Clock
+}
+
+func NewHoldUseCase(holdRepo repository.HoldRepository, bookRepo bookRepository.BookRepository, cfg config.LibraryConfig, clk clock.Clock) UseCase {
+	return &HoldUseCase{holdRepo: holdRepo, bookRepo: bookRepo, config: cfg, clock: clk}
+}
+
+// PlaceHold queues the user for a book whose copies are all out.
+func (u *HoldUseCase) PlaceHold(holdInput *models.HoldInput, userID uint) (*models.HoldResponse, error) {
+	_, err := u.bookRepo.FindByID(holdInput.BookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	availability, err := u.bookRepo.GetAvailability(holdInput.BookID)
+	if err != nil {
+		return nil, err
+	}
+	if availability.TotalCopies == 0 {
+		return nil, ErrNoCopies
+	}
+	if availability.AvailableCopies > 0 {
+		return nil, ErrCopyAvailable
+	}
+
+	_, err = u.holdRepo.FindActive(holdInput.BookID, userID)
+	if err == nil {
+		return nil, ErrHoldExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hold, err := u.holdRepo.Create(&models.Hold{
+		BookID: holdInput.BookID,
+		UserID: userID,
+		Status: models.HoldStatusWaiting,
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrHoldExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.filterHold(hold)
+}
+
+func (u *HoldUseCase) GetHolds(userID uint) ([]*models.HoldResponse, error) {
+	holds, err := u.holdRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var holdResponses []*models.HoldResponse
+	for _, hold := range holds {
+		holdResponse, err := u.filterHold(hold)
+		if err != nil {
+			return nil, err
+		}
+		holdResponses = append(holdResponses, holdResponse)
+	}
+	return holdResponses, nil
+}
+
+func (u *HoldUseCase) GetHold(holdID, userID uint) (*models.HoldResponse, error) {
+	hold, err := u.findOwnedHold(holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.filterHold(hold)
+}
+
+func (u *HoldUseCase) CancelHold(holdID, userID uint) (*models.HoldResponse, error) {
+	hold, err := u.findOwnedHold(holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelledHold, err := u.holdRepo.Cancel(hold.ID, u.clock.Now(), u.pickupPeriod())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHoldNotActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterHoldRecord(cancelledHold), nil
+}
+
+// ExpireHolds is run by the scheduler to lapse ready holds that were not
+// picked up in time.
+func (u *HoldUseCase) ExpireHolds(ctx context.Context) error {
+	expired, err := u.holdRepo.ExpireReady(u.clock.Now(), u.pickupPeriod())
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		log.Printf("expired %d holds", expired)
+	}
+	return nil
+}
+
+func (u *HoldUseCase) findOwnedHold(holdID, userID uint) (*models.Hold, error) {
+	hold, err := u.holdRepo.FindByID(holdID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hold.UserID != userID {
+		return nil, ErrHoldForbidden
+	}
+	return hold, nil
+}
+
+// filterHold adds the queue position of waiting holds.
+func (u *HoldUseCase) filterHold(hold *models.Hold) (*models.HoldResponse, error) {
+	holdResponse := models.FilterHoldRecord(hold)
+	if hold.Status == models.HoldStatusWaiting {
+		position, err := u.holdRepo.Position(hold)
+		if err != nil {
+			return nil, err
+		}
+		holdResponse.Position = position
+	}
+	return holdResponse, nil
+}
+
+func (u *HoldUseCase) pickupPeriod() time.Duration {
+	return time.Duration(u.config.HoldPickupDays) * 24 * time.Hour
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	repository "github.com/1rhino/clean_architecture/app/modules/holds/repositories"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+// fakeHoldRepo expires ready holds the way HoldRepo.ExpireReady does,
+// without handing their copies on.
+type fakeHoldRepo struct {
+	repository.HoldRepository
+	holds []*models.Hold
+}
+
+func (r *fakeHoldRepo) ExpireReady(now time.Time, pickupTTL time.Duration) (int, error) {
+	expired := 0
+	for _, hold := range r.holds {
+		if hold.Status == models.HoldStatusReady && hold.ExpiresAt.Before(now) {
+			hold.Status = models.HoldStatusExpired
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func TestExpireHoldsAfterPickupWindow(t *testing.T) {
+	readyAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(readyAt)
+	cfg := config.LibraryConfig{HoldPickupDays: 3}
+
+	expiresAt := readyAt.Add(3 * 24 * time.Hour)
+	laterExpiresAt := expiresAt.Add(24 * time.Hour)
+	holdRepo := &fakeHoldRepo{holds: []*models.Hold{
+		{Model: gorm.Model{ID: 1}, Status: models.HoldStatusReady, ReadyAt: &readyAt, ExpiresAt: &expiresAt},
+		{Model: gorm.Model{ID: 2}, Status: models.HoldStatusReady, ReadyAt: &readyAt, ExpiresAt: &laterExpiresAt},
+		{Model: gorm.Model{ID: 3}, Status: models.HoldStatusWaiting},
+	}}
+	holdUseCase := NewHoldUseCase(holdRepo, nil, cfg, clk)
+
+	steps := []struct {
+		advance time.Duration
+		want    []string
+	}{
+		{0, []string{models.HoldStatusReady, models.HoldStatusReady, models.HoldStatusWaiting}},
+		{3 * 24 * time.Hour, []string{models.HoldStatusReady, models.HoldStatusReady, models.HoldStatusWaiting}},
+		{time.Second, []string{models.HoldStatusExpired, models.HoldStatusReady, models.HoldStatusWaiting}},
+		{24 * time.Hour, []string{models.HoldStatusExpired, models.HoldStatusExpired, models.HoldStatusWaiting}},
+	}
+	for i, step := range steps {
+		clk.Advance(step.advance)
+		if err := holdUseCase.ExpireHolds(context.Background()); err != nil {
+			t.Fatalf("step %d: ExpireHolds: %v", i, err)
+		}
+		for j, hold := range holdRepo.holds {
+			if hold.Status != step.want[j] {
+				t.Errorf("step %d: hold %d status = %q, want %q", i, hold.ID, hold.Status, step.want[j])
+			}
+		}
+	}
+}
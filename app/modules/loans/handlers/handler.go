@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	loan "github.com/1rhino/clean_architecture/app/modules/loans/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type LoanHandlers struct {
+	loanUseCase loan.UseCase
+}
+
+func NewLoanHandlers(loanUseCase loan.UseCase) *LoanHandlers {
+	return &LoanHandlers{loanUseCase: loanUseCase}
+}
+
+// check out a copy of a book
+func (h *LoanHandlers) Checkout(c *gin.Context) {
+	var checkoutInput models.CheckoutInput
+
+	if err := c.ShouldBind(&checkoutInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdLoan, err := h.loanUseCase.Checkout(&checkoutInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": createdLoan})
+}
+
+// return a loaned copy
+func (h *LoanHandlers) ReturnLoan(c *gin.Context) {
+	userID, loanID, ok := userAndLoanID(c)
+	if !ok {
+		return
+	}
+
+	returnedLoan, err := h.loanUseCase.ReturnLoan(loanID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": returnedLoan})
+}
+
+// renew a loan
+func (h *LoanHandlers) RenewLoan(c *gin.Context) {
+	userID, loanID, ok := userAndLoanID(c)
+	if !ok {
+		return
+	}
+
+	renewedLoan, err := h.loanUseCase.RenewLoan(loanID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": renewedLoan})
+}
+
+// get list loans by userID
+func (h *LoanHandlers) GetLoans(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query models.LoanQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loans, err := h.loanUseCase.GetLoans(userID, &query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": loans})
+}
+
+// get loan detail
+func (h *LoanHandlers) GetLoanDetail(c *gin.Context) {
+	userID, loanID, ok := userAndLoanID(c)
+	if !ok {
+		return
+	}
+
+	getLoan, err := h.loanUseCase.GetLoan(loanID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, getLoan)
+}
+
+func userAndLoanID(c *gin.Context) (uint, uint, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	loanID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(loanID), true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, loan.ErrLoanNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, loan.ErrLoanForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, loan.ErrNoCopyAvailable),
+		errors.Is(err, loan.ErrLoanLimitReached),
+		errors.Is(err, loan.ErrLoanNotActive),
+		errors.Is(err, loan.ErrRenewalLimitReached),
+		errors.Is(err, loan.ErrRenewalOnHold),
+		errors.Is(err, loan.ErrLoanOverdue):
+		return http.StatusConflict
+	case errors.Is(err, loan.ErrFinesOutstanding):
+		return http.StatusPaymentRequired
+	case errors.Is(err, loan.ErrCheckoutTarget):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
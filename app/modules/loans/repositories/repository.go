@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	fineRepository "github.com/1rhino/clean_architecture/app/modules/fines/repositories"
+	holdRepository "github.com/1rhino/clean_architecture/app/modules/holds/repositories"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLoanLimit        = errors.New("active loan limit reached")
+	ErrFinesOutstanding = errors.New("outstanding fines over threshold")
+)
+
+type LoanRepository interface {
+	Checkout(loan *models.Loan, copyID, bookID uint, maxActiveLoans int, fineThreshold int64) (*models.Loan, error)
+	FindByID(id uint) (*models.Loan, error)
+	FindByUserID(userID uint, status string) ([]*models.Loan, error)
+	Return(loanID uint, returnedAt time.Time, pickupTTL time.Duration, fineCents int64) (*models.Loan, error)
+	Renew(loanID uint, dueAt time.Time, maxRenewals int) (*models.Loan, error)
+	HasWaitingHolds(bookID uint) (bool, error)
+}
+
+type LoanRepo struct {
+	DB *gorm.DB
+}
+
+func NewLoanRepo(db *gorm.DB) LoanRepository {
+	return &LoanRepo{DB: db}
+}
+
+// Checkout locks an available copy, either the given one or any copy of the
+// given book, marks it as on loan and records the loan. A copy set aside for
+// the borrower's ready hold is used first and the hold is fulfilled. It
+// returns gorm.ErrRecordNotFound when no copy is available.
+//
+// The borrower's row is locked first so that concurrent checkouts see each
+// other's loans: ErrLoanLimit is returned once maxActiveLoans are active and
+// ErrFinesOutstanding once open fines reach a positive fineThreshold.
+func (r *LoanRepo) Checkout(loan *models.Loan, copyID, bookID uint, maxActiveLoans int, fineThreshold int64) (*models.Loan, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&user, loan.UserID).Error
+		if err != nil {
+			return err
+		}
+
+		var activeLoans int64
+		err = tx.Model(&models.Loan{}).
+			Where("user_id = ? AND status = ?", loan.UserID, models.LoanStatusActive).
+			Count(&activeLoans).Error
+		if err != nil {
+			return err
+		}
+		if activeLoans >= int64(maxActiveLoans) {
+			return ErrLoanLimit
+		}
+
+		if fineThreshold > 0 {
+			balance, err := fineRepository.Balance(tx, loan.UserID)
+			if err != nil {
+				return err
+			}
+			if balance >= fineThreshold {
+				return ErrFinesOutstanding
+			}
+		}
+
+		var hold models.Hold
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", loan.UserID, models.HoldStatusReady).
+			Where(tx.Where("copy_id = ?", copyID).Or("book_id = ?", bookID)).
+			Limit(1).
+			Find(&hold).Error
+		if err != nil {
+			return err
+		}
+
+		var bookCopy models.Copy
+		var query *gorm.DB
+		switch {
+		case hold.ID != 0 && hold.CopyID != nil:
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND status = ?", *hold.CopyID, models.CopyStatusOnHold)
+		case copyID != 0:
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND status = ?", copyID, models.CopyStatusAvailable)
+		default:
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("book_id = ? AND status = ?", bookID, models.CopyStatusAvailable).
+				Order("id ASC")
+		}
+		if err := query.First(&bookCopy).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&bookCopy).Update("status", models.CopyStatusOnLoan).Error; err != nil {
+			return err
+		}
+
+		if hold.ID != 0 {
+			if err := tx.Model(&hold).Update("status", models.HoldStatusFulfilled).Error; err != nil {
+				return err
+			}
+		}
+
+		loan.CopyID = &bookCopy.ID
+		loan.BookID = &bookCopy.BookID
+		return tx.Omit("Copy", "User").Create(loan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+func (r *LoanRepo) FindByID(id uint) (*models.Loan, error) {
+	var loan models.Loan
+	if err := r.DB.First(&loan, id).Error; err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+func (r *LoanRepo) FindByUserID(userID uint, status string) ([]*models.Loan, error) {
+	var loans []*models.Loan
+	query := r.DB.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("checked_out_at DESC, id DESC").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// Return closes an active loan and hands its copy to the next waiting hold,
+// or puts it back on the shelf. A positive fineCents settles the loan's late
+// fine in the same transaction. It returns gorm.ErrRecordNotFound when the
+// loan is no longer active.
+func (r *LoanRepo) Return(loanID uint, returnedAt time.Time, pickupTTL time.Duration, fineCents int64) (*models.Loan, error) {
+	var loan models.Loan
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", loanID, models.LoanStatusActive).
+			First(&loan).Error
+		if err != nil {
+			return err
+		}
+
+		loan.Status = models.LoanStatusReturned
+		loan.ReturnedAt = &returnedAt
+		if err := tx.Omit("Copy", "User").Save(&loan).Error; err != nil {
+			return err
+		}
+
+		if fineCents > 0 {
+			if err := fineRepository.AccrueFine(tx, &loan, fineCents, returnedAt); err != nil {
+				return err
+			}
+			if loan.OverdueAt == nil {
+				loan.OverdueAt = &returnedAt
+			}
+		}
+
+		return holdRepository.ReleaseCopy(tx, *loan.CopyID, *loan.BookID, returnedAt, pickupTTL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// Renew moves the due date of an active loan that has renewals left. It
+// returns gorm.ErrRecordNotFound when the loan can't be renewed.
+func (r *LoanRepo) Renew(loanID uint, dueAt time.Time, maxRenewals int) (*models.Loan, error) {
+	result := r.DB.Model(&models.Loan{}).
+		Where("id = ? AND status = ? AND renewals < ?", loanID, models.LoanStatusActive, maxRenewals).
+		Updates(map[string]interface{}{
+			"due_at":   dueAt,
+			"renewals": gorm.Expr("renewals + 1"),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.FindByID(loanID)
+}
+
+func (r *LoanRepo) HasWaitingHolds(bookID uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ?", bookID, models.HoldStatusWaiting).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
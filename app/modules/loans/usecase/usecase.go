@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	fine "github.com/1rhino/clean_architecture/app/modules/fines/usecase"
+	repository "github.com/1rhino/clean_architecture/app/modules/loans/repositories"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCheckoutTarget      = errors.New("either copy_id or book_id is required")
+	ErrNoCopyAvailable     = errors.New("no copy is available for checkout")
+	ErrLoanLimitReached    = errors.New("you have reached the maximum number of active loans")
+	ErrLoanNotFound        = errors.New("loan not found")
+	ErrLoanForbidden       = errors.New("you can only manage your own loans")
+	ErrLoanNotActive       = errors.New("loan has already been returned")
+	ErrRenewalLimitReached = errors.New("loan can't be renewed any more")
+	ErrRenewalOnHold       = errors.New("loan can't be renewed while other members are waiting for the book")
+	ErrLoanOverdue         = errors.New("overdue loans can't be renewed")
+	ErrFinesOutstanding    = errors.New("outstanding fines must be paid before borrowing")
+)
+
+type UseCase interface {
+	Checkout(checkoutInput *models.CheckoutInput, userID uint) (*models.LoanResponse, error)
+	ReturnLoan(loanID, userID uint) (*models.LoanResponse, error)
+	RenewLoan(loanID, userID uint) (*models.LoanResponse, error)
+	GetLoans(userID uint, query *models.LoanQuery) ([]*models.LoanResponse, error)
+	GetLoan(loanID, userID uint) (*models.LoanResponse, error)
+}
+
+type LoanUseCase struct {
+	loanRepo    repository.LoanRepository
+	bookRepo    bookRepository.BookRepository
+	fineUseCase fine.UseCase
+	config      config.LibraryConfig
+	finesConfig config.FinesConfig
+	clock       clock.Clock
+}
+
+func NewLoanUseCase(loanRepo repository.LoanRepository, bookRepo bookRepository.BookRepository, fineUseCase fine.UseCase, cfg config.LibraryConfig, finesCfg config.FinesConfig, clk clock.Clock) UseCase {
+	return &LoanUseCase{
+		loanRepo:    loanRepo,
+		bookRepo:    bookRepo,
+		fineUseCase: fineUseCase,
+		config:      cfg,
+		finesConfig: finesCfg,
+		clock:       clk,
+	}
+}
+
+func (u *LoanUseCase) Checkout(checkoutInput *models.CheckoutInput, userID uint) (*models.LoanResponse, error) {
+	if checkoutInput.CopyID == 0 && checkoutInput.BookID == 0 {
+		return nil, ErrCheckoutTarget
+	}
+
+	now := u.clock.Now()
+	loan := &models.Loan{
+		UserID:       userID,
+		Status:       models.LoanStatusActive,
+		CheckedOutAt: now,
+		DueAt:        now.Add(u.loanPeriod()),
+	}
+
+	createdLoan, err := u.loanRepo.Checkout(loan, checkoutInput.CopyID, checkoutInput.BookID, u.config.MaxActiveLoans, u.finesConfig.BlockThreshold)
+	switch {
+	case errors.Is(err, repository.ErrLoanLimit):
+		return nil, ErrLoanLimitReached
+	case errors.Is(err, repository.ErrFinesOutstanding):
+		return nil, ErrFinesOutstanding
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, ErrNoCopyAvailable
+	case err != nil:
+		return nil, err
+	}
+	return models.FilterLoanRecord(createdLoan), nil
+}
+
+// ReturnLoan can be done by the borrower or by the owner of the book.
+func (u *LoanUseCase) ReturnLoan(loanID, userID uint) (*models.LoanResponse, error) {
+	loan, err := u.findAccessibleLoan(loanID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.Status != models.LoanStatusActive {
+		return nil, ErrLoanNotActive
+	}
+
+	now := u.clock.Now()
+	returnedLoan, err := u.loanRepo.Return(loan.ID, now, u.pickupPeriod(), u.fineUseCase.FineFor(loan, now))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLoanNotActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterLoanRecord(returnedLoan), nil
+}
+
+// RenewLoan restarts the loan period from today, up to the configured number
+// of renewals. Loans that are already overdue have to be returned.
+func (u *LoanUseCase) RenewLoan(loanID, userID uint) (*models.LoanResponse, error) {
+	loan, err := u.findLoan(loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.UserID != userID {
+		return nil, ErrLoanForbidden
+	}
+	if loan.Status != models.LoanStatusActive {
+		return nil, ErrLoanNotActive
+	}
+	if loan.Renewals >= u.config.MaxRenewals {
+		return nil, ErrRenewalLimitReached
+	}
+
+	now := u.clock.Now()
+	if loan.OverdueAt != nil || now.After(loan.DueAt) {
+		return nil, ErrLoanOverdue
+	}
+
+	onHold, err := u.loanRepo.HasWaitingHolds(*loan.BookID)
+	if err != nil {
+		return nil, err
+	}
+	if onHold {
+		return nil, ErrRenewalOnHold
+	}
+
+	renewedLoan, err := u.loanRepo.Renew(loan.ID, now.Add(u.loanPeriod()), u.config.MaxRenewals)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRenewalLimitReached
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterLoanRecord(renewedLoan), nil
+}
+
+func (u *LoanUseCase) GetLoans(userID uint, query *models.LoanQuery) ([]*models.LoanResponse, error) {
+	loans, err := u.loanRepo.FindByUserID(userID, query.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	var loanResponses []*models.LoanResponse
+	for _, loan := range loans {
+		loanResponses = append(loanResponses, models.FilterLoanRecord(loan))
+	}
+	return loanResponses, nil
+}
+
+func (u *LoanUseCase) GetLoan(loanID, userID uint) (*models.LoanResponse, error) {
+	loan, err := u.findAccessibleLoan(loanID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterLoanRecord(loan), nil
+}
+
+func (u *LoanUseCase) findLoan(loanID uint) (*models.Loan, error) {
+	loan, err := u.loanRepo.FindByID(loanID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLoanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// findAccessibleLoan lets the borrower and the owner of the book through.
+func (u *LoanUseCase) findAccessibleLoan(loanID, userID uint) (*models.Loan, error) {
+	loan, err := u.findLoan(loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.UserID == userID {
+		return loan, nil
+	}
+
+	// Loans of purged books no longer name the book or its owner.
+	if loan.BookID == nil {
+		return nil, ErrLoanForbidden
+	}
+	book, err := u.bookRepo.FindByID(*loan.BookID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if book == nil || book.UserID != userID {
+		return nil, ErrLoanForbidden
+	}
+	return loan, nil
+}
+
+func (u *LoanUseCase) loanPeriod() time.Duration {
+	return time.Duration(u.config.LoanPeriodDays) * 24 * time.Hour
+}
+
+func (u *LoanUseCase) pickupPeriod() time.Duration {
+	return time.Duration(u.config.HoldPickupDays) * 24 * time.Hour
+}
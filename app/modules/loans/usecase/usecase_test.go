@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	fine "github.com/1rhino/clean_architecture/app/modules/fines/usecase"
+	repository "github.com/1rhino/clean_architecture/app/modules/loans/repositories"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+// fakeLoanRepo keeps loans in memory and records the fine charged on return.
+type fakeLoanRepo struct {
+	repository.LoanRepository
+	loans     map[uint]*models.Loan
+	fineCents int64
+}
+
+func (r *fakeLoanRepo) Checkout(loan *models.Loan, copyID, bookID uint, maxActiveLoans int, fineThreshold int64) (*models.Loan, error) {
+	loan.ID = uint(len(r.loans) + 1)
+	loan.CopyID = &copyID
+	loan.BookID = &bookID
+	r.loans[loan.ID] = loan
+	return loan, nil
+}
+
+func (r *fakeLoanRepo) FindByID(id uint) (*models.Loan, error) {
+	loan, ok := r.loans[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return loan, nil
+}
+
+func (r *fakeLoanRepo) Return(loanID uint, returnedAt time.Time, pickupTTL time.Duration, fineCents int64) (*models.Loan, error) {
+	loan := r.loans[loanID]
+	loan.Status = models.LoanStatusReturned
+	loan.ReturnedAt = &returnedAt
+	r.fineCents = fineCents
+	return loan, nil
+}
+
+func (r *fakeLoanRepo) Renew(loanID uint, dueAt time.Time, maxRenewals int) (*models.Loan, error) {
+	loan := r.loans[loanID]
+	loan.DueAt = dueAt
+	loan.Renewals++
+	return loan, nil
+}
+
+func (r *fakeLoanRepo) HasWaitingHolds(bookID uint) (bool, error) {
+	return false, nil
+}
+
+func newTestLoanUseCase(clk clock.Clock) (UseCase, *fakeLoanRepo) {
+	loanRepo := &fakeLoanRepo{loans: map[uint]*models.Loan{}}
+	finesCfg := config.FinesConfig{PerDay: 50, Cap: 400}
+	fineUseCase := fine.NewFineUseCase(nil, nil, finesCfg, clk)
+	cfg := config.LibraryConfig{LoanPeriodDays: 14, MaxRenewals: 2, MaxActiveLoans: 5}
+	return NewLoanUseCase(loanRepo, nil, fineUseCase, cfg, finesCfg, clk), loanRepo
+}
+
+func TestCheckoutDueDate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	loanUseCase, _ := newTestLoanUseCase(clock.NewFake(start))
+
+	loan, err := loanUseCase.Checkout(&models.CheckoutInput{CopyID: 7}, 1)
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if want := start.Add(14 * 24 * time.Hour); !loan.DueAt.Equal(want) {
+		t.Errorf("due at %v, want %v", loan.DueAt, want)
+	}
+}
+
+func TestRenewAcrossDueDate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	loanUseCase, _ := newTestLoanUseCase(clk)
+
+	loan, err := loanUseCase.Checkout(&models.CheckoutInput{CopyID: 7}, 1)
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+
+	clk.Advance(10 * 24 * time.Hour)
+	renewed, err := loanUseCase.RenewLoan(loan.ID, 1)
+	if err != nil {
+		t.Fatalf("RenewLoan before due date: %v", err)
+	}
+	if want := clk.Now().Add(14 * 24 * time.Hour); !renewed.DueAt.Equal(want) {
+		t.Errorf("renewed due at %v, want %v", renewed.DueAt, want)
+	}
+
+	clk.Set(renewed.DueAt.Add(time.Minute))
+	if _, err := loanUseCase.RenewLoan(loan.ID, 1); err != ErrLoanOverdue {
+		t.Errorf("RenewLoan after due date = %v, want %v", err, ErrLoanOverdue)
+	}
+}
+
+func TestReturnChargesFine(t *testing.T) {
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  int64
+	}{
+		{"on time", 14 * 24 * time.Hour, 0},
+		{"an hour late", 14*24*time.Hour + time.Hour, 50},
+		{"three days late", 17 * 24 * time.Hour, 150},
+		{"capped", 60 * 24 * time.Hour, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+			clk := clock.NewFake(start)
+			loanUseCase, loanRepo := newTestLoanUseCase(clk)
+
+			loan, err := loanUseCase.Checkout(&models.CheckoutInput{CopyID: 7}, 1)
+			if err != nil {
+				t.Fatalf("Checkout: %v", err)
+			}
+			clk.Advance(tt.after)
+			if _, err := loanUseCase.ReturnLoan(loan.ID, 1); err != nil {
+				t.Fatalf("ReturnLoan: %v", err)
+			}
+			if loanRepo.fineCents != tt.want {
+				t.Errorf("fine = %d, want %d", loanRepo.fineCents, tt.want)
+			}
+		})
+	}
+}
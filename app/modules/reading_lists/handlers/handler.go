@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	readingList "github.com/1rhino/clean_architecture/app/modules/reading_lists/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ReadingListHandlers struct {
+	readingListUseCase readingList.UseCase
+}
+
+func NewReadingListHandlers(readingListUseCase readingList.UseCase) *ReadingListHandlers {
+	return &ReadingListHandlers{readingListUseCase: readingListUseCase}
+}
+
+// create a new reading list
+func (h *ReadingListHandlers) CreateReadingList(c *gin.Context) {
+	var listInput models.ReadingListInput
+
+	if err := c.ShouldBind(&listInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdList, err := h.readingListUseCase.CreateReadingList(&listInput, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": createdList})
+}
+
+// get list reading lists by userID
+func (h *ReadingListHandlers) GetReadingLists(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	lists, err := h.readingListUseCase.GetReadingLists(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": lists})
+}
+
+// get reading list detail
+func (h *ReadingListHandlers) GetReadingListDetail(c *gin.Context) {
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.readingListUseCase.GetReadingList(listID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// update reading list
+func (h *ReadingListHandlers) UpdateReadingList(c *gin.Context) {
+	var listInput models.UpdateReadingList
+
+	if err := c.ShouldBind(&listInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	listInput.ID = listID
+	updatedList, err := h.readingListUseCase.UpdateReadingList(&listInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": updatedList})
+}
+
+// delete reading list
+func (h *ReadingListHandlers) DeleteReadingList(c *gin.Context) {
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	err := h.readingListUseCase.DeleteReadingList(listID, userID)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to delete reading list"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ReadingList deleted successfully"})
+}
+
+// add a book to a reading list
+func (h *ReadingListHandlers) AddItem(c *gin.Context) {
+	var itemInput models.ReadingListItemInput
+
+	if err := c.ShouldBind(&itemInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	item, err := h.readingListUseCase.AddItem(listID, userID, &itemInput)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": item})
+}
+
+// update the note of a reading list entry
+func (h *ReadingListHandlers) UpdateItem(c *gin.Context) {
+	var itemInput models.UpdateReadingListItem
+
+	if err := c.ShouldBind(&itemInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reading list item ID"})
+		return
+	}
+
+	item, err := h.readingListUseCase.UpdateItem(listID, uint(itemID), userID, &itemInput)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": item})
+}
+
+// remove a book from a reading list
+func (h *ReadingListHandlers) DeleteItem(c *gin.Context) {
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reading list item ID"})
+		return
+	}
+
+	err = h.readingListUseCase.DeleteItem(listID, uint(itemID), userID)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to remove book from reading list"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Book removed from reading list successfully"})
+}
+
+// reorder the entries of a reading list
+func (h *ReadingListHandlers) Reorder(c *gin.Context) {
+	var reorderInput models.ReorderReadingListInput
+
+	if err := c.ShouldBind(&reorderInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, listID, ok := userAndListID(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.readingListUseCase.Reorder(listID, userID, &reorderInput)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// get public reading lists, no authentication required
+func (h *ReadingListHandlers) GetPublicReadingLists(c *gin.Context) {
+	var pagination models.PaginationInput
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pagination.Normalize()
+
+	lists, meta, err := h.readingListUseCase.GetPublicReadingLists(&pagination)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": lists, "meta": meta})
+}
+
+// get a public reading list, no authentication required
+func (h *ReadingListHandlers) GetPublicReadingList(c *gin.Context) {
+	listID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reading list ID"})
+		return
+	}
+
+	list, err := h.readingListUseCase.GetPublicReadingList(uint(listID))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// get a reading list through its share link, no authentication required
+func (h *ReadingListHandlers) GetSharedReadingList(c *gin.Context) {
+	list, err := h.readingListUseCase.GetSharedReadingList(c.Param("token"))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func userAndListID(c *gin.Context) (uint, uint, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	listID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reading list ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(listID), true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, readingList.ErrReadingListNotFound),
+		errors.Is(err, readingList.ErrReadingListItemNotFound),
+		errors.Is(err, readingList.ErrBookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, readingList.ErrBookAlreadyListed):
+		return http.StatusConflict
+	case errors.Is(err, readingList.ErrInvalidReorder):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
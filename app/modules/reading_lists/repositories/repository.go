@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReadingListRepository interface {
+	Create(list *models.ReadingList) (*models.ReadingList, error)
+	FindByID(id uint) (*models.ReadingList, error)
+	FindByShareToken(token string) (*models.ReadingList, error)
+	FindByUserID(userID uint) ([]*models.ReadingList, error)
+	FindPublic(limit, offset int) ([]*models.ReadingList, error)
+	CountPublic() (int64, error)
+	Update(list *models.ReadingList) (*models.ReadingList, error)
+	Delete(id uint) error
+	AddItem(item *models.ReadingListItem) (*models.ReadingListItem, error)
+	FindItem(listID, itemID uint) (*models.ReadingListItem, error)
+	UpdateItem(item *models.ReadingListItem) (*models.ReadingListItem, error)
+	DeleteItem(item *models.ReadingListItem) error
+	Reorder(listID uint, itemIDs []uint) error
+}
+
+type ReadingListRepo struct {
+	DB *gorm.DB
+}
+
+func NewReadingListRepo(db *gorm.DB) ReadingListRepository {
+	return &ReadingListRepo{DB: db}
+}
+
+func (r *ReadingListRepo) Create(list *models.ReadingList) (*models.ReadingList, error) {
+	if err := r.DB.Create(list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *ReadingListRepo) FindByID(id uint) (*models.ReadingList, error) {
+	var list models.ReadingList
+	if err := r.withItems().First(&list, id).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *ReadingListRepo) FindByShareToken(token string) (*models.ReadingList, error) {
+	var list models.ReadingList
+	if err := r.withItems().Where("share_token = ?", token).First(&list).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *ReadingListRepo) FindByUserID(userID uint) ([]*models.ReadingList, error) {
+	var lists []*models.ReadingList
+	if err := r.DB.Where("user_id = ?", userID).Order("updated_at DESC, id DESC").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *ReadingListRepo) FindPublic(limit, offset int) ([]*models.ReadingList, error) {
+	var lists []*models.ReadingList
+	err := r.DB.Where("visibility = ?", models.ReadingListPublic).
+		Order("updated_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&lists).Error
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *ReadingListRepo) CountPublic() (int64, error) {
+	var count int64
+	if err := r.DB.Model(&models.ReadingList{}).Where("visibility = ?", models.ReadingListPublic).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *ReadingListRepo) Update(list *models.ReadingList) (*models.ReadingList, error) {
+	if err := r.DB.Omit("User", "Items").Save(list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *ReadingListRepo) Delete(id uint) error {
+	return r.DB.Delete(&models.ReadingList{}, id).Error
+}
+
+// AddItem appends the item at the end of its list. Adding a book the list
+// already holds returns gorm.ErrDuplicatedKey.
+func (r *ReadingListRepo) AddItem(item *models.ReadingListItem) (*models.ReadingListItem, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockList(tx, item.ReadingListID); err != nil {
+			return err
+		}
+		var lastPosition int
+		err := tx.Model(&models.ReadingListItem{}).
+			Where("reading_list_id = ?", item.ReadingListID).
+			Select("COALESCE(MAX(position), 0)").
+			Scan(&lastPosition).Error
+		if err != nil {
+			return err
+		}
+		item.Position = lastPosition + 1
+		return tx.Omit("Book").Create(item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *ReadingListRepo) FindItem(listID, itemID uint) (*models.ReadingListItem, error) {
+	var item models.ReadingListItem
+	if err := r.DB.Where("reading_list_id = ?", listID).First(&item, itemID).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *ReadingListRepo) UpdateItem(item *models.ReadingListItem) (*models.ReadingListItem, error) {
+	if err := r.DB.Omit("Book").Save(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// DeleteItem removes the item and closes the gap it leaves in the ordering.
+func (r *ReadingListRepo) DeleteItem(item *models.ReadingListItem) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockList(tx, item.ReadingListID); err != nil {
+			return err
+		}
+		if err := tx.Delete(item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ReadingListItem{}).
+			Where("reading_list_id = ? AND position > ?", item.ReadingListID, item.Position).
+			UpdateColumn("position", gorm.Expr("position - 1")).Error
+	})
+}
+
+// Reorder assigns positions following the order of itemIDs.
+func (r *ReadingListRepo) Reorder(listID uint, itemIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockList(tx, listID); err != nil {
+			return err
+		}
+		for i, itemID := range itemIDs {
+			err := tx.Model(&models.ReadingListItem{}).
+				Where("id = ? AND reading_list_id = ?", itemID, listID).
+				Update("position", i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lockList locks the list's row for the rest of tx, so that changes to the
+// positions of its items happen one at a time.
+func lockList(tx *gorm.DB, listID uint) error {
+	var list models.ReadingList
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&list, listID).Error
+}
+
+func (r *ReadingListRepo) withItems() *gorm.DB {
+	return r.DB.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC, id ASC")
+		}).
+		Preload("Items.Book")
+}
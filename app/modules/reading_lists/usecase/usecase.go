@@ -0,0 +1,302 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/reading_lists/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReadingListNotFound     = errors.New("reading list not found")
+	ErrReadingListItemNotFound = errors.New("reading list item not found")
+	ErrBookNotFound            = errors.New("book not found")
+	ErrBookAlreadyListed       = errors.New("book is already on this reading list")
+	ErrInvalidReorder          = errors.New("item_ids must list every item of the reading list exactly once")
+)
+
+type UseCase interface {
+	CreateReadingList(listInput *models.ReadingListInput, userID uint) (*models.ReadingListResponse, error)
+	GetReadingLists(userID uint) ([]*models.ReadingListResponse, error)
+	GetReadingList(listID, userID uint) (*models.ReadingListResponse, error)
+	UpdateReadingList(listInput *models.UpdateReadingList, userID uint) (*models.ReadingListResponse, error)
+	DeleteReadingList(listID, userID uint) error
+	AddItem(listID, userID uint, itemInput *models.ReadingListItemInput) (*models.ReadingListItemResponse, error)
+	UpdateItem(listID, itemID, userID uint, itemInput *models.UpdateReadingListItem) (*models.ReadingListItemResponse, error)
+	DeleteItem(listID, itemID, userID uint) error
+	Reorder(listID, userID uint, reorderInput *models.ReorderReadingListInput) (*models.ReadingListResponse, error)
+	GetPublicReadingLists(pagination *models.PaginationInput) ([]*models.ReadingListResponse, *models.PaginationMeta, error)
+	GetPublicReadingList(listID uint) (*models.ReadingListResponse, error)
+	GetSharedReadingList(token string) (*models.ReadingListResponse, error)
+}
+
+type ReadingListUseCase struct {
+	readingListRepo repository.ReadingListRepository
+	bookRepo        bookRepository.BookRepository
+}
+
+func NewReadingListUseCase(readingListRepo repository.ReadingListRepository, bookRepo bookRepository.BookRepository) UseCase {
+	return &ReadingListUseCase{readingListRepo: readingListRepo, bookRepo: bookRepo}
+}
+
+func (u *ReadingListUseCase) CreateReadingList(listInput *models.ReadingListInput, userID uint) (*models.ReadingListResponse, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.ReadingList{
+		UserID:      userID,
+		Name:        listInput.Name,
+		Description: listInput.Description,
+		Visibility:  listInput.Visibility,
+		ShareToken:  token,
+	}
+	if list.Visibility == "" {
+		list.Visibility = models.ReadingListPrivate
+	}
+
+	createdList, err := u.readingListRepo.Create(list)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReadingListRecord(createdList), nil
+}
+
+func (u *ReadingListUseCase) GetReadingLists(userID uint) ([]*models.ReadingListResponse, error) {
+	lists, err := u.readingListRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var listResponses []*models.ReadingListResponse
+	for _, list := range lists {
+		listResponses = append(listResponses, models.FilterReadingListRecord(list))
+	}
+	return listResponses, nil
+}
+
+func (u *ReadingListUseCase) GetReadingList(listID, userID uint) (*models.ReadingListResponse, error) {
+	list, err := u.findOwnedList(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReadingListRecord(list), nil
+}
+
+func (u *ReadingListUseCase) UpdateReadingList(listInput *models.UpdateReadingList, userID uint) (*models.ReadingListResponse, error) {
+	list, err := u.findOwnedList(listInput.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list.Name = listInput.Name
+	list.Description = listInput.Description
+	if listInput.Visibility != "" {
+		list.Visibility = listInput.Visibility
+	}
+
+	updatedList, err := u.readingListRepo.Update(list)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReadingListRecord(updatedList), nil
+}
+
+func (u *ReadingListUseCase) DeleteReadingList(listID, userID uint) error {
+	if _, err := u.findOwnedList(listID, userID); err != nil {
+		return err
+	}
+	return u.readingListRepo.Delete(listID)
+}
+
+func (u *ReadingListUseCase) AddItem(listID, userID uint, itemInput *models.ReadingListItemInput) (*models.ReadingListItemResponse, error) {
+	list, err := u.findOwnedList(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range list.Items {
+		if item.BookID == itemInput.BookID {
+			return nil, ErrBookAlreadyListed
+		}
+	}
+
+	book, err := u.bookRepo.FindByID(itemInput.BookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := u.readingListRepo.AddItem(&models.ReadingListItem{
+		ReadingListID: list.ID,
+		BookID:        book.ID,
+		Note:          itemInput.Note,
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrBookAlreadyListed
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReadingListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	item.Book = *book
+	return models.FilterReadingListItemRecord(item), nil
+}
+
+func (u *ReadingListUseCase) UpdateItem(listID, itemID, userID uint, itemInput *models.UpdateReadingListItem) (*models.ReadingListItemResponse, error) {
+	item, err := u.findOwnedItem(listID, itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Note = itemInput.Note
+
+	updatedItem, err := u.readingListRepo.UpdateItem(item)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReadingListItemRecord(updatedItem), nil
+}
+
+func (u *ReadingListUseCase) DeleteItem(listID, itemID, userID uint) error {
+	item, err := u.findOwnedItem(listID, itemID, userID)
+	if err != nil {
+		return err
+	}
+	return u.readingListRepo.DeleteItem(item)
+}
+
+// Reorder takes the complete new order of the list's items.
+func (u *ReadingListUseCase) Reorder(listID, userID uint, reorderInput *models.ReorderReadingListInput) (*models.ReadingListResponse, error) {
+	list, err := u.findOwnedList(listID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reorderInput.ItemIDs) != len(list.Items) {
+		return nil, ErrInvalidReorder
+	}
+	remaining := make(map[uint]bool, len(list.Items))
+	for _, item := range list.Items {
+		remaining[item.ID] = true
+	}
+	for _, itemID := range reorderInput.ItemIDs {
+		if !remaining[itemID] {
+			return nil, ErrInvalidReorder
+		}
+		delete(remaining, itemID)
+	}
+
+	if err := u.readingListRepo.Reorder(list.ID, reorderInput.ItemIDs); err != nil {
+		return nil, err
+	}
+	return u.GetReadingList(list.ID, userID)
+}
+
+func (u *ReadingListUseCase) GetPublicReadingLists(pagination *models.PaginationInput) ([]*models.ReadingListResponse, *models.PaginationMeta, error) {
+	total, err := u.readingListRepo.CountPublic()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lists, err := u.readingListRepo.FindPublic(pagination.PerPage, pagination.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listResponses := make([]*models.ReadingListResponse, 0, len(lists))
+	for _, list := range lists {
+		listResponses = append(listResponses, filterSharedReadingList(list))
+	}
+	meta := models.NewPaginationMeta(pagination, total)
+	return listResponses, &meta, nil
+}
+
+func (u *ReadingListUseCase) GetPublicReadingList(listID uint) (*models.ReadingListResponse, error) {
+	list, err := u.findList(listID)
+	if err != nil {
+		return nil, err
+	}
+	if list.Visibility != models.ReadingListPublic {
+		return nil, ErrReadingListNotFound
+	}
+	return filterSharedReadingList(list), nil
+}
+
+// GetSharedReadingList resolves a share link, which works for unlisted and
+// public lists but never for private ones.
+func (u *ReadingListUseCase) GetSharedReadingList(token string) (*models.ReadingListResponse, error) {
+	list, err := u.readingListRepo.FindByShareToken(token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReadingListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if list.Visibility == models.ReadingListPrivate {
+		return nil, ErrReadingListNotFound
+	}
+	return filterSharedReadingList(list), nil
+}
+
+func (u *ReadingListUseCase) findList(listID uint) (*models.ReadingList, error) {
+	list, err := u.readingListRepo.FindByID(listID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReadingListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// findOwnedList hides other users' lists behind a not found error.
+func (u *ReadingListUseCase) findOwnedList(listID, userID uint) (*models.ReadingList, error) {
+	list, err := u.findList(listID)
+	if err != nil {
+		return nil, err
+	}
+	if list.UserID != userID {
+		return nil, ErrReadingListNotFound
+	}
+	return list, nil
+}
+
+func (u *ReadingListUseCase) findOwnedItem(listID, itemID, userID uint) (*models.ReadingListItem, error) {
+	if _, err := u.findOwnedList(listID, userID); err != nil {
+		return nil, err
+	}
+
+	item, err := u.readingListRepo.FindItem(listID, itemID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReadingListItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// filterSharedReadingList never exposes the share token to other users.
+func filterSharedReadingList(list *models.ReadingList) *models.ReadingListResponse {
+	response := models.FilterReadingListRecord(list)
+	response.ShareToken = ""
+	return response
+}
+
+func newShareToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
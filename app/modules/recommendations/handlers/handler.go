@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	recommendation "github.com/1rhino/clean_architecture/app/modules/recommendations/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type RecommendationHandlers struct {
+	recommendationUseCase recommendation.UseCase
+}
+
+func NewRecommendationHandlers(recommendationUseCase recommendation.UseCase) *RecommendationHandlers {
+	return &RecommendationHandlers{recommendationUseCase: recommendationUseCase}
+}
+
+// get books similar to a book
+func (h *RecommendationHandlers) GetSimilarBooks(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	userID, query, ok := userAndQuery(c)
+	if !ok {
+		return
+	}
+
+	books, err := h.recommendationUseCase.GetSimilarBooks(uint(bookID), userID, query)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": books})
+}
+
+// get personalized recommendations for the user
+func (h *RecommendationHandlers) GetRecommendations(c *gin.Context) {
+	userID, query, ok := userAndQuery(c)
+	if !ok {
+		return
+	}
+
+	books, err := h.recommendationUseCase.GetRecommendations(userID, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": books})
+}
+
+func userAndQuery(c *gin.Context) (uint, *models.RecommendationQuery, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, nil, false
+	}
+
+	var query models.RecommendationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, nil, false
+	}
+	query.Normalize()
+
+	return userID, &query, true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, recommendation.ErrBookNotFound):
+		return http.StatusNotFound
+	default:
+		return fallback
+	}
+}
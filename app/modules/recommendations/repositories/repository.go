@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+)
+
+const insertBatchSize = 500
+
+type RecommendationRepository interface {
+	LoadBooks() ([]*models.Book, error)
+	LoadFavorites() ([]*models.Favorite, error)
+	ReplaceSimilarities(similarities []*models.BookSimilarity) error
+	ReplaceRecommendations(recommendations []*models.UserRecommendation) error
+	FindSimilar(bookID uint, limit int) ([]*models.BookSimilarity, error)
+	FindRecommendations(userID uint, limit int) ([]*models.UserRecommendation, error)
+	FindPopular(userID uint, limit int) ([]*models.Book, error)
+}
+
+type RecommendationRepo struct {
+	DB *gorm.DB
+}
+
+func NewRecommendationRepo(db *gorm.DB) RecommendationRepository {
+	return &RecommendationRepo{DB: db}
+}
+
+// LoadBooks reads only the columns the similarity model looks at.
+func (r *RecommendationRepo) LoadBooks() ([]*models.Book, error) {
+	var books []*models.Book
+	err := r.DB.Select("id", "user_id", "category_id", "author", "tags", "description").
+		Order("id ASC").
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (r *RecommendationRepo) LoadFavorites() ([]*models.Favorite, error) {
+	var favorites []*models.Favorite
+	if err := r.DB.Select("user_id", "book_id").Find(&favorites).Error; err != nil {
+		return nil, err
+	}
+	return favorites, nil
+}
+
+// ReplaceSimilarities swaps the whole table in one transaction so readers
+// never see a half refreshed set.
+func (r *RecommendationRepo) ReplaceSimilarities(similarities []*models.BookSimilarity) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.BookSimilarity{}).Error; err != nil {
+			return err
+		}
+		if len(similarities) == 0 {
+			return nil
+		}
+		return tx.Omit("SimilarBook").CreateInBatches(similarities, insertBatchSize).Error
+	})
+}
+
+func (r *RecommendationRepo) ReplaceRecommendations(recommendations []*models.UserRecommendation) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.UserRecommendation{}).Error; err != nil {
+			return err
+		}
+		if len(recommendations) == 0 {
+			return nil
+		}
+		return tx.Omit("Book").CreateInBatches(recommendations, insertBatchSize).Error
+	})
+}
+
+// FindSimilar skips books deleted since the last refresh.
+func (r *RecommendationRepo) FindSimilar(bookID uint, limit int) ([]*models.BookSimilarity, error) {
+	var similarities []*models.BookSimilarity
+	err := r.DB.InnerJoins("SimilarBook").
+		Where("book_similarities.book_id = ?", bookID).
+		Order("book_similarities.score DESC, book_similarities.similar_book_id ASC").
+		Limit(limit).
+		Find(&similarities).Error
+	if err != nil {
+		return nil, err
+	}
+	return similarities, nil
+}
+
+func (r *RecommendationRepo) FindRecommendations(userID uint, limit int) ([]*models.UserRecommendation, error) {
+	var recommendations []*models.UserRecommendation
+	err := r.DB.InnerJoins("Book").
+		Where("user_recommendations.user_id = ?", userID).
+		Order("user_recommendations.score DESC, user_recommendations.book_id ASC").
+		Limit(limit).
+		Find(&recommendations).Error
+	if err != nil {
+		return nil, err
+	}
+	return recommendations, nil
+}
+
+// FindPopular is the fallback for users without any activity yet: the most
+// favorited books they neither own nor have favorited.
+func (r *RecommendationRepo) FindPopular(userID uint, limit int) ([]*models.Book, error) {
+	var books []*models.Book
+	err := r.DB.Where("user_id <> ?", userID).
+		Where("id NOT IN (?)", r.DB.Model(&models.Favorite{}).Select("book_id").Where("user_id = ?", userID)).
+		Order("favorites_count DESC, id DESC").
+		Limit(limit).
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
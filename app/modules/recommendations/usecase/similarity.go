@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/1rhino/clean_architecture/app/models"
+)
+
+// Weights of the signals that make up the similarity score. They add up to
+// one, so a score of 1 means same category, same author, same tags and the
+// same description.
+const (
+	categoryWeight    = 0.3
+	authorWeight      = 0.2
+	tagWeight         = 0.2
+	descriptionWeight = 0.3
+)
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"from": true, "are": true, "was": true, "were": true, "his": true, "her": true,
+	"its": true, "their": true, "into": true, "about": true, "but": true, "not": true,
+	"you": true, "your": true, "has": true, "have": true, "had": true, "who": true,
+	"which": true, "when": true, "what": true, "will": true, "one": true, "all": true,
+}
+
+// bookProfile holds everything the similarity model needs about a book.
+type bookProfile struct {
+	id         uint
+	categoryID *uint
+	author     string
+	tags       map[string]bool
+	terms      map[string]float64
+}
+
+type scoredBook struct {
+	bookID uint
+	score  float64
+}
+
+// buildProfiles turns books into profiles whose description terms are
+// TF-IDF weighted and normalised to unit length, so the cosine similarity
+// of two descriptions is the dot product of their term maps.
+func buildProfiles(books []*models.Book) []*bookProfile {
+	documentFrequency := make(map[string]int)
+	termCounts := make([]map[string]int, len(books))
+	for i, book := range books {
+		counts := make(map[string]int)
+		for _, term := range tokenize(book.Description) {
+			counts[term]++
+		}
+		for term := range counts {
+			documentFrequency[term]++
+		}
+		termCounts[i] = counts
+	}
+
+	total := float64(len(books))
+	profiles := make([]*bookProfile, len(books))
+	for i, book := range books {
+		terms := make(map[string]float64, len(termCounts[i]))
+		var norm float64
+		for term, count := range termCounts[i] {
+			idf := math.Log((1+total)/(1+float64(documentFrequency[term]))) + 1
+			weight := float64(count) * idf
+			terms[term] = weight
+			norm += weight * weight
+		}
+		norm = math.Sqrt(norm)
+		for term := range terms {
+			terms[term] /= norm
+		}
+
+		tags := make(map[string]bool)
+		for _, tag := range book.TagList() {
+			tags[tag] = true
+		}
+
+		profiles[i] = &bookProfile{
+			id:         book.ID,
+			categoryID: book.CategoryID,
+			author:     strings.ToLower(strings.TrimSpace(book.Author)),
+			tags:       tags,
+			terms:      terms,
+		}
+	}
+	return profiles
+}
+
+func similarity(a, b *bookProfile) float64 {
+	var score float64
+	if a.categoryID != nil && b.categoryID != nil && *a.categoryID == *b.categoryID {
+		score += categoryWeight
+	}
+	if a.author != "" && a.author == b.author {
+		score += authorWeight
+	}
+	score += tagWeight * jaccard(a.tags, b.tags)
+	score += descriptionWeight * cosine(a.terms, b.terms)
+	return score
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for tag := range a {
+		if b[tag] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func cosine(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var dot float64
+	for term, weight := range a {
+		dot += weight * b[term]
+	}
+	return dot
+}
+
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) < 3 || stopWords[word] {
+			continue
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// topScored keeps the highest scores, breaking ties by book id so refreshes
+// are stable.
+func topScored(scores map[uint]float64, limit int) []scoredBook {
+	ranked := make([]scoredBook, 0, len(scores))
+	for bookID, score := range scores {
+		ranked = append(ranked, scoredBook{bookID: bookID, score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].bookID < ranked[j].bookID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
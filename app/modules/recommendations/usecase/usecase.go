@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/recommendations/repositories"
+	"gorm.io/gorm"
+)
+
+var ErrBookNotFound = errors.New("book not found")
+
+const (
+	// similarPerBook and recommendationsPerUser bound the size of the
+	// precomputed tables.
+	similarPerBook         = 20
+	recommendationsPerUser = models.MaxRecommendationLimit
+)
+
+type UseCase interface {
+	GetSimilarBooks(bookID, userID uint, query *models.RecommendationQuery) ([]*models.RecommendedBookResponse, error)
+	GetRecommendations(userID uint, query *models.RecommendationQuery) ([]*models.RecommendedBookResponse, error)
+	RefreshRecommendations(ctx context.Context) error
+}
+
+type RecommendationUseCase struct {
+	recommendationRepo repository.RecommendationRepository
+	bookRepo           bookRepository.BookRepository
+	clock              clock.Clock
+}
+
+func NewRecommendationUseCase(recommendationRepo repository.RecommendationRepository, bookRepo bookRepository.BookRepository, clk clock.Clock) UseCase {
+	return &RecommendationUseCase{recommendationRepo: recommendationRepo, bookRepo: bookRepo, clock: clk}
+}
+
+func (u *RecommendationUseCase) GetSimilarBooks(bookID, userID uint, query *models.RecommendationQuery) ([]*models.RecommendedBookResponse, error) {
+	_, err := u.bookRepo.FindByID(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	similarities, err := u.recommendationRepo.FindSimilar(bookID, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*models.Book, 0, len(similarities))
+	scores := make([]float64, 0, len(similarities))
+	for _, similar := range similarities {
+		books = append(books, &similar.SimilarBook)
+		scores = append(scores, similar.Score)
+	}
+	return u.filterRecommended(books, scores, userID)
+}
+
+// GetRecommendations falls back to the most favorited books for users who
+// have neither books nor favorites yet.
+func (u *RecommendationUseCase) GetRecommendations(userID uint, query *models.RecommendationQuery) ([]*models.RecommendedBookResponse, error) {
+	recommendations, err := u.recommendationRepo.FindRecommendations(userID, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recommendations) == 0 {
+		popular, err := u.recommendationRepo.FindPopular(userID, query.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return u.filterRecommended(popular, make([]float64, len(popular)), userID)
+	}
+
+	books := make([]*models.Book, 0, len(recommendations))
+	scores := make([]float64, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		books = append(books, &recommendation.Book)
+		scores = append(scores, recommendation.Score)
+	}
+	return u.filterRecommended(books, scores, userID)
+}
+
+// RefreshRecommendations is run by the scheduler. It rebuilds the similar
+// books of every book, then scores each user's candidates by summing their
+// similarity to the user's own and favorited books. Comparing every pair of
+// books is quadratic, which is fine at library scale.
+func (u *RecommendationUseCase) RefreshRecommendations(ctx context.Context) error {
+	started := time.Now()
+
+	books, err := u.recommendationRepo.LoadBooks()
+	if err != nil {
+		return err
+	}
+	favorites, err := u.recommendationRepo.LoadFavorites()
+	if err != nil {
+		return err
+	}
+
+	now := u.clock.Now()
+	profiles := buildProfiles(books)
+
+	similarByBook := make(map[uint][]scoredBook, len(profiles))
+	var similarities []*models.BookSimilarity
+	for _, profile := range profiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		scores := make(map[uint]float64)
+		for _, other := range profiles {
+			if other.id == profile.id {
+				continue
+			}
+			if score := similarity(profile, other); score > 0 {
+				scores[other.id] = score
+			}
+		}
+
+		top := topScored(scores, similarPerBook)
+		similarByBook[profile.id] = top
+		for _, similar := range top {
+			similarities = append(similarities, &models.BookSimilarity{
+				BookID:        profile.id,
+				SimilarBookID: similar.bookID,
+				Score:         roundScore(similar.score),
+				ComputedAt:    now,
+			})
+		}
+	}
+
+	seedsByUser := make(map[uint]map[uint]bool)
+	addSeed := func(userID, bookID uint) {
+		if seedsByUser[userID] == nil {
+			seedsByUser[userID] = make(map[uint]bool)
+		}
+		seedsByUser[userID][bookID] = true
+	}
+	for _, book := range books {
+		addSeed(book.UserID, book.ID)
+	}
+	for _, favorite := range favorites {
+		addSeed(favorite.UserID, favorite.BookID)
+	}
+
+	var recommendations []*models.UserRecommendation
+	for userID, seeds := range seedsByUser {
+		scores := make(map[uint]float64)
+		for seedID := range seeds {
+			for _, similar := range similarByBook[seedID] {
+				if !seeds[similar.bookID] {
+					scores[similar.bookID] += similar.score
+				}
+			}
+		}
+
+		for _, candidate := range topScored(scores, recommendationsPerUser) {
+			recommendations = append(recommendations, &models.UserRecommendation{
+				UserID:     userID,
+				BookID:     candidate.bookID,
+				Score:      roundScore(candidate.score),
+				ComputedAt: now,
+			})
+		}
+	}
+
+	if err := u.recommendationRepo.ReplaceSimilarities(similarities); err != nil {
+		return err
+	}
+	if err := u.recommendationRepo.ReplaceRecommendations(recommendations); err != nil {
+		return err
+	}
+
+	log.Printf("refreshed recommendations: %d books, %d similar pairs, %d user recommendations in %s",
+		len(books), len(similarities), len(recommendations), time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+func (u *RecommendationUseCase) filterRecommended(books []*models.Book, scores []float64, userID uint) ([]*models.RecommendedBookResponse, error) {
+	bookIDs := make([]uint, 0, len(books))
+	for _, book := range books {
+		bookIDs = append(bookIDs, book.ID)
+	}
+
+	favorited, err := u.bookRepo.FavoritedBookIDs(userID, bookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	recommended := []*models.RecommendedBookResponse{}
+	for i, book := range books {
+		bookResponse := models.FilterBookRecord(book)
+		bookResponse.IsFavorited = favorited[book.ID]
+		recommended = append(recommended, &models.RecommendedBookResponse{
+			Score: scores[i],
+			Book:  bookResponse,
+		})
+	}
+	return recommended, nil
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*10000) / 10000
+}
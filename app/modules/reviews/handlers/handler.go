@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	review "github.com/1rhino/clean_architecture/app/modules/reviews/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewHandlers struct {
+	reviewUseCase review.UseCase
+}
+
+func NewReviewHandlers(reviewUseCase review.UseCase) *ReviewHandlers {
+	return &ReviewHandlers{reviewUseCase: reviewUseCase}
+}
+
+// create a new review
+func (h *ReviewHandlers) CreateReview(c *gin.Context) {
+	var reviewInput models.ReviewInput
+
+	if err := c.ShouldBind(&reviewInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdReview, err := h.reviewUseCase.CreateReview(&reviewInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": createdReview})
+}
+
+// get paginated reviews of a book
+func (h *ReviewHandlers) GetBookReviews(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	var query models.ReviewQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Normalize()
+
+	reviews, meta, err := h.reviewUseCase.GetBookReviews(uint(bookID), &query)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reviews, "meta": meta})
+}
+
+// get list reviews by userID
+func (h *ReviewHandlers) GetReviews(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviews, err := h.reviewUseCase.GetReviews(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reviews})
+}
+
+// get review detail
+func (h *ReviewHandlers) GetReviewDetail(c *gin.Context) {
+	reviewID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	getReview, err := h.reviewUseCase.GetReview(uint(reviewID))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, getReview)
+}
+
+// update review
+func (h *ReviewHandlers) UpdateReview(c *gin.Context) {
+	var reviewInput models.UpdateReview
+
+	if err := c.ShouldBind(&reviewInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviewID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviewInput.ID = uint(reviewID)
+	updatedReview, err := h.reviewUseCase.UpdateReview(&reviewInput, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": updatedReview})
+}
+
+// delete review
+func (h *ReviewHandlers) DeleteReview(c *gin.Context) {
+	reviewID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.reviewUseCase.DeleteReview(uint(reviewID), userID)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to delete review"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, review.ErrBookNotFound), errors.Is(err, review.ErrReviewNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, review.ErrReviewExists):
+		return http.StatusConflict
+	case errors.Is(err, review.ErrReviewForbidden):
+		return http.StatusForbidden
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReviewRepository interface {
+	Create(review *models.Review) (*models.Review, error)
+	FindByID(id uint) (*models.Review, error)
+	FindByBookAndUser(bookID, userID uint) (*models.Review, error)
+	FindByBookID(bookID uint, order string, limit, offset int) ([]*models.Review, error)
+	CountByBookID(bookID uint) (int64, error)
+	FindByUserID(userID uint) ([]*models.Review, error)
+	Update(review *models.Review) (*models.Review, error)
+	Delete(id uint) error
+}
+
+type ReviewRepo struct {
+	DB *gorm.DB
+}
+
+func NewReviewRepo(db *gorm.DB) ReviewRepository {
+	return &ReviewRepo{DB: db}
+}
+
+// Create inserts the review and adds its rating to the book aggregates in
+// the same transaction. A second review by the same user for the same book
+// returns gorm.ErrDuplicatedKey.
+func (r *ReviewRepo) Create(review *models.Review) (*models.Review, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Book{}).Where("id = ?", review.BookID).UpdateColumns(map[string]interface{}{
+			"rating_count": gorm.Expr("rating_count + 1"),
+			"rating_sum":   gorm.Expr("rating_sum + ?", review.Rating),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (r *ReviewRepo) FindByID(id uint) (*models.Review, error) {
+	var review models.Review
+	if err := r.DB.First(&review, id).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *ReviewRepo) FindByBookAndUser(bookID, userID uint) (*models.Review, error) {
+	var review models.Review
+	if err := r.DB.Where("book_id = ? AND user_id = ?", bookID, userID).First(&review).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *ReviewRepo) FindByBookID(bookID uint, order string, limit, offset int) ([]*models.Review, error) {
+	var reviews []*models.Review
+	err := r.DB.Where("book_id = ?", bookID).
+		Order(order).
+		Limit(limit).
+		Offset(offset).
+		Find(&reviews).Error
+	if err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *ReviewRepo) CountByBookID(bookID uint) (int64, error) {
+	var count int64
+	if err := r.DB.Model(&models.Review{}).Where("book_id = ?", bookID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *ReviewRepo) FindByUserID(userID uint) ([]*models.Review, error) {
+	var reviews []*models.Review
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// Update saves the review and applies the rating difference to the book
+// aggregates. The stored row is locked so concurrent edits can't both
+// apply a delta computed from the same previous rating.
+func (r *ReviewRepo) Update(review *models.Review) (*models.Review, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.Review
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&previous, review.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(review).Error; err != nil {
+			return err
+		}
+		if delta := review.Rating - previous.Rating; delta != 0 {
+			return tx.Model(&models.Book{}).Where("id = ?", review.BookID).
+				UpdateColumn("rating_sum", gorm.Expr("rating_sum + ?", delta)).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// Delete removes the review for good, so the user can review the book
+// again, and takes its rating out of the book aggregates.
+func (r *ReviewRepo) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var review models.Review
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&review).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Book{}).Where("id = ?", review.BookID).UpdateColumns(map[string]interface{}{
+			"rating_count": gorm.Expr("rating_count - 1"),
+			"rating_sum":   gorm.Expr("rating_sum - ?", review.Rating),
+		}).Error
+	})
+}
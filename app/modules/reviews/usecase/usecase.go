@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/reviews/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound    = errors.New("book not found")
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrReviewExists    = errors.New("you have already reviewed this book")
+	ErrReviewForbidden = errors.New("you can only change your own reviews")
+)
+
+type UseCase interface {
+	CreateReview(reviewInput *models.ReviewInput, userID uint) (*models.ReviewResponse, error)
+	GetBookReviews(bookID uint, query *models.ReviewQuery) ([]*models.ReviewResponse, *models.PaginationMeta, error)
+	GetReviews(userID uint) ([]*models.ReviewResponse, error)
+	GetReview(reviewID uint) (*models.ReviewResponse, error)
+	UpdateReview(reviewInput *models.UpdateReview, userID uint) (*models.ReviewResponse, error)
+	DeleteReview(reviewID uint, userID uint) error
+}
+
+type ReviewUseCase struct {
+	reviewRepo repository.ReviewRepository
+	bookRepo   bookRepository.BookRepository
+}
+
+func NewReviewUseCase(reviewRepo repository.ReviewRepository, bookRepo bookRepository.BookRepository) UseCase {
+	return &ReviewUseCase{reviewRepo: reviewRepo, bookRepo: bookRepo}
+}
+
+func (u *ReviewUseCase) CreateReview(reviewInput *models.ReviewInput, userID uint) (*models.ReviewResponse, error) {
+	if err := u.checkBook(reviewInput.BookID); err != nil {
+		return nil, err
+	}
+
+	_, err := u.reviewRepo.FindByBookAndUser(reviewInput.BookID, userID)
+	if err == nil {
+		return nil, ErrReviewExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	review := &models.Review{
+		BookID:  reviewInput.BookID,
+		UserID:  userID,
+		Rating:  reviewInput.Rating,
+		Title:   reviewInput.Title,
+		Content: reviewInput.Content,
+	}
+
+	createdReview, err := u.reviewRepo.Create(review)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrReviewExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReviewRecord(createdReview), nil
+}
+
+func (u *ReviewUseCase) GetBookReviews(bookID uint, query *models.ReviewQuery) ([]*models.ReviewResponse, *models.PaginationMeta, error) {
+	if err := u.checkBook(bookID); err != nil {
+		return nil, nil, err
+	}
+
+	total, err := u.reviewRepo.CountByBookID(bookID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reviews, err := u.reviewRepo.FindByBookID(bookID, query.OrderClause(), query.PerPage, query.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reviewResponses := make([]*models.ReviewResponse, 0, len(reviews))
+	for _, review := range reviews {
+		reviewResponses = append(reviewResponses, models.FilterReviewRecord(review))
+	}
+	meta := models.NewPaginationMeta(&query.PaginationInput, total)
+	return reviewResponses, &meta, nil
+}
+
+func (u *ReviewUseCase) GetReviews(userID uint) ([]*models.ReviewResponse, error) {
+	reviews, err := u.reviewRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reviewResponses []*models.ReviewResponse
+	for _, review := range reviews {
+		reviewResponses = append(reviewResponses, models.FilterReviewRecord(review))
+	}
+	return reviewResponses, nil
+}
+
+func (u *ReviewUseCase) GetReview(reviewID uint) (*models.ReviewResponse, error) {
+	review, err := u.findReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReviewRecord(review), nil
+}
+
+func (u *ReviewUseCase) UpdateReview(reviewInput *models.UpdateReview, userID uint) (*models.ReviewResponse, error) {
+	review, err := u.findReview(reviewInput.ID)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID != userID {
+		return nil, ErrReviewForbidden
+	}
+
+	if reviewInput.Rating != 0 {
+		review.Rating = reviewInput.Rating
+	}
+	if reviewInput.Title != nil {
+		review.Title = *reviewInput.Title
+	}
+	if reviewInput.Content != nil {
+		review.Content = *reviewInput.Content
+	}
+
+	updatedReview, err := u.reviewRepo.Update(review)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterReviewRecord(updatedReview), nil
+}
+
+func (u *ReviewUseCase) DeleteReview(reviewID uint, userID uint) error {
+	review, err := u.findReview(reviewID)
+	if err != nil {
+		return err
+	}
+	if review.UserID != userID {
+		return ErrReviewForbidden
+	}
+
+	return u.reviewRepo.Delete(review.ID)
+}
+
+func (u *ReviewUseCase) findReview(reviewID uint) (*models.Review, error) {
+	review, err := u.reviewRepo.FindByID(reviewID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (u *ReviewUseCase) checkBook(bookID uint) error {
+	_, err := u.bookRepo.FindByID(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookNotFound
+	}
+	return err
+}
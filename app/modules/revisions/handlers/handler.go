@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	revision "github.com/1rhino/clean_architecture/app/modules/revisions/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+// RevisionHandlers serve the history of any versioned entity; each method
+// returns the handler for one entity type.
+type RevisionHandlers struct {
+	revisionUseCase revision.UseCase
+}
+
+func NewRevisionHandlers(revisionUseCase revision.UseCase) *RevisionHandlers {
+	return &RevisionHandlers{revisionUseCase: revisionUseCase}
+}
+
+// get the paginated history of a record
+func (h *RevisionHandlers) GetHistory(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entityID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		var query models.RevisionQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query.Normalize()
+
+		revisions, meta, err := h.revisionUseCase.GetHistory(entityType, uint(entityID), &query)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": revisions, "meta": meta})
+	}
+}
+
+// get one revision of a record with its full snapshot
+func (h *RevisionHandlers) GetRevision(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entityID, number, ok := ParseRevisionParams(c)
+		if !ok {
+			return
+		}
+
+		getRevision, err := h.revisionUseCase.GetRevision(entityType, entityID, number)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, getRevision)
+	}
+}
+
+// ParseRevisionParams reads the :id and :revision path parameters, writing
+// a 400 response when either is invalid.
+func ParseRevisionParams(c *gin.Context) (uint, int, bool) {
+	entityID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, 0, false
+	}
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return 0, 0, false
+	}
+
+	return uint(entityID), revision, true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, revision.ErrHistoryNotFound), errors.Is(err, revision.ErrRevisionNotFound):
+		return http.StatusNotFound
+	default:
+		return fallback
+	}
+}
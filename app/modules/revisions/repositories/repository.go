@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+)
+
+type RevisionRepository interface {
+	FindByEntity(entityType string, entityID uint, limit, offset int) ([]*models.Revision, error)
+	CountByEntity(entityType string, entityID uint) (int64, error)
+	FindRevision(entityType string, entityID uint, number int) (*models.Revision, error)
+}
+
+type RevisionRepo struct {
+	DB *gorm.DB
+}
+
+func NewRevisionRepo(db *gorm.DB) RevisionRepository {
+	return &RevisionRepo{DB: db}
+}
+
+func (r *RevisionRepo) FindByEntity(entityType string, entityID uint, limit, offset int) ([]*models.Revision, error) {
+	var revisions []*models.Revision
+	err := r.DB.Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("version DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *RevisionRepo) CountByEntity(entityType string, entityID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.Revision{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *RevisionRepo) FindRevision(entityType string, entityID uint, number int) (*models.Revision, error) {
+	var revision models.Revision
+	err := r.DB.Where("entity_type = ? AND entity_id = ? AND version = ?", entityType, entityID, number).
+		First(&revision).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RecordRevision appends the next version of an entity. before is nil for
+// a create and after is nil for a delete; updates that change nothing are
+// not recorded. The first change to a row that predates history also
+// records its previous state as a baseline version. It must run inside the
+// transaction that writes the entity, with the entity row locked, so
+// versions are assigned one at a time.
+func RecordRevision(tx *gorm.DB, entityType string, entityID, actorID uint, action string, before, after interface{}) error {
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	var changes []models.RevisionChange
+	if beforeJSON != nil && afterJSON != nil {
+		changes, err = diff(beforeJSON, afterJSON)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+	}
+
+	var version int
+	err = tx.Model(&models.Revision{}).
+		Select("COALESCE(MAX(version), 0)").
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Scan(&version).Error
+	if err != nil {
+		return err
+	}
+
+	if version == 0 && beforeJSON != nil {
+		version++
+		baseline := &models.Revision{
+			EntityType: entityType,
+			EntityID:   entityID,
+			Version:    version,
+			Action:     models.RevisionActionBaseline,
+			Snapshot:   string(beforeJSON),
+			Changes:    "[]",
+		}
+		if err := tx.Create(baseline).Error; err != nil {
+			return err
+		}
+	}
+
+	snapshot := afterJSON
+	if snapshot == nil {
+		snapshot = beforeJSON
+	}
+	if changes == nil {
+		changes = []models.RevisionChange{}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.Revision{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Version:    version + 1,
+		Action:     action,
+		ActorID:    actorID,
+		Snapshot:   string(snapshot),
+		Changes:    string(changesJSON),
+	}).Error
+}
+
+// ChangedFields lists the snapshot fields that differ between before and
+// after. Snapshot fields are named after their columns, so the result can
+// restrict an update to the columns that changed.
+func ChangedFields(before, after interface{}) ([]string, error) {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diff(beforeJSON, afterJSON)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	return fields, nil
+}
+
+func marshalSnapshot(snapshot interface{}) ([]byte, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
+
+// diff compares two snapshots field by field, in field name order.
+func diff(before, after []byte) ([]models.RevisionChange, error) {
+	var beforeFields, afterFields map[string]json.RawMessage
+	if err := json.Unmarshal(before, &beforeFields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &afterFields); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(afterFields))
+	for field := range afterFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var changes []models.RevisionChange
+	for _, field := range fields {
+		if bytes.Equal(beforeFields[field], afterFields[field]) {
+			continue
+		}
+		changes = append(changes, models.RevisionChange{
+			Field: field,
+			From:  beforeFields[field],
+			To:    afterFields[field],
+		})
+	}
+	return changes, nil
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	repository "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrHistoryNotFound  = errors.New("no history recorded for this record")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+type UseCase interface {
+	GetHistory(entityType string, entityID uint, query *models.RevisionQuery) ([]*models.RevisionResponse, *models.PaginationMeta, error)
+	GetRevision(entityType string, entityID uint, number int) (*models.RevisionResponse, error)
+}
+
+type RevisionUseCase struct {
+	revisionRepo repository.RevisionRepository
+}
+
+func NewRevisionUseCase(revisionRepo repository.RevisionRepository) UseCase {
+	return &RevisionUseCase{revisionRepo: revisionRepo}
+}
+
+// GetHistory lists the revisions of a record, newest first. History stays
+// readable after the record itself is deleted.
+func (u *RevisionUseCase) GetHistory(entityType string, entityID uint, query *models.RevisionQuery) ([]*models.RevisionResponse, *models.PaginationMeta, error) {
+	total, err := u.revisionRepo.CountByEntity(entityType, entityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if total == 0 {
+		return nil, nil, ErrHistoryNotFound
+	}
+
+	revisions, err := u.revisionRepo.FindByEntity(entityType, entityID, query.PerPage, query.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revisionResponses := []*models.RevisionResponse{}
+	for _, revision := range revisions {
+		revisionResponses = append(revisionResponses, models.FilterRevisionRecord(revision))
+	}
+
+	meta := models.NewPaginationMeta(&query.PaginationInput, total)
+	return revisionResponses, &meta, nil
+}
+
+func (u *RevisionUseCase) GetRevision(entityType string, entityID uint, number int) (*models.RevisionResponse, error) {
+	revision, err := u.revisionRepo.FindRevision(entityType, entityID, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revisionResponse := models.FilterRevisionRecord(revision)
+	revisionResponse.Snapshot = json.RawMessage(revision.Snapshot)
+	return revisionResponse, nil
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	shelf "github.com/1rhino/clean_architecture/app/modules/shelves/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ShelfHandlers struct {
+	shelfUseCase shelf.UseCase
+}
+
+func NewShelfHandlers(shelfUseCase shelf.UseCase) *ShelfHandlers {
+	return &ShelfHandlers{shelfUseCase: shelfUseCase}
+}
+
+// get the user's shelf, optionally filtered by status
+func (h *ShelfHandlers) GetShelf(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query models.ShelfQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Normalize()
+
+	entries, meta, err := h.shelfUseCase.GetShelf(userID, &query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries, "meta": meta})
+}
+
+// get a shelf entry with its progress history
+func (h *ShelfHandlers) GetShelfEntry(c *gin.Context) {
+	userID, bookID, ok := userAndBookID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.shelfUseCase.GetShelfEntry(userID, bookID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// update the reading status of a book
+func (h *ShelfHandlers) UpdateStatus(c *gin.Context) {
+	var statusInput models.ShelfStatusInput
+
+	if err := c.ShouldBind(&statusInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, bookID, ok := userAndBookID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.shelfUseCase.UpdateStatus(userID, bookID, &statusInput)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+// record reading progress of a book
+func (h *ShelfHandlers) RecordProgress(c *gin.Context) {
+	var progressInput models.ReadingProgressInput
+
+	if err := c.ShouldBind(&progressInput); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, bookID, ok := userAndBookID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.shelfUseCase.RecordProgress(userID, bookID, &progressInput)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": entry})
+}
+
+// remove a book from the shelf
+func (h *ShelfHandlers) DeleteShelfEntry(c *gin.Context) {
+	userID, bookID, ok := userAndBookID(c)
+	if !ok {
+		return
+	}
+
+	err := h.shelfUseCase.DeleteShelfEntry(userID, bookID)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to remove book from shelf"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Book removed from shelf successfully"})
+}
+
+// get yearly reading stats
+func (h *ShelfHandlers) GetStats(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var query models.ReadingStatsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.shelfUseCase.GetStats(userID, query.Year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load reading stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+func userAndBookID(c *gin.Context) (uint, uint, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(bookID), true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	var fieldErr *models.FieldError
+	switch {
+	case errors.Is(err, shelf.ErrBookNotFound), errors.Is(err, shelf.ErrShelfEntryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, shelf.ErrProgressRequired), errors.As(err, &fieldErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
+
+// errorBody names the offending field of validation errors.
+func errorBody(err error) gin.H {
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		return gin.H{"error": fieldErr.Message, "field": fieldErr.Field}
+	}
+	return gin.H{"error": err.Error()}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+)
+
+type ShelfRepository interface {
+	FindEntry(userID, bookID uint) (*models.ShelfEntry, error)
+	FindEntryWithProgress(userID, bookID uint) (*models.ShelfEntry, error)
+	FindEntries(userID uint, status string, limit, offset int) ([]*models.ShelfEntry, error)
+	CountEntries(userID uint, status string) (int64, error)
+	SaveEntry(entry *models.ShelfEntry) (*models.ShelfEntry, error)
+	AddProgress(entry *models.ShelfEntry, progress *models.ReadingProgress) (*models.ShelfEntry, error)
+	DeleteEntry(entry *models.ShelfEntry) error
+	GetStats(userID uint, year int) (*models.ReadingStats, error)
+}
+
+type ShelfRepo struct {
+	DB *gorm.DB
+}
+
+func NewShelfRepo(db *gorm.DB) ShelfRepository {
+	return &ShelfRepo{DB: db}
+}
+
+func (r *ShelfRepo) FindEntry(userID, bookID uint) (*models.ShelfEntry, error) {
+	var entry models.ShelfEntry
+	if err := r.DB.Where("user_id = ? AND book_id = ?", userID, bookID).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *ShelfRepo) FindEntryWithProgress(userID, bookID uint) (*models.ShelfEntry, error) {
+	var entry models.ShelfEntry
+	err := r.DB.Preload("Book").
+		Preload("Progress", func(db *gorm.DB) *gorm.DB {
+			return db.Order("recorded_at ASC, id ASC")
+		}).
+		Where("user_id = ? AND book_id = ?", userID, bookID).
+		First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *ShelfRepo) FindEntries(userID uint, status string, limit, offset int) ([]*models.ShelfEntry, error) {
+	var entries []*models.ShelfEntry
+	err := r.entriesScope(userID, status).
+		Preload("Book").
+		Order("updated_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *ShelfRepo) CountEntries(userID uint, status string) (int64, error) {
+	var count int64
+	if err := r.entriesScope(userID, status).Model(&models.ShelfEntry{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *ShelfRepo) SaveEntry(entry *models.ShelfEntry) (*models.ShelfEntry, error) {
+	if err := r.DB.Omit("Book", "User", "Progress").Save(entry).Error; err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// AddProgress records a progress entry and moves the shelf entry forward
+// in the same transaction.
+func (r *ShelfRepo) AddProgress(entry *models.ShelfEntry, progress *models.ReadingProgress) (*models.ShelfEntry, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Book", "User", "Progress").Save(entry).Error; err != nil {
+			return err
+		}
+		progress.ShelfEntryID = entry.ID
+		return tx.Create(progress).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// DeleteEntry removes the shelf entry and its progress history for good,
+// so the book can be shelved again later.
+func (r *ShelfRepo) DeleteEntry(entry *models.ShelfEntry) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("shelf_entry_id = ?", entry.ID).Delete(&models.ReadingProgress{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(entry).Error
+	})
+}
+
+// GetStats aggregates a user's reading year. Pages read are the forward
+// page deltas between consecutive progress records, attributed to the year
+// each record was made in.
+func (r *ShelfRepo) GetStats(userID uint, year int) (*models.ReadingStats, error) {
+	stats := &models.ReadingStats{Year: year}
+
+	err := r.DB.Model(&models.ShelfEntry{}).
+		Where("user_id = ? AND EXTRACT(YEAR FROM started_at) = ?", userID, year).
+		Count(&stats.BooksStarted).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.DB.Model(&models.ShelfEntry{}).
+		Where("user_id = ? AND status = ? AND EXTRACT(YEAR FROM finished_at) = ?", userID, models.ShelfStatusFinished, year).
+		Count(&stats.BooksFinished).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.DB.Raw(`
+		SELECT COALESCE(SUM(GREATEST(page - COALESCE(previous_page, 0), 0)), 0)
+		FROM (
+			SELECT page, recorded_at,
+				LAG(page) OVER (PARTITION BY shelf_entry_id ORDER BY recorded_at, id) AS previous_page
+			FROM reading_progresses
+			WHERE user_id = ? AND deleted_at IS NULL
+		) deltas
+		WHERE EXTRACT(YEAR FROM recorded_at) = ?`, userID, year).
+		Scan(&stats.PagesRead).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (r *ShelfRepo) entriesScope(userID uint, status string) *gorm.DB {
+	db := r.DB.Where("user_id = ?", userID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	return db
+}
@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	bookRepository "github.com/1rhino/clean_architecture/app/modules/books/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/shelves/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound       = errors.New("book not found")
+	ErrShelfEntryNotFound = errors.New("book is not on your shelf")
+	ErrProgressRequired   = errors.New("either page or percent is required")
+)
+
+type UseCase interface {
+	GetShelf(userID uint, query *models.ShelfQuery) ([]*models.ShelfEntryResponse, *models.PaginationMeta, error)
+	GetShelfEntry(userID, bookID uint) (*models.ShelfEntryResponse, error)
+	UpdateStatus(userID, bookID uint, statusInput *models.ShelfStatusInput) (*models.ShelfEntryResponse, error)
+	RecordProgress(userID, bookID uint, progressInput *models.ReadingProgressInput) (*models.ShelfEntryResponse, error)
+	DeleteShelfEntry(userID, bookID uint) error
+	GetStats(userID uint, year int) (*models.ReadingStats, error)
+}
+
+type ShelfUseCase struct {
+	shelfRepo repository.ShelfRepository
+	bookRepo  bookRepository.BookRepository
+}
+
+func NewShelfUseCase(shelfRepo repository.ShelfRepository, bookRepo bookRepository.BookRepository) UseCase {
+	return &ShelfUseCase{shelfRepo: shelfRepo, bookRepo: bookRepo}
+}
+
+func (u *ShelfUseCase) GetShelf(userID uint, query *models.ShelfQuery) ([]*models.ShelfEntryResponse, *models.PaginationMeta, error) {
+	total, err := u.shelfRepo.CountEntries(userID, query.Status)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := u.shelfRepo.FindEntries(userID, query.Status, query.PerPage, query.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entryResponses := make([]*models.ShelfEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		entryResponses = append(entryResponses, models.FilterShelfEntryRecord(entry))
+	}
+	meta := models.NewPaginationMeta(&query.PaginationInput, total)
+	return entryResponses, &meta, nil
+}
+
+func (u *ShelfUseCase) GetShelfEntry(userID, bookID uint) (*models.ShelfEntryResponse, error) {
+	entry, err := u.shelfRepo.FindEntryWithProgress(userID, bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShelfEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterShelfEntryRecord(entry), nil
+}
+
+// UpdateStatus shelves the book if needed and moves it to the given status,
+// filling in the start and finish dates when they are not supplied. A book
+// cannot be finished later than now or on a day before it was started; dates
+// are supplied without a time, so finishing on the day it was started is
+// allowed.
+func (u *ShelfUseCase) UpdateStatus(userID, bookID uint, statusInput *models.ShelfStatusInput) (*models.ShelfEntryResponse, error) {
+	entry, err := u.findOrNewEntry(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entry.Status = statusInput.Status
+	switch statusInput.Status {
+	case models.ShelfStatusWantToRead:
+		entry.StartedAt = nil
+		entry.FinishedAt = nil
+	case models.ShelfStatusReading:
+		entry.StartedAt = firstTime(statusInput.StartedAt, entry.StartedAt, &now)
+		entry.FinishedAt = nil
+	case models.ShelfStatusFinished:
+		entry.FinishedAt = firstTime(statusInput.FinishedAt, &now)
+		entry.StartedAt = firstTime(statusInput.StartedAt, entry.StartedAt, entry.FinishedAt)
+		entry.Percent = 100
+		if entry.FinishedAt.After(now) {
+			return nil, &models.FieldError{Field: "finished_at", Message: "finished_at cannot be in the future"}
+		}
+		if entry.FinishedAt.Before(startOfDay(*entry.StartedAt)) {
+			return nil, &models.FieldError{Field: "finished_at", Message: "finished_at cannot be earlier than started_at"}
+		}
+	}
+
+	savedEntry, err := u.shelfRepo.SaveEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterShelfEntryRecord(savedEntry), nil
+}
+
+// RecordProgress appends a progress record. Recording progress on a book
+// that isn't being read yet starts it, and reaching 100 percent finishes it.
+func (u *ShelfUseCase) RecordProgress(userID, bookID uint, progressInput *models.ReadingProgressInput) (*models.ShelfEntryResponse, error) {
+	if progressInput.Page == nil && progressInput.Percent == nil {
+		return nil, ErrProgressRequired
+	}
+
+	entry, err := u.findOrNewEntry(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordedAt := progressInput.RecordedAt
+	if recordedAt.IsZero() {
+		recordedAt = time.Now()
+	}
+
+	progress := &models.ReadingProgress{
+		UserID:     userID,
+		Page:       entry.CurrentPage,
+		Percent:    entry.Percent,
+		RecordedAt: recordedAt,
+	}
+	if progressInput.Page != nil {
+		progress.Page = *progressInput.Page
+	}
+	if progressInput.Percent != nil {
+		progress.Percent = *progressInput.Percent
+	}
+
+	entry.CurrentPage = progress.Page
+	entry.Percent = progress.Percent
+	if entry.Status != models.ShelfStatusReading && entry.Status != models.ShelfStatusFinished {
+		entry.Status = models.ShelfStatusReading
+	}
+	entry.StartedAt = firstTime(entry.StartedAt, &recordedAt)
+	if progress.Percent >= 100 {
+		entry.Status = models.ShelfStatusFinished
+		entry.FinishedAt = firstTime(entry.FinishedAt, &recordedAt)
+	}
+
+	savedEntry, err := u.shelfRepo.AddProgress(entry, progress)
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterShelfEntryRecord(savedEntry), nil
+}
+
+func (u *ShelfUseCase) DeleteShelfEntry(userID, bookID uint) error {
+	entry, err := u.shelfRepo.FindEntry(userID, bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrShelfEntryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return u.shelfRepo.DeleteEntry(entry)
+}
+
+func (u *ShelfUseCase) GetStats(userID uint, year int) (*models.ReadingStats, error) {
+	if year == 0 {
+		year = time.Now().Year()
+	}
+	return u.shelfRepo.GetStats(userID, year)
+}
+
+func (u *ShelfUseCase) findOrNewEntry(userID, bookID uint) (*models.ShelfEntry, error) {
+	entry, err := u.shelfRepo.FindEntry(userID, bookID)
+	if err == nil {
+		return entry, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	_, err = u.bookRepo.FindByID(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ShelfEntry{
+		UserID: userID,
+		BookID: bookID,
+		Status: models.ShelfStatusWantToRead,
+	}, nil
+}
+
+// firstTime returns the first non-nil time.
+func firstTime(times ...*time.Time) *time.Time {
+	for _, t := range times {
+		if t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// startOfDay returns midnight of the day t falls on.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	trash "github.com/1rhino/clean_architecture/app/modules/trash/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandlers struct {
+	trashUseCase trash.UseCase
+}
+
+func NewTrashHandlers(trashUseCase trash.UseCase) *TrashHandlers {
+	return &TrashHandlers{trashUseCase: trashUseCase}
+}
+
+// get the user's deleted books
+func (h *TrashHandlers) GetDeletedBooks(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	books, err := h.trashUseCase.GetDeletedBooks(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": books})
+}
+
+// restore a deleted book
+func (h *TrashHandlers) RestoreBook(c *gin.Context) {
+	userID, bookID, ok := userAndID(c)
+	if !ok {
+		return
+	}
+
+	restoredBook, err := h.trashUseCase.RestoreBook(bookID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": restoredBook})
+}
+
+// permanently delete a book from the trash
+func (h *TrashHandlers) PurgeBook(c *gin.Context) {
+	userID, bookID, ok := userAndID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.trashUseCase.PurgeBook(bookID, userID); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Book purged successfully"})
+}
+
+// get the user's deleted book categories
+func (h *TrashHandlers) GetDeletedBookCategories(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookCategories, err := h.trashUseCase.GetDeletedBookCategories(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bookCategories})
+}
+
+// restore a deleted book category
+func (h *TrashHandlers) RestoreBookCategory(c *gin.Context) {
+	userID, bookCategoryID, ok := userAndID(c)
+	if !ok {
+		return
+	}
+
+	restoredBookCategory, err := h.trashUseCase.RestoreBookCategory(bookCategoryID, userID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": restoredBookCategory})
+}
+
+// permanently delete a book category from the trash
+func (h *TrashHandlers) PurgeBookCategory(c *gin.Context) {
+	userID, bookCategoryID, ok := userAndID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.trashUseCase.PurgeBookCategory(bookCategoryID, userID); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "BookCategory purged successfully"})
+}
+
+func userAndID(c *gin.Context) (uint, uint, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(id), true
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	var fieldErr *models.FieldError
+	switch {
+	case errors.Is(err, trash.ErrBookNotFound), errors.Is(err, trash.ErrBookCategoryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, trash.ErrBookInCirculation), errors.Is(err, trash.ErrBookHasOpenFines),
+		errors.Is(err, trash.ErrBookCategoryHasBooks):
+		return http.StatusConflict
+	case errors.As(err, &fieldErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
+
+// errorBody names the offending field of validation errors.
+func errorBody(err error) gin.H {
+	var fieldErr *models.FieldError
+	if errors.As(err, &fieldErr) {
+		return gin.H{"error": fieldErr.Message, "field": fieldErr.Field}
+	}
+	return gin.H{"error": err.Error()}
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	bookCategoryRepository "github.com/1rhino/clean_architecture/app/modules/book_category/repositories"
+	revisionRepository "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBookInCirculation = errors.New("book is on loan or has members waiting for it")
+	ErrBookHasOpenFines  = errors.New("book has open fines")
+)
+
+// TrashRepository reaches soft-deleted books and categories, which the
+// other repositories never see.
+type TrashRepository interface {
+	FindDeletedBooks(userID uint) ([]*models.Book, error)
+	FindDeletedBook(id uint) (*models.Book, error)
+	FindExpiredBooks(before time.Time, afterID uint, limit int) ([]*models.Book, error)
+	RestoreBook(book *models.Book, actorID uint) error
+	PurgeBook(book *models.Book, actorID uint) error
+
+	FindDeletedBookCategories(userID uint) ([]*models.BookCategory, error)
+	FindDeletedBookCategory(id uint) (*models.BookCategory, error)
+	FindExpiredBookCategories(before time.Time, afterID uint, limit int) ([]*models.BookCategory, error)
+	RestoreBookCategory(bookCategory *models.BookCategory, actorID uint) error
+	PurgeBookCategory(bookCategory *models.BookCategory, actorID uint) error
+	BookCategoryHasBooks(bookCategoryID uint) (bool, error)
+}
+
+type TrashRepo struct {
+	DB *gorm.DB
+}
+
+func NewTrashRepo(db *gorm.DB) TrashRepository {
+	return &TrashRepo{DB: db}
+}
+
+func (r *TrashRepo) FindDeletedBooks(userID uint) ([]*models.Book, error) {
+	var books []*models.Book
+	err := r.DB.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC, id DESC").
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (r *TrashRepo) FindDeletedBook(id uint) (*models.Book, error) {
+	var book models.Book
+	if err := r.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&book, id).Error; err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (r *TrashRepo) FindExpiredBooks(before time.Time, afterID uint, limit int) ([]*models.Book, error) {
+	var books []*models.Book
+	err := r.DB.Unscoped().
+		Where("deleted_at < ? AND id > ?", before, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// RestoreBook clears the soft delete and records it in the book history.
+// It returns gorm.ErrRecordNotFound when the book is no longer deleted, and
+// bookCategoryRepository.ErrNotOwned when its category has been deleted
+// since, in which case the category has to be restored first.
+func (r *TrashRepo) RestoreBook(book *models.Book, actorID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Book
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL").
+			First(&current, book.ID).Error
+		if err != nil {
+			return err
+		}
+		if current.CategoryID != nil {
+			if err := bookCategoryRepository.LockOwned(tx, *current.CategoryID, current.UserID); err != nil {
+				return err
+			}
+		}
+
+		err = tx.Unscoped().Model(&models.Book{}).Where("id = ?", book.ID).
+			UpdateColumns(map[string]interface{}{
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			}).Error
+		if err != nil {
+			return err
+		}
+		*book = current
+		book.DeletedAt = gorm.DeletedAt{}
+		book.Version++
+
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBook, book.ID, actorID,
+			models.RevisionActionRestore, nil, models.NewBookSnapshot(book))
+	})
+}
+
+// PurgeBook hard-deletes a trashed book together with everything that hangs
+// off it: reviews, shelves, favorites, list items, e-books, precomputed
+// recommendations, holds and copies. Its revision history is kept, and so
+// are the members' loans and fines, which stop naming the book. It returns
+// ErrBookInCirculation or ErrBookHasOpenFines while the book is still in use.
+func (r *TrashRepo) PurgeBook(book *models.Book, actorID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL").
+			First(&models.Book{}, book.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := checkBookUnused(tx, book.ID); err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Model(&models.Loan{}).Where("book_id = ?", book.ID).
+			UpdateColumns(map[string]interface{}{"book_id": nil, "copy_id": nil}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Model(&models.Fine{}).Where("book_id = ?", book.ID).
+			UpdateColumn("book_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		shelfEntries := tx.Unscoped().Model(&models.ShelfEntry{}).Select("id").Where("book_id = ?", book.ID)
+		ebooks := tx.Model(&models.Ebook{}).Select("id").Where("book_id = ?", book.ID)
+		dependents := []struct {
+			model interface{}
+			query string
+			args  []interface{}
+		}{
+			{&models.ReadingProgress{}, "shelf_entry_id IN (?)", []interface{}{shelfEntries}},
+			{&models.ShelfEntry{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.Review{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.Favorite{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.ReadingListItem{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.EbookDownload{}, "ebook_id IN (?)", []interface{}{ebooks}},
+			{&models.Ebook{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.BookSimilarity{}, "book_id = ? OR similar_book_id = ?", []interface{}{book.ID, book.ID}},
+			{&models.UserRecommendation{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.Hold{}, "book_id = ?", []interface{}{book.ID}},
+			{&models.Copy{}, "book_id = ?", []interface{}{book.ID}},
+		}
+		for _, dependent := range dependents {
+			if err := tx.Unscoped().Where(dependent.query, dependent.args...).Delete(dependent.model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Delete(&models.Book{}, book.ID).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBook, book.ID, actorID,
+			models.RevisionActionPurge, models.NewBookSnapshot(book), nil)
+	})
+}
+
+// checkBookUnused returns ErrBookInCirculation while the book is on loan or
+// has members queued for it, and ErrBookHasOpenFines while fines on it are
+// still open.
+func checkBookUnused(tx *gorm.DB, bookID uint) error {
+	var loans, holds, fines int64
+	err := tx.Model(&models.Loan{}).
+		Where("book_id = ? AND status = ?", bookID, models.LoanStatusActive).
+		Count(&loans).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.Hold{}).
+		Where("book_id = ? AND status IN ?", bookID, []string{models.HoldStatusWaiting, models.HoldStatusReady}).
+		Count(&holds).Error
+	if err != nil {
+		return err
+	}
+	if loans > 0 || holds > 0 {
+		return ErrBookInCirculation
+	}
+
+	err = tx.Model(&models.Fine{}).
+		Where("book_id = ? AND status = ?", bookID, models.FineStatusOpen).
+		Count(&fines).Error
+	if err != nil {
+		return err
+	}
+	if fines > 0 {
+		return ErrBookHasOpenFines
+	}
+	return nil
+}
+
+func (r *TrashRepo) FindDeletedBookCategories(userID uint) ([]*models.BookCategory, error) {
+	var bookCategories []*models.BookCategory
+	err := r.DB.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC, id DESC").
+		Find(&bookCategories).Error
+	if err != nil {
+		return nil, err
+	}
+	return bookCategories, nil
+}
+
+func (r *TrashRepo) FindDeletedBookCategory(id uint) (*models.BookCategory, error) {
+	var bookCategory models.BookCategory
+	if err := r.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&bookCategory, id).Error; err != nil {
+		return nil, err
+	}
+	return &bookCategory, nil
+}
+
+func (r *TrashRepo) FindExpiredBookCategories(before time.Time, afterID uint, limit int) ([]*models.BookCategory, error) {
+	var bookCategories []*models.BookCategory
+	err := r.DB.Unscoped().
+		Where("deleted_at < ? AND id > ?", before, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&bookCategories).Error
+	if err != nil {
+		return nil, err
+	}
+	return bookCategories, nil
+}
+
+// RestoreBookCategory clears the soft delete and records it in the category
+// history. It returns gorm.ErrRecordNotFound when the category is no longer
+// deleted.
+func (r *TrashRepo) RestoreBookCategory(bookCategory *models.BookCategory, actorID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.BookCategory{}).
+			Where("id = ? AND deleted_at IS NOT NULL", bookCategory.ID).
+			UpdateColumns(map[string]interface{}{
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		bookCategory.DeletedAt = gorm.DeletedAt{}
+		bookCategory.Version++
+
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBookCategory, bookCategory.ID, actorID,
+			models.RevisionActionRestore, nil, models.NewBookCategorySnapshot(bookCategory))
+	})
+}
+
+func (r *TrashRepo) PurgeBookCategory(bookCategory *models.BookCategory, actorID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL").
+			First(&models.BookCategory{}, bookCategory.ID).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&models.BookCategory{}, bookCategory.ID).Error; err != nil {
+			return err
+		}
+		return revisionRepository.RecordRevision(tx, models.RevisionEntityBookCategory, bookCategory.ID, actorID,
+			models.RevisionActionPurge, models.NewBookCategorySnapshot(bookCategory), nil)
+	})
+}
+
+// BookCategoryHasBooks counts trashed books too, since purging the category
+// would leave them pointing nowhere if they were restored.
+func (r *TrashRepo) BookCategoryHasBooks(bookCategoryID uint) (bool, error) {
+	var count int64
+	err := r.DB.Unscoped().Model(&models.Book{}).
+		Where("category_id = ?", bookCategoryID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
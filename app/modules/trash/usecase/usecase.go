@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	bookCategoryRepository "github.com/1rhino/clean_architecture/app/modules/book_category/repositories"
+	repository "github.com/1rhino/clean_architecture/app/modules/trash/repositories"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookNotFound         = errors.New("book not found in trash")
+	ErrBookCategoryNotFound = errors.New("book category not found in trash")
+	ErrBookInCirculation    = errors.New("book is on loan or has members waiting for it")
+	ErrBookHasOpenFines     = errors.New("book has open fines, settle or waive them first")
+	ErrBookCategoryHasBooks = errors.New("book category still has books, purge them first")
+)
+
+const purgeBatch = 100
+
+type UseCase interface {
+	GetDeletedBooks(userID uint) ([]*models.BookResponse, error)
+	RestoreBook(bookID, userID uint) (*models.BookResponse, error)
+	PurgeBook(bookID, userID uint) error
+	GetDeletedBookCategories(userID uint) ([]*models.BookCategoryResponse, error)
+	RestoreBookCategory(bookCategoryID, userID uint) (*models.BookCategoryResponse, error)
+	PurgeBookCategory(bookCategoryID, userID uint) error
+	PurgeExpired(ctx context.Context) error
+}
+
+// TrashUseCase lets owners restore or purge what they deleted. Other users'
+// trash is reported as not found.
+type TrashUseCase struct {
+	trashRepo     repository.TrashRepository
+	config        config.TrashConfig
+	clock         clock.Clock
+	uploadUseCase upload.UseCase
+}
+
+func NewTrashUseCase(trashRepo repository.TrashRepository, cfg config.TrashConfig, clk clock.Clock, uploadUseCase upload.UseCase) UseCase {
+	return &TrashUseCase{trashRepo: trashRepo, config: cfg, clock: clk, uploadUseCase: uploadUseCase}
+}
+
+func (u *TrashUseCase) GetDeletedBooks(userID uint) ([]*models.BookResponse, error) {
+	books, err := u.trashRepo.FindDeletedBooks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookResponses := []*models.BookResponse{}
+	for _, book := range books {
+		bookResponses = append(bookResponses, models.FilterBookRecord(book))
+	}
+	return bookResponses, nil
+}
+
+func (u *TrashUseCase) RestoreBook(bookID, userID uint) (*models.BookResponse, error) {
+	book, err := u.findDeletedBook(bookID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.trashRepo.RestoreBook(book, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if errors.Is(err, bookCategoryRepository.ErrNotOwned) {
+		return nil, &models.FieldError{Field: "category_id", Message: "book category does not exist"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterBookRecord(book), nil
+}
+
+func (u *TrashUseCase) PurgeBook(bookID, userID uint) error {
+	book, err := u.findDeletedBook(bookID, userID)
+	if err != nil {
+		return err
+	}
+	return u.purgeBook(book, userID)
+}
+
+func (u *TrashUseCase) GetDeletedBookCategories(userID uint) ([]*models.BookCategoryResponse, error) {
+	bookCategories, err := u.trashRepo.FindDeletedBookCategories(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookCategoryResponses := []*models.BookCategoryResponse{}
+	for _, bookCategory := range bookCategories {
+		bookCategoryResponses = append(bookCategoryResponses, models.FilterBookCategoryRecord(bookCategory))
+	}
+	return bookCategoryResponses, nil
+}
+
+func (u *TrashUseCase) RestoreBookCategory(bookCategoryID, userID uint) (*models.BookCategoryResponse, error) {
+	bookCategory, err := u.findDeletedBookCategory(bookCategoryID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.trashRepo.RestoreBookCategory(bookCategory, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.FilterBookCategoryRecord(bookCategory), nil
+}
+
+func (u *TrashUseCase) PurgeBookCategory(bookCategoryID, userID uint) error {
+	bookCategory, err := u.findDeletedBookCategory(bookCategoryID, userID)
+	if err != nil {
+		return err
+	}
+	return u.purgeBookCategory(bookCategory, userID)
+}
+
+// PurgeExpired is run by the scheduler. Books go first so that categories
+// emptied in the same run can follow; anything still in use is skipped and
+// retried on the next run.
+func (u *TrashUseCase) PurgeExpired(ctx context.Context) error {
+	if u.config.RetentionDays <= 0 {
+		return nil
+	}
+	before := u.clock.Now().Add(-time.Duration(u.config.RetentionDays) * 24 * time.Hour)
+	purged := 0
+
+	var afterID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		books, err := u.trashRepo.FindExpiredBooks(before, afterID, purgeBatch)
+		if err != nil {
+			return err
+		}
+		for _, book := range books {
+			afterID = book.ID
+			err := u.purgeBook(book, 0)
+			if errors.Is(err, ErrBookInCirculation) || errors.Is(err, ErrBookHasOpenFines) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			purged++
+		}
+		if len(books) < purgeBatch {
+			break
+		}
+	}
+
+	afterID = 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bookCategories, err := u.trashRepo.FindExpiredBookCategories(before, afterID, purgeBatch)
+		if err != nil {
+			return err
+		}
+		for _, bookCategory := range bookCategories {
+			afterID = bookCategory.ID
+			err := u.purgeBookCategory(bookCategory, 0)
+			if errors.Is(err, ErrBookCategoryHasBooks) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			purged++
+		}
+		if len(bookCategories) < purgeBatch {
+			break
+		}
+	}
+
+	if purged > 0 {
+		log.Printf("purged %d expired records from the trash", purged)
+	}
+	return nil
+}
+
+func (u *TrashUseCase) purgeBook(book *models.Book, actorID uint) error {
+	err := u.trashRepo.PurgeBook(book, actorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookNotFound
+	}
+	if errors.Is(err, repository.ErrBookInCirculation) {
+		return ErrBookInCirculation
+	}
+	if errors.Is(err, repository.ErrBookHasOpenFines) {
+		return ErrBookHasOpenFines
+	}
+	if err != nil {
+		return err
+	}
+
+	u.uploadUseCase.ClearReferences(models.UploadEntityBook, book.ID)
+	return nil
+}
+
+func (u *TrashUseCase) purgeBookCategory(bookCategory *models.BookCategory, actorID uint) error {
+	hasBooks, err := u.trashRepo.BookCategoryHasBooks(bookCategory.ID)
+	if err != nil {
+		return err
+	}
+	if hasBooks {
+		return ErrBookCategoryHasBooks
+	}
+
+	err = u.trashRepo.PurgeBookCategory(bookCategory, actorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBookCategoryNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	u.uploadUseCase.ReferenceImage(models.UploadEntityBookCategory, bookCategory.ID, "")
+	return nil
+}
+
+func (u *TrashUseCase) findDeletedBook(bookID, userID uint) (*models.Book, error) {
+	book, err := u.trashRepo.FindDeletedBook(bookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if book.UserID != userID {
+		return nil, ErrBookNotFound
+	}
+	return book, nil
+}
+
+func (u *TrashUseCase) findDeletedBookCategory(bookCategoryID, userID uint) (*models.BookCategory, error) {
+	bookCategory, err := u.trashRepo.FindDeletedBookCategory(bookCategoryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bookCategory.UserID != userID {
+		return nil, ErrBookCategoryNotFound
+	}
+	return bookCategory, nil
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
+	"github.com/1rhino/clean_architecture/app/patch"
+	"github.com/gin-gonic/gin"
+)
+
+type UploadHandlers struct {
+	uploadUseCase upload.UseCase
+}
+
+func NewUploadHandlers(uploadUseCase upload.UseCase) *UploadHandlers {
+	return &UploadHandlers{uploadUseCase: uploadUseCase}
+}
+
+// issue a URL to upload an image straight to storage
+func (h *UploadHandlers) PresignUpload(c *gin.Context) {
+	var input models.PresignUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	presigned, err := h.uploadUseCase.Presign(c.Request.Context(), userID, &input)
+	if err != nil {
+		WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": presigned})
+}
+
+// receive an upload made to a URL issued by PresignUpload, for stores
+// that can't presign uploads themselves
+func (h *UploadHandlers) PutDirectUpload(c *gin.Context) {
+	var query models.DirectUploadQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := h.uploadUseCase.PutDirect(c.Request.Context(), key, c.ContentType(), &query, c.Request.Body); err != nil {
+		WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully"})
+}
+
+// serve a private file, or redirect its owner to a signed URL for it
+func (h *UploadHandlers) GetFile(c *gin.Context) {
+	var query models.SignedFileQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Requests with a signature come without a token.
+	userID, _ := middleware.GetUserID(c)
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	object, location, err := h.uploadUseCase.OpenFile(c.Request.Context(), key, userID, &query)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to read file"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if location != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, location)
+		return
+	}
+	defer object.Body.Close()
+	c.DataFromReader(http.StatusOK, object.Size, object.ContentType, object.Body, map[string]string{
+		"Cache-Control": "private",
+	})
+}
+
+// SignedOr runs auth on requests without a signature in their query. The
+// handler checks the signature of the others.
+func SignedOr(auth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("signature") != "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// FormImage uploads the optional "image" file of a form with the given
+// visibility, empty for the entity type's, and returns its URL and the
+// URLs of its renditions, or nothing when the form has none. It writes the
+// error response itself and returns false when the image is rejected.
+func FormImage(c *gin.Context, uploadUseCase upload.UseCase, entityType, visibility string, ownerID uint) (string, models.ImageRenditions, bool) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		return "", nil, true
+	}
+
+	uploaded, err := uploadUseCase.Upload(c.Request.Context(), entityType, visibility, ownerID, file)
+	if err != nil {
+		WriteError(c, err)
+		return "", nil, false
+	}
+	return uploaded.URL, uploaded.Renditions, true
+}
+
+// InputImage is FormImage for inputs that can also name an image to import
+// by URL in image_url. JSON patches carry it and image_visibility as
+// members of their own, which are taken out of the patch. An uploaded file
+// takes precedence.
+func InputImage(c *gin.Context, uploadUseCase upload.UseCase, entityType string, ownerID uint, imageURL, visibility string, inputPatch *patch.Patch) (string, models.ImageRenditions, bool) {
+	if inputPatch != nil {
+		for _, member := range []struct {
+			name  string
+			value *string
+		}{{"image_url", &imageURL}, {"image_visibility", &visibility}} {
+			value, err := inputPatch.Take(member.name)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return "", nil, false
+			}
+			if value != nil {
+				if err := json.Unmarshal(value, member.value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": member.name + " must be a string"})
+					return "", nil, false
+				}
+			}
+		}
+	}
+
+	image, renditions, ok := FormImage(c, uploadUseCase, entityType, visibility, ownerID)
+	if !ok || image != "" || imageURL == "" {
+		return image, renditions, ok
+	}
+
+	imported, err := uploadUseCase.Import(c.Request.Context(), entityType, visibility, ownerID, imageURL)
+	if err != nil {
+		WriteError(c, err)
+		return "", nil, false
+	}
+	return imported.URL, imported.Renditions, true
+}
+
+// WriteError writes the response for an error of the uploads usecase.
+// Unexpected errors are reported without their details.
+func WriteError(c *gin.Context, err error) {
+	status := errorStatus(err, http.StatusInternalServerError)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "Failed to upload image"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// errorStatus maps usecase errors to HTTP status codes, falling back to
+// the given status for anything unexpected.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, upload.ErrFileTooLarge), errors.Is(err, upload.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, upload.ErrUnsupportedType), errors.Is(err, upload.ErrInvalidImage):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, upload.ErrInfected), errors.Is(err, upload.ErrUnscannable), errors.Is(err, upload.ErrImportFailed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, upload.ErrUnknownEntity), errors.Is(err, upload.ErrUnknownVisibility),
+		errors.Is(err, upload.ErrInvalidImageURL), errors.Is(err, upload.ErrBlockedAddress):
+		return http.StatusBadRequest
+	case errors.Is(err, upload.ErrInvalidSignature):
+		return http.StatusForbidden
+	case errors.Is(err, upload.ErrUploadNotFound):
+		return http.StatusNotFound
+	default:
+		return fallback
+	}
+}
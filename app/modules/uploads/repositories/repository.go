@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UploadRepository interface {
+	FindByKey(key string) (*models.Upload, error)
+	Create(upload *models.Upload) (*models.Upload, bool, error)
+	DeleteByKey(key string) error
+	SetReference(reference *models.UploadReference, deleteAfter time.Time) error
+	ClearReference(entityType string, entityID uint, field string, deleteAfter time.Time) error
+	AddReferences(references []*models.UploadReference) error
+	FindUnreferenced(afterID uint, limit int) ([]*models.Upload, error)
+	FindUsers(key string) ([]*models.UploadReference, error)
+	FindKeys() (map[string]bool, error)
+	Schedule(uploadID uint, deleteAfter time.Time) error
+	DeleteUnreferenced(uploadID uint, now time.Time) (bool, error)
+}
+
+type UploadRepo struct {
+	DB *gorm.DB
+}
+
+func NewUploadRepo(db *gorm.DB) UploadRepository {
+	return &UploadRepo{DB: db}
+}
+
+// unreferenced selects uploads no entity references.
+const unreferenced = "NOT EXISTS (SELECT 1 FROM upload_references WHERE upload_references.upload_id = uploads.id)"
+
+func (r *UploadRepo) FindByKey(key string) (*models.Upload, error) {
+	var upload models.Upload
+	if err := r.DB.Where("key = ?", key).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Create records the upload unless its key is already taken, in which case
+// it returns the existing record and false. Concurrent uploads of the same
+// file therefore end up sharing one record.
+func (r *UploadRepo) Create(upload *models.Upload) (*models.Upload, bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).Create(upload)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		existing, err := r.FindByKey(upload.Key)
+		if err != nil {
+			return nil, false, err
+		}
+		return existing, false, nil
+	}
+	return upload, true, nil
+}
+
+func (r *UploadRepo) DeleteByKey(key string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		uploads := tx.Model(&models.Upload{}).Select("id").Where("key = ?", key)
+		if err := tx.Where("upload_id IN (?)", uploads).Delete(&models.UploadReference{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("key = ?", key).Delete(&models.Upload{}).Error
+	})
+}
+
+// SetReference points the field at an upload, in place of whichever it
+// referenced before. The upload it replaces is scheduled for deletion at
+// deleteAfter if nothing else references it, and the new one is no longer
+// scheduled.
+func (r *UploadRepo) SetReference(reference *models.UploadReference, deleteAfter time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.UploadReference
+		err := tx.Where("entity_type = ? AND entity_id = ? AND field = ?", reference.EntityType, reference.EntityID, reference.Field).
+			First(&previous).Error
+		if err == nil && previous.UploadID == reference.UploadID {
+			return nil
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_type"}, {Name: "entity_id"}, {Name: "field"}},
+			DoUpdates: clause.AssignmentColumns([]string{"upload_id"}),
+		}).Create(reference).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Upload{}).Where("id = ?", reference.UploadID).Update("delete_after", nil).Error; err != nil {
+			return err
+		}
+		if previous.UploadID == 0 {
+			return nil
+		}
+		return schedule(tx, previous.UploadID, deleteAfter)
+	})
+}
+
+// ClearReference removes the reference of the field, or of every field of
+// the entity when field is empty, scheduling the uploads it referenced for
+// deletion at deleteAfter if nothing else references them.
+func (r *UploadRepo) ClearReference(entityType string, entityID uint, field string, deleteAfter time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Returning{}).Where("entity_type = ? AND entity_id = ?", entityType, entityID)
+		if field != "" {
+			query = query.Where("field = ?", field)
+		}
+		var references []models.UploadReference
+		err := query.Delete(&references).Error
+		if err != nil {
+			return err
+		}
+		for _, reference := range references {
+			if err := schedule(tx, reference.UploadID, deleteAfter); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddReferences records references found after the fact, leaving existing
+// ones alone, and unschedules the uploads they reference.
+func (r *UploadRepo) AddReferences(references []*models.UploadReference) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, reference := range references {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(reference).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Upload{}).Where("id = ?", reference.UploadID).Update("delete_after", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindUnreferenced pages through the uploads nothing references, in order
+// of ID.
+func (r *UploadRepo) FindUnreferenced(afterID uint, limit int) ([]*models.Upload, error) {
+	var uploads []*models.Upload
+	err := r.DB.Where("id > ?", afterID).Where(unreferenced).
+		Order("id").
+		Limit(limit).
+		Find(&uploads).Error
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// FindUsers finds the books, categories and users whose image is the file
+// stored under key, whatever the URL it was saved with, and the books it
+// is an e-book of. Trashed books and categories count, since they can be
+// restored; deleted users don't.
+func (r *UploadRepo) FindUsers(key string) ([]*models.UploadReference, error) {
+	pattern := "%/" + key
+	users := []struct {
+		entityType string
+		query      *gorm.DB
+	}{
+		{models.UploadEntityBook, r.DB.Unscoped().Model(&models.Book{})},
+		{models.UploadEntityBookCategory, r.DB.Unscoped().Model(&models.BookCategory{})},
+		{models.UploadEntityUser, r.DB.Model(&models.User{})},
+	}
+
+	var references []*models.UploadReference
+	for _, user := range users {
+		var ids []uint
+		if err := user.query.Where("image LIKE ?", pattern).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			references = append(references, &models.UploadReference{
+				EntityType: user.entityType,
+				EntityID:   id,
+				Field:      models.UploadFieldImage,
+			})
+		}
+	}
+
+	var ebooks []*models.Ebook
+	if err := r.DB.Where("key = ?", key).Find(&ebooks).Error; err != nil {
+		return nil, err
+	}
+	for _, ebook := range ebooks {
+		references = append(references, &models.UploadReference{
+			EntityType: models.UploadEntityBook,
+			EntityID:   ebook.BookID,
+			Field:      models.EbookUploadField(ebook.Format),
+		})
+	}
+	return references, nil
+}
+
+// FindKeys returns the keys of every upload and of its renditions.
+func (r *UploadRepo) FindKeys() (map[string]bool, error) {
+	var uploads []*models.Upload
+	if err := r.DB.Select("key", "renditions").Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, upload := range uploads {
+		keys[upload.Key] = true
+		for _, key := range upload.Renditions {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+// Schedule sets when an upload is deleted unless it is already scheduled.
+func (r *UploadRepo) Schedule(uploadID uint, deleteAfter time.Time) error {
+	return r.DB.Model(&models.Upload{}).
+		Where("id = ? AND delete_after IS NULL", uploadID).
+		Update("delete_after", deleteAfter).Error
+}
+
+// DeleteUnreferenced deletes the record of an upload that is due for
+// deletion and still unreferenced, reporting whether it did. Checking both
+// in the same statement keeps an upload referenced again in the meantime.
+func (r *UploadRepo) DeleteUnreferenced(uploadID uint, now time.Time) (bool, error) {
+	result := r.DB.Where("id = ? AND delete_after <= ?", uploadID, now).
+		Where(unreferenced).
+		Delete(&models.Upload{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// schedule schedules an upload for deletion if nothing references it.
+func schedule(tx *gorm.DB, uploadID uint, deleteAfter time.Time) error {
+	return tx.Model(&models.Upload{}).
+		Where("id = ?", uploadID).
+		Where(unreferenced).
+		Update("delete_after", deleteAfter).Error
+}
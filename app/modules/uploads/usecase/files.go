@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"github.com/1rhino/clean_architecture/app/storage"
+)
+
+// FilesRoute is the route the router serves private files on. Their URLs
+// point there instead of into the store, so they stay the same while the
+// signed URLs handed out for them change.
+const FilesRoute = "/api/v1/files"
+
+// OpenFile gives access to a private file to its owner, or to anyone with
+// a URL signed for it. The owner of a file with signed visibility gets a
+// signed URL to read it from; otherwise the file itself is returned, and
+// the caller must close its body.
+func (u *UploadUseCase) OpenFile(ctx context.Context, key string, userID uint, query *models.SignedFileQuery) (*storage.Object, string, error) {
+	// Keys of other owners are treated as missing rather than forbidden,
+	// so they can't be probed.
+	_, ownerID, ok := privateOwner(key)
+	if !ok {
+		return nil, "", ErrUploadNotFound
+	}
+
+	if query.Signature != "" {
+		signature := u.sign(http.MethodGet, key, "", 0, query.Expires)
+		if !hmac.Equal([]byte(query.Signature), []byte(signature)) || u.clock.Now().Unix() > query.Expires {
+			return nil, "", ErrInvalidSignature
+		}
+		object, err := u.get(ctx, key)
+		return object, "", err
+	}
+
+	if userID != ownerID {
+		return nil, "", ErrUploadNotFound
+	}
+	if u.visibilityOf(key) == models.UploadVisibilitySigned {
+		location, err := u.signedURL(ctx, key)
+		return nil, location, err
+	}
+	object, err := u.get(ctx, key)
+	return object, "", err
+}
+
+// signedURL returns a URL reading a private file until PresignTTL has
+// passed, from the store itself when it can presign.
+func (u *UploadUseCase) signedURL(ctx context.Context, key string) (string, error) {
+	if presigner, ok := u.store.(storage.Presigner); ok {
+		return presigner.PresignGet(ctx, key, u.config.PresignTTL)
+	}
+
+	expires := u.clock.Now().Add(u.config.PresignTTL).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", u.sign(http.MethodGet, key, "", 0, expires))
+	return FilesRoute + "/" + key + "?" + query.Encode(), nil
+}
+
+func (u *UploadUseCase) get(ctx context.Context, key string) (*storage.Object, error) {
+	object, err := u.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return nil, ErrUploadNotFound
+	}
+	return object, err
+}
+
+// visibility is how images of an entity type are served unless their
+// upload chose otherwise.
+func (u *UploadUseCase) visibility(entityType string) string {
+	if visibility, ok := u.config.Visibility[entityType]; ok {
+		return visibility
+	}
+	return models.UploadVisibilityPublic
+}
+
+// resolveVisibility checks the visibility asked for an upload, falling
+// back to the entity type's when none was.
+func (u *UploadUseCase) resolveVisibility(entityType, visibility string) (string, error) {
+	switch visibility {
+	case "":
+		return u.visibility(entityType), nil
+	case models.UploadVisibilityPublic, models.UploadVisibilitySigned, models.UploadVisibilityProxy:
+		return visibility, nil
+	default:
+		return "", ErrUnknownVisibility
+	}
+}
+
+// visibilityOf is how the file stored under key is served. Private images
+// name their visibility after the owner. E-books, stored by StoreFile,
+// follow their entity type: signed if it is, proxied otherwise.
+func (u *UploadUseCase) visibilityOf(key string) string {
+	if !storage.IsPrivate(key) {
+		return models.UploadVisibilityPublic
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, storage.PrivatePrefix), "/", 4)
+	if len(parts) == 4 && (parts[2] == models.UploadVisibilitySigned || parts[2] == models.UploadVisibilityProxy) {
+		return parts[2]
+	}
+	if u.visibility(parts[0]) == models.UploadVisibilitySigned {
+		return models.UploadVisibilitySigned
+	}
+	return models.UploadVisibilityProxy
+}
+
+// url is where clients read the file stored under key: the store for
+// public files and FilesRoute for private ones.
+func (u *UploadUseCase) url(key string) string {
+	if storage.IsPrivate(key) {
+		return FilesRoute + "/" + key
+	}
+	return u.store.URL(key)
+}
+
+// key is the inverse of url.
+func (u *UploadUseCase) key(location string) (string, error) {
+	parsed, err := url.Parse(location)
+	if err == nil && strings.HasPrefix(parsed.Path, FilesRoute+"/") {
+		return strings.TrimPrefix(parsed.Path, FilesRoute+"/"), nil
+	}
+	return u.store.Key(location)
+}
+
+// privateOwner reads the entity type and owner out of a private key, laid
+// out as private/<entity type>/<owner>/<file>.
+func privateOwner(key string) (string, uint, bool) {
+	if !storage.IsPrivate(key) || path.Clean("/"+key) != "/"+key {
+		return "", 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, storage.PrivatePrefix), "/", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return "", 0, false
+	}
+	ownerID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[0], uint(ownerID), true
+}
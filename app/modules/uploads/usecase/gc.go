@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"github.com/1rhino/clean_architecture/app/storage"
+	"gorm.io/gorm"
+)
+
+const collectBatch = 100
+
+// ReferenceImage records that the image of an entity is now the given URL,
+// or that it has none, so the upload it used before is deleted once
+// nothing else uses it. URLs outside the store reference nothing. The
+// entity is already saved when this is called, so failures are only
+// logged; the collector catches up with them.
+func (u *UploadUseCase) ReferenceImage(entityType string, entityID uint, image string) {
+	deleteAfter := u.clock.Now().Add(u.config.OrphanGrace)
+	err := u.referenceImage(entityType, entityID, image, deleteAfter)
+	if err != nil {
+		log.Printf("failed to reference image of %s %d: %v", entityType, entityID, err)
+	}
+}
+
+func (u *UploadUseCase) referenceImage(entityType string, entityID uint, image string, deleteAfter time.Time) error {
+	key := ""
+	if image != "" {
+		var err error
+		key, err = u.key(image)
+		if errors.Is(err, storage.ErrForeignURL) {
+			key = ""
+		} else if err != nil {
+			return err
+		}
+	}
+	return u.reference(entityType, entityID, models.UploadFieldImage, key, deleteAfter)
+}
+
+// ReferenceFile records that a field of an entity now holds the file
+// stored under key, or nothing when key is empty, like ReferenceImage does
+// for images.
+func (u *UploadUseCase) ReferenceFile(entityType string, entityID uint, field, key string) {
+	deleteAfter := u.clock.Now().Add(u.config.OrphanGrace)
+	if err := u.reference(entityType, entityID, field, key, deleteAfter); err != nil {
+		log.Printf("failed to reference %s of %s %d: %v", field, entityType, entityID, err)
+	}
+}
+
+// ClearReferences records that an entity is gone, so every upload it used
+// is deleted once nothing else uses it.
+func (u *UploadUseCase) ClearReferences(entityType string, entityID uint) {
+	deleteAfter := u.clock.Now().Add(u.config.OrphanGrace)
+	if err := u.uploadRepo.ClearReference(entityType, entityID, "", deleteAfter); err != nil {
+		log.Printf("failed to clear references of %s %d: %v", entityType, entityID, err)
+	}
+}
+
+func (u *UploadUseCase) reference(entityType string, entityID uint, field, key string, deleteAfter time.Time) error {
+	var upload *models.Upload
+	if key != "" {
+		var err error
+		upload, err = u.uploadRepo.FindByKey(key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	if upload == nil {
+		return u.uploadRepo.ClearReference(entityType, entityID, field, deleteAfter)
+	}
+	return u.uploadRepo.SetReference(&models.UploadReference{
+		UploadID:   upload.ID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Field:      field,
+	}, deleteAfter)
+}
+
+// CollectGarbage deletes uploads nothing has referenced for OrphanGrace,
+// then stored files older than that no upload accounts for, such as the
+// leftovers of failed uploads and presigned uploads never attached.
+//
+// Records saved before references were tracked, or whose reference failed
+// to be recorded, are looked up by their image or e-book before anything
+// is deleted, and referenced if found.
+func (u *UploadUseCase) CollectGarbage(ctx context.Context) error {
+	now := u.clock.Now()
+	for afterID := uint(0); ; {
+		uploads, err := u.uploadRepo.FindUnreferenced(afterID, collectBatch)
+		if err != nil {
+			return err
+		}
+		if len(uploads) == 0 {
+			break
+		}
+
+		for _, upload := range uploads {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			afterID = upload.ID
+			if err := u.collect(ctx, upload, now); err != nil {
+				log.Printf("failed to collect upload %s: %v", upload.Key, err)
+			}
+		}
+	}
+	return u.collectStrays(ctx, now)
+}
+
+func (u *UploadUseCase) collect(ctx context.Context, upload *models.Upload, now time.Time) error {
+	references, err := u.uploadRepo.FindUsers(upload.Key)
+	if err != nil {
+		return err
+	}
+	if len(references) > 0 {
+		for _, reference := range references {
+			reference.UploadID = upload.ID
+		}
+		return u.uploadRepo.AddReferences(references)
+	}
+
+	if upload.DeleteAfter == nil {
+		return u.uploadRepo.Schedule(upload.ID, now.Add(u.config.OrphanGrace))
+	}
+	if now.Before(*upload.DeleteAfter) {
+		return nil
+	}
+
+	deleted, err := u.uploadRepo.DeleteUnreferenced(upload.ID, now)
+	if err != nil || !deleted {
+		return err
+	}
+	// Files left behind by a failed delete are strays, and collected as
+	// such.
+	keys := []string{upload.Key}
+	for _, key := range upload.Renditions {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if err := u.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectStrays deletes stored files no upload accounts for. Files written
+// less than OrphanGrace ago may belong to an upload in progress, and files
+// an entity links to predate upload records, so both are kept, as are
+// quarantined files.
+func (u *UploadUseCase) collectStrays(ctx context.Context, now time.Time) error {
+	known, err := u.uploadRepo.FindKeys()
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-u.config.OrphanGrace)
+	return u.store.List(ctx, "", func(object storage.ObjectInfo) error {
+		if known[object.Key] || object.LastModified.After(cutoff) || strings.HasPrefix(object.Key, quarantinePrefix+"/") {
+			return nil
+		}
+		references, err := u.uploadRepo.FindUsers(object.Key)
+		if err != nil {
+			return err
+		}
+		if len(references) > 0 {
+			return nil
+		}
+
+		if err := u.store.Delete(ctx, object.Key); err != nil {
+			log.Printf("failed to delete stray file %s: %v", object.Key, err)
+		}
+		return nil
+	})
+}
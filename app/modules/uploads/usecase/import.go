@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+)
+
+var (
+	ErrInvalidImageURL = errors.New("image_url must be an http or https URL")
+	ErrBlockedAddress  = errors.New("image_url points to an address that is not allowed")
+	ErrImportFailed    = errors.New("image could not be fetched from image_url")
+
+	errTooManyRedirects = fmt.Errorf("%w: too many redirects", ErrImportFailed)
+)
+
+// blockedNetworks are ranges that aren't reachable from the internet,
+// besides the private, loopback, link-local and multicast ones net.IP
+// recognises: "this network", carrier-grade NAT, IETF protocol
+// assignments, benchmarking, reserved and NAT64, which can reach any IPv4
+// address.
+var blockedNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// blockedIP reports whether images may not be fetched from ip.
+func blockedIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newImportClient builds the client images are fetched with. Addresses
+// are checked as connections are made, after names are resolved, so a
+// name can't resolve to a public address when checked and to a private
+// one when used. Proxies are never used, since they would connect on the
+// client's behalf. blocked is blockedIP outside tests.
+func newImportClient(timeout time.Duration, maxRedirects int, blocked func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || blocked(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                  nil,
+			DialContext:            dialer.DialContext,
+			ForceAttemptHTTP2:      true,
+			TLSHandshakeTimeout:    timeout,
+			ResponseHeaderTimeout:  timeout,
+			MaxResponseHeaderBytes: 64 << 10,
+			MaxIdleConns:           10,
+			IdleConnTimeout:        90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return errTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidImageURL
+			}
+			return nil
+		},
+	}
+}
+
+// Import fetches the image at rawURL and stores it the way Upload stores
+// uploaded ones. Only public addresses are fetched from, redirects are
+// followed ImportMaxRedirects times at most and the whole fetch must
+// finish within ImportTimeout.
+func (u *UploadUseCase) Import(ctx context.Context, entityType, visibility string, ownerID uint, rawURL string) (*models.UploadResponse, error) {
+	imageURL, err := url.Parse(rawURL)
+	if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") || imageURL.Hostname() == "" || imageURL.User != nil {
+		return nil, ErrInvalidImageURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidImageURL
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, importError(rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: the server answered %s", ErrImportFailed, resp.Status)
+	}
+	if resp.ContentLength > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, u.config.MaxBytes+1))
+	if err != nil {
+		return nil, importError(rawURL, err)
+	}
+	if int64(len(data)) > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	return u.save(ctx, entityType, visibility, ownerID, data, path.Base(resp.Request.URL.Path))
+}
+
+// importError keeps the details of failed fetches, which may describe the
+// network the API runs in, out of responses.
+func importError(rawURL string, err error) error {
+	for _, known := range []error{ErrBlockedAddress, ErrInvalidImageURL, errTooManyRedirects} {
+		if errors.Is(err, known) {
+			return known
+		}
+	}
+	log.Printf("failed to import image %s: %v", rawURL, err)
+	return ErrImportFailed
+}
@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"github.com/1rhino/clean_architecture/app/storage"
+)
+
+// DirectUploadRoute is the route the router serves PutDirect on, for
+// stores that can't presign uploads themselves.
+const DirectUploadRoute = "/api/v1/uploads/direct"
+
+// incomingPrefix keeps files uploaded by clients apart from the rest of
+// the store, and private, until they are attached, checked and stored
+// under their content address.
+const incomingPrefix = storage.PrivatePrefix + "incoming"
+
+var (
+	ErrUnknownEntity     = errors.New("entity_type must be book, book_category or user")
+	ErrUnknownVisibility = errors.New("visibility must be public, signed or proxy")
+	ErrInvalidSignature  = errors.New("upload URL is invalid or has expired")
+	ErrUploadNotFound    = errors.New("upload not found")
+)
+
+var entityTypes = map[string]bool{
+	models.UploadEntityBook:         true,
+	models.UploadEntityBookCategory: true,
+	models.UploadEntityUser:         true,
+}
+
+// Presign issues a URL for the client to upload an image to without it
+// passing through the API, bound to the declared content type and size.
+// Stores that can't presign get a URL signed by the API instead. The
+// visibility the image is stored with once attached is part of its key.
+func (u *UploadUseCase) Presign(ctx context.Context, ownerID uint, input *models.PresignUploadInput) (*models.PresignedUpload, error) {
+	if !entityTypes[input.EntityType] {
+		return nil, ErrUnknownEntity
+	}
+	if !allowedTypes[input.ContentType] {
+		return nil, ErrUnsupportedType
+	}
+	if input.Size > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	visibility, err := u.resolveVisibility(input.EntityType, input.Visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%s/%d/%s/%s%s", incomingPrefix, input.EntityType, ownerID, visibility, hex.EncodeToString(token), extension(input.ContentType))
+	expiresAt := u.clock.Now().Add(u.config.PresignTTL)
+
+	if presigner, ok := u.store.(storage.Presigner); ok {
+		request, err := presigner.PresignPut(ctx, key, input.ContentType, input.Size, u.config.PresignTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &models.PresignedUpload{
+			Key:       key,
+			Method:    request.Method,
+			URL:       request.URL,
+			Headers:   request.Headers,
+			ExpiresAt: expiresAt,
+		}, nil
+	}
+
+	query := url.Values{}
+	query.Set("size", strconv.FormatInt(input.Size, 10))
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", u.sign(http.MethodPut, key, input.ContentType, input.Size, expiresAt.Unix()))
+	return &models.PresignedUpload{
+		Key:       key,
+		Method:    http.MethodPut,
+		URL:       DirectUploadRoute + "/" + key + "?" + query.Encode(),
+		Headers:   map[string]string{"Content-Type": input.ContentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// PutDirect stores a file uploaded to a URL issued by Presign. The
+// signature covers the key, content type, size and expiry, so changing any
+// of them makes the URL invalid; the body may be smaller than the size but
+// not larger.
+func (u *UploadUseCase) PutDirect(ctx context.Context, key, contentType string, query *models.DirectUploadQuery, body io.Reader) error {
+	signature := u.sign(http.MethodPut, key, contentType, query.Size, query.Expires)
+	if !hmac.Equal([]byte(query.Signature), []byte(signature)) || u.clock.Now().Unix() > query.Expires {
+		return ErrInvalidSignature
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, query.Size+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > query.Size {
+		return fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, query.Size)
+	}
+	return u.store.Put(ctx, key, bytes.NewReader(data), contentType)
+}
+
+// Finalize checks and processes a file the owner uploaded with a URL from
+// Presign, and stores it like Upload does. The uploaded file is removed
+// afterwards, whether it was accepted or not.
+func (u *UploadUseCase) Finalize(ctx context.Context, entityType string, ownerID uint, key string) (*models.UploadResponse, error) {
+	// Keys of other owners or entities are treated as missing rather than
+	// forbidden, so they can't be probed.
+	prefix := fmt.Sprintf("%s/%s/%d/", incomingPrefix, entityType, ownerID)
+	if !strings.HasPrefix(key, prefix) {
+		return nil, ErrUploadNotFound
+	}
+	visibility, _, ok := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+
+	object, err := u.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer u.deleteIncoming(key)
+
+	data, err := io.ReadAll(io.LimitReader(object.Body, u.config.MaxBytes+1))
+	object.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	return u.save(ctx, entityType, visibility, ownerID, data, "")
+}
+
+func (u *UploadUseCase) deleteIncoming(key string) {
+	if err := u.store.Delete(context.Background(), key); err != nil {
+		log.Printf("failed to delete upload %s: %v", key, err)
+	}
+}
+
+// sign covers the method too, so a URL signed for reading a file can't be
+// used to overwrite it.
+func (u *UploadUseCase) sign(method, key, contentType string, size, expires int64) string {
+	mac := hmac.New(sha256.New, u.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d\n%d", method, key, contentType, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/1rhino/clean_architecture/app/imaging"
+	"github.com/1rhino/clean_architecture/app/models"
+)
+
+// rendition is an encoded image ready to be stored.
+type rendition struct {
+	name        string
+	key         string
+	contentType string
+	data        []byte
+}
+
+// process prepares the renditions of a validated image, the original
+// first. The original keeps its encoding but loses its metadata, EXIF
+// positions included; the other sizes are encoded afresh, which carries no
+// metadata over. JPEGs are turned upright first since the EXIF orientation
+// they rely on goes with the rest of the metadata, which means re-encoding
+// the original too.
+func (u *UploadUseCase) process(data []byte, img image.Image, contentType, base string) ([]rendition, error) {
+	orientation := 1
+	if contentType == "image/jpeg" {
+		orientation = imaging.Orientation(data)
+	}
+
+	var original []byte
+	var err error
+	if orientation != 1 {
+		img = imaging.Orient(img, orientation)
+		original, err = u.encode(img, imaging.FormatJPEG)
+	} else {
+		original, err = imaging.StripMetadata(data, contentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	renditions := []rendition{{
+		name:        models.RenditionOriginal,
+		key:         base + extension(contentType),
+		contentType: contentType,
+		data:        original,
+	}}
+
+	format := u.renditionFormat(contentType)
+	renditionType := imaging.ContentTypes[format]
+	for _, size := range u.config.Renditions {
+		scaled, err := u.encode(imaging.Fit(img, size.MaxSize), format)
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, rendition{
+			name:        size.Name,
+			key:         base + "_" + size.Name + extension(renditionType),
+			contentType: renditionType,
+			data:        scaled,
+		})
+	}
+	return renditions, nil
+}
+
+// renditionFormat keeps JPEGs as JPEG and turns everything else into PNG,
+// which keeps transparency, unless WebP is configured.
+func (u *UploadUseCase) renditionFormat(contentType string) string {
+	switch {
+	case u.config.WebP:
+		return imaging.FormatWebP
+	case contentType == "image/jpeg":
+		return imaging.FormatJPEG
+	default:
+		return imaging.FormatPNG
+	}
+}
+
+func (u *UploadUseCase) encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format, u.config.JPEGQuality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
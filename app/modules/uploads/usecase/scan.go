@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/1rhino/clean_architecture/app/storage"
+)
+
+var (
+	ErrInfected    = errors.New("file contains malware")
+	ErrUnscannable = errors.New("file could not be scanned for malware")
+)
+
+// quarantinePrefix starts the keys of rejected files. They are private,
+// have no owner clients can read them as, and are left alone by the
+// collector until an operator removes them.
+const quarantinePrefix = storage.PrivatePrefix + "quarantine"
+
+// scan checks a file for malware before it is stored. Infected files and
+// files that can't be scanned are quarantined and rejected. Every verdict
+// is logged.
+func (u *UploadUseCase) scan(ctx context.Context, entityType string, ownerID uint, data []byte, sum string) error {
+	result, err := u.scanner.Scan(ctx, bytes.NewReader(data))
+	if err == nil && result == nil {
+		return nil
+	}
+
+	switch {
+	case err != nil:
+		log.Printf("failed to scan upload %s of %s owner %d: %v", sum, entityType, ownerID, err)
+		u.quarantine(ctx, entityType, ownerID, data, sum)
+		return ErrUnscannable
+	case result.Infected:
+		log.Printf("rejected upload %s of %s owner %d: %s found", sum, entityType, ownerID, result.Signature)
+		u.quarantine(ctx, entityType, ownerID, data, sum)
+		return fmt.Errorf("%w: %s", ErrInfected, result.Signature)
+	default:
+		log.Printf("scanned upload %s of %s owner %d: clean", sum, entityType, ownerID)
+		return nil
+	}
+}
+
+// quarantine keeps a copy of a rejected file for inspection. The upload is
+// rejected either way, so failures are only logged.
+func (u *UploadUseCase) quarantine(ctx context.Context, entityType string, ownerID uint, data []byte, sum string) {
+	key := fmt.Sprintf("%s/%s/%d/%s", quarantinePrefix, entityType, ownerID, sum)
+	if err := u.store.Put(ctx, key, bytes.NewReader(data), "application/octet-stream"); err != nil {
+		log.Printf("failed to quarantine upload %s: %v", sum, err)
+	}
+}
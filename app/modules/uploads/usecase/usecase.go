@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/models"
+	repository "github.com/1rhino/clean_architecture/app/modules/uploads/repositories"
+	"github.com/1rhino/clean_architecture/app/scanner"
+	"github.com/1rhino/clean_architecture/app/storage"
+	"github.com/1rhino/clean_architecture/config"
+	"gorm.io/gorm"
+)
+
+type UseCase interface {
+	Upload(ctx context.Context, entityType, visibility string, ownerID uint, file *multipart.FileHeader) (*models.UploadResponse, error)
+	Import(ctx context.Context, entityType, visibility string, ownerID uint, rawURL string) (*models.UploadResponse, error)
+	SaveImage(ctx context.Context, entityType string, ownerID uint, data []byte, filename string) (*models.UploadResponse, error)
+	StoreFile(ctx context.Context, entityType string, ownerID uint, data []byte, contentType, filename string) (*models.Upload, error)
+	Presign(ctx context.Context, ownerID uint, input *models.PresignUploadInput) (*models.PresignedUpload, error)
+	PutDirect(ctx context.Context, key, contentType string, query *models.DirectUploadQuery, body io.Reader) error
+	Finalize(ctx context.Context, entityType string, ownerID uint, key string) (*models.UploadResponse, error)
+	OpenFile(ctx context.Context, key string, userID uint, query *models.SignedFileQuery) (*storage.Object, string, error)
+	Delete(ctx context.Context, location string) error
+	ReferenceImage(entityType string, entityID uint, image string)
+	ReferenceFile(entityType string, entityID uint, field, key string)
+	ClearReferences(entityType string, entityID uint)
+	CollectGarbage(ctx context.Context) error
+}
+
+type UploadUseCase struct {
+	uploadRepo repository.UploadRepository
+	store      storage.Store
+	scanner    scanner.Scanner
+	config     config.UploadsConfig
+	clock      clock.Clock
+	secret     []byte
+	client     *http.Client
+}
+
+func NewUploadUseCase(uploadRepo repository.UploadRepository, store storage.Store, fileScanner scanner.Scanner, cfg config.UploadsConfig, clk clock.Clock) UseCase {
+	secret := []byte(cfg.SigningSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	return &UploadUseCase{
+		uploadRepo: uploadRepo,
+		store:      store,
+		scanner:    fileScanner,
+		config:     cfg,
+		clock:      clk,
+		secret:     secret,
+		client:     newImportClient(cfg.ImportTimeout, cfg.ImportMaxRedirects, blockedIP),
+	}
+}
+
+// extensions are the file extensions given to keys of common types, which
+// mime.ExtensionsByType doesn't pick consistently across systems.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+
+	"application/epub+zip": ".epub",
+	"application/pdf":      ".pdf",
+}
+
+// Upload validates the image and stores it under a key made of the entity
+// type, the owner and the SHA-256 of its content, along with its
+// renditions. When the owner already uploaded the same file it is reused
+// rather than stored again. An empty visibility stands for the entity
+// type's.
+func (u *UploadUseCase) Upload(ctx context.Context, entityType, visibility string, ownerID uint, file *multipart.FileHeader) (*models.UploadResponse, error) {
+	if file.Size > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, errors.New("failed to open file")
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, u.config.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	return u.save(ctx, entityType, visibility, ownerID, data, file.Filename)
+}
+
+// SaveImage stores an image read by the server rather than uploaded, such
+// as the cover of an e-book, the same way Upload does, with the entity
+// type's visibility.
+func (u *UploadUseCase) SaveImage(ctx context.Context, entityType string, ownerID uint, data []byte, filename string) (*models.UploadResponse, error) {
+	if int64(len(data)) > u.config.MaxBytes {
+		return nil, u.errFileTooLarge()
+	}
+	return u.save(ctx, entityType, "", ownerID, data, filename)
+}
+
+// StoreFile stores a file that isn't an image as is, under a private key
+// made like those of images, so it is only ever read through the API. The
+// caller validates it; it is scanned for malware here. As with images, the
+// same file stored twice by an owner is stored once.
+func (u *UploadUseCase) StoreFile(ctx context.Context, entityType string, ownerID uint, data []byte, contentType, filename string) (*models.Upload, error) {
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+	key := fmt.Sprintf("%s%s/%d/%s%s", storage.PrivatePrefix, entityType, ownerID, sum, extension(contentType))
+
+	existing, err := u.uploadRepo.FindByKey(key)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := u.scan(ctx, entityType, ownerID, data, sum); err != nil {
+		return nil, err
+	}
+
+	if err := u.store.Put(ctx, key, bytes.NewReader(data), contentType); err != nil {
+		return nil, err
+	}
+	deleteAfter := u.clock.Now().Add(u.config.OrphanGrace)
+	upload, _, err := u.uploadRepo.Create(&models.Upload{
+		Key:              key,
+		EntityType:       entityType,
+		OwnerID:          ownerID,
+		SHA256:           sum,
+		Size:             int64(len(data)),
+		ContentType:      contentType,
+		OriginalFilename: filename,
+		DeleteAfter:      &deleteAfter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// save validates, scans, processes and stores an image, unless the owner
+// already stored the same one with the same visibility.
+func (u *UploadUseCase) save(ctx context.Context, entityType, visibility string, ownerID uint, data []byte, filename string) (*models.UploadResponse, error) {
+	visibility, err := u.resolveVisibility(entityType, visibility)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+
+	img, contentType, err := u.validateImage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// Private keys name their visibility, which is how OpenFile knows it.
+	base := fmt.Sprintf("%s/%d/%s", entityType, ownerID, sum)
+	if visibility != models.UploadVisibilityPublic {
+		base = fmt.Sprintf("%s%s/%d/%s/%s", storage.PrivatePrefix, entityType, ownerID, visibility, sum)
+	}
+	key := base + extension(contentType)
+
+	existing, err := u.uploadRepo.FindByKey(key)
+	if err == nil {
+		return u.response(existing, true), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := u.scan(ctx, entityType, ownerID, data, sum); err != nil {
+		return nil, err
+	}
+
+	renditions, err := u.process(data, img, contentType, base)
+	if err != nil {
+		return nil, err
+	}
+	keys := models.ImageRenditions{}
+	for _, rendition := range renditions {
+		if err := u.store.Put(ctx, rendition.key, bytes.NewReader(rendition.data), rendition.contentType); err != nil {
+			return nil, err
+		}
+		if rendition.name != models.RenditionOriginal {
+			keys[rendition.name] = rendition.key
+		}
+	}
+
+	// Nothing references the upload until the entity it is for is saved.
+	deleteAfter := u.clock.Now().Add(u.config.OrphanGrace)
+	upload, created, err := u.uploadRepo.Create(&models.Upload{
+		Key:              key,
+		EntityType:       entityType,
+		OwnerID:          ownerID,
+		SHA256:           sum,
+		Size:             int64(len(renditions[0].data)),
+		ContentType:      contentType,
+		OriginalFilename: filename,
+		Renditions:       keys,
+		DeleteAfter:      &deleteAfter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u.response(upload, !created), nil
+}
+
+// Delete removes a stored file, its renditions and its record given its
+// URL. URLs that don't point into the store or FilesRoute return
+// storage.ErrForeignURL.
+func (u *UploadUseCase) Delete(ctx context.Context, location string) error {
+	key, err := u.key(location)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{key}
+	upload, err := u.uploadRepo.FindByKey(key)
+	if err == nil {
+		for _, renditionKey := range upload.Renditions {
+			keys = append(keys, renditionKey)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := u.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return u.uploadRepo.DeleteByKey(key)
+}
+
+// response lists the URL of every rendition of an upload, the original
+// included.
+func (u *UploadUseCase) response(upload *models.Upload, duplicate bool) *models.UploadResponse {
+	url := u.url(upload.Key)
+	renditions := models.ImageRenditions{models.RenditionOriginal: url}
+	for name, key := range upload.Renditions {
+		renditions[name] = u.url(key)
+	}
+
+	uploadResponse := models.FilterUploadRecord(upload, url, renditions)
+	uploadResponse.Visibility = u.visibilityOf(upload.Key)
+	uploadResponse.Duplicate = duplicate
+	return uploadResponse
+}
+
+func (u *UploadUseCase) errFileTooLarge() error {
+	return fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, u.config.MaxBytes)
+}
+
+func extension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := extensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+
+	// Decoders for the accepted image types.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrFileTooLarge    = errors.New("file is too large")
+	ErrUnsupportedType = errors.New("only JPEG, PNG, WebP and GIF images are accepted")
+	ErrImageTooLarge   = errors.New("image dimensions are too large")
+	ErrInvalidImage    = errors.New("image could not be decoded")
+)
+
+// allowedTypes are the image types accepted, as detected from the content.
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+	"image/gif":  true,
+}
+
+// validateImage checks an upload is an image of an accepted type within the
+// configured dimensions that decodes cleanly, and returns the decoded image
+// with its content type. The dimensions are read from the header before
+// decoding, so oversized images are rejected without allocating their
+// pixels.
+func (u *UploadUseCase) validateImage(f io.ReadSeeker) (image.Image, string, error) {
+	contentType, err := sniffContentType(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if !allowedTypes[contentType] {
+		return nil, "", ErrUnsupportedType
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	if config.Width > u.config.MaxWidth || config.Height > u.config.MaxHeight {
+		return nil, "", fmt.Errorf("%w: %dx%d is over the %dx%d limit",
+			ErrImageTooLarge, config.Width, config.Height, u.config.MaxWidth, u.config.MaxHeight)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	return img, contentType, nil
+}
+
+// sniffContentType detects the type from the magic bytes rather than
+// trusting the client's header.
+func sniffContentType(f io.ReadSeeker) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
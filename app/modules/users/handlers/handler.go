@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -8,17 +9,20 @@ import (
 
 	"github.com/1rhino/clean_architecture/app/middleware"
 	"github.com/1rhino/clean_architecture/app/models"
+	uploadHandlers "github.com/1rhino/clean_architecture/app/modules/uploads/handlers"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
 	user "github.com/1rhino/clean_architecture/app/modules/users/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type UserHandlers struct {
-	userUseCase user.UseCase
+	userUseCase   user.UseCase
+	uploadUseCase upload.UseCase
 }
 
-func NewUserHandlers(userUseCase user.UseCase) *UserHandlers {
-	return &UserHandlers{userUseCase: userUseCase}
+func NewUserHandlers(userUseCase user.UseCase, uploadUseCase upload.UseCase) *UserHandlers {
+	return &UserHandlers{userUseCase: userUseCase, uploadUseCase: uploadUseCase}
 }
 
 // SignUp User
@@ -95,6 +99,9 @@ func (h *UserHandlers) GetUserProfile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if middleware.NotModified(c, profile.Version, profile) {
+		return
+	}
 
 	c.JSON(http.StatusOK, profile)
 }
@@ -139,6 +146,12 @@ func (h *UserHandlers) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+	updatedUser.Version = version
+
 	// file, err := c.FormFile("image")
 	// if err == nil {
 	// 	savePath := "assets/image/" + file.Filename
@@ -150,22 +163,67 @@ func (h *UserHandlers) UpdateUser(c *gin.Context) {
 	// 	updatedUser.Image = savePath
 	// }
 
-	file, err := c.FormFile("image")
-	if err == nil {
-		uploadedURL, uploadErr := middleware.HandleUploadImage(file)
-		if uploadErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
-			return
-		}
-		updatedUser.Image = uploadedURL
+	image, renditions, ok := uploadHandlers.FormImage(c, h.uploadUseCase, models.UploadEntityUser, updatedUser.ImageVisibility, uint(userID))
+	if !ok {
+		return
 	}
+	updatedUser.Image = image
+	updatedUser.ImageRenditions = renditions
 
 	updatedUserResponse, err := h.userUseCase.UpdateUser(uint(userID), &updatedUser)
+	if errors.Is(err, models.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	middleware.SetETag(c, updatedUserResponse.Version)
+	c.JSON(http.StatusOK, updatedUserResponse)
+}
+
+// attach an avatar uploaded straight to storage to the user
+func (h *UserHandlers) AttachUserImage(c *gin.Context) {
+	var input models.AttachUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	uploaded, err := h.uploadUseCase.Finalize(c.Request.Context(), models.UploadEntityUser, userID, input.Key)
+	if err != nil {
+		uploadHandlers.WriteError(c, err)
+		return
+	}
+
+	updatedUserResponse, err := h.userUseCase.UpdateUser(userID, &models.UserUpdateInput{
+		Image:           uploaded.URL,
+		ImageRenditions: uploaded.Renditions,
+		Version:         version,
+	})
+	if errors.Is(err, models.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	middleware.SetETag(c, updatedUserResponse.Version)
 	c.JSON(http.StatusOK, updatedUserResponse)
 }
 
@@ -188,7 +246,16 @@ func (h *UserHandlers) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err := h.userUseCase.DeleteUser(uint(userID))
+	version, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	err := h.userUseCase.DeleteUser(uint(userID), version)
+	if errors.Is(err, models.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
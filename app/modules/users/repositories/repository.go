@@ -6,6 +6,7 @@ import (
 	"github.com/1rhino/clean_architecture/app/models"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepoInterface interface {
@@ -14,7 +15,7 @@ type UserRepoInterface interface {
 	GetByEmail(email string) (*models.User, error)
 	GetByID(id uint) (*models.User, error)
 	Update(user *models.User) error
-	Delete(id uint) error
+	Delete(id uint, version int64) error
 }
 
 type UserRepo struct {
@@ -77,10 +78,36 @@ func (r *UserRepo) GetByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// Update saves the user provided the stored version is still the one it
+// was read at, bumping the version.
 func (r *UserRepo) Update(user *models.User) error {
-	return r.DB.Save(user).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&previous, user.ID).Error; err != nil {
+			return err
+		}
+		if previous.Version != user.Version {
+			return models.ErrVersionConflict
+		}
+
+		user.Version++
+		return tx.Save(user).Error
+	})
 }
 
-func (r *UserRepo) Delete(id uint) error {
-	return r.DB.Delete(&models.User{}, id).Error
+// Delete removes the user, checking version when it is non-zero.
+func (r *UserRepo) Delete(id uint, version int64) error {
+	query := r.DB.Where("id = ?", id)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	result := query.Delete(&models.User{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 && version != 0 {
+		return models.ErrVersionConflict
+	}
+	return nil
 }
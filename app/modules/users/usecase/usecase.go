@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/1rhino/clean_architecture/app/models"
+	upload "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
 	users "github.com/1rhino/clean_architecture/app/modules/users/repositories"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -14,15 +15,16 @@ type UseCase interface {
 	LoginUser(ctx *gin.Context, user *models.SignInInput) (*models.User, error)
 	GetUserProfile(userID uint) (*models.UserResponse, error)
 	UpdateUser(userID uint, updatedUser *models.UserUpdateInput) (*models.UserResponse, error)
-	DeleteUser(userID uint) error
+	DeleteUser(userID uint, version int64) error
 }
 
 type UserUseCase struct {
-	userRepo users.UserRepoInterface
+	userRepo      users.UserRepoInterface
+	uploadUseCase upload.UseCase
 }
 
-func NewUserUseCase(userRepo users.UserRepoInterface) UseCase {
-	return &UserUseCase{userRepo: userRepo}
+func NewUserUseCase(userRepo users.UserRepoInterface, uploadUseCase upload.UseCase) UseCase {
+	return &UserUseCase{userRepo: userRepo, uploadUseCase: uploadUseCase}
 }
 
 func (u UserUseCase) SignUpUser(ctx *gin.Context, payload *models.SignUpInput) (*models.UserResponse, error) {
@@ -69,6 +71,9 @@ func (u *UserUseCase) UpdateUser(userID uint, updatedUser *models.UserUpdateInpu
 	if err != nil {
 		return nil, err
 	}
+	if updatedUser.Version != 0 && updatedUser.Version != user.Version {
+		return nil, models.ErrVersionConflict
+	}
 
 	if updatedUser.Name != "" {
 		user.Name = updatedUser.Name
@@ -76,22 +81,28 @@ func (u *UserUseCase) UpdateUser(userID uint, updatedUser *models.UserUpdateInpu
 	if updatedUser.Email != "" {
 		user.Email = updatedUser.Email
 	}
+	image := user.Image
 	if updatedUser.Image != "" {
 		user.Image = updatedUser.Image
+		user.ImageRenditions = updatedUser.ImageRenditions
 	}
 
 	err = u.userRepo.Update(user)
 	if err != nil {
 		return nil, err
 	}
+	if user.Image != image {
+		u.uploadUseCase.ReferenceImage(models.UploadEntityUser, user.ID, user.Image)
+	}
 
 	return models.FilterUserRecord(user), nil
 }
 
-func (u *UserUseCase) DeleteUser(userID uint) error {
-	err := u.userRepo.Delete(userID)
+func (u *UserUseCase) DeleteUser(userID uint, version int64) error {
+	err := u.userRepo.Delete(userID, version)
 	if err != nil {
 		return err
 	}
+	u.uploadUseCase.ReferenceImage(models.UploadEntityUser, userID, "")
 	return nil
 }
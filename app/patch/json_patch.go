@@ -0,0 +1,327 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONPatch applies a JSON Patch to doc. The operations are applied in
+// order and the whole patch fails if any of them does.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch must be an array of operations", ErrMalformedPatch)
+	}
+
+	for i, operation := range operations {
+		target, err = applyOperation(target, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func applyOperation(doc interface{}, operation map[string]json.RawMessage) (interface{}, error) {
+	var op string
+	if err := json.Unmarshal(operation["op"], &op); err != nil {
+		return nil, fmt.Errorf("%w: missing op", ErrMalformedPatch)
+	}
+	path, err := pointerMember(operation, "path")
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "add":
+		value, err := valueMember(operation)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		value, err := valueMember(operation)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move":
+		from, err := pointerMember(operation, "from")
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+			return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrMalformedPatch)
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := pointerMember(operation, "from")
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		value, err = deepCopy(value)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "test":
+		value, err := valueMember(operation)
+		if err != nil {
+			return nil, err
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, fmt.Errorf("%w: test of %s failed", ErrConflict, formatPointer(path))
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrMalformedPatch, op)
+	}
+}
+
+func pointerMember(operation map[string]json.RawMessage, name string) ([]string, error) {
+	var pointer string
+	if err := json.Unmarshal(operation[name], &pointer); err != nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrMalformedPatch, name)
+	}
+	return parsePointer(pointer)
+}
+
+func valueMember(operation map[string]json.RawMessage) (interface{}, error) {
+	raw, ok := operation["value"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing value", ErrMalformedPatch)
+	}
+	value, err := decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedPatch, err)
+	}
+	return value, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped reference
+// tokens. The empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrMalformedPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func formatPointer(path []string) string {
+	var pointer strings.Builder
+	for _, token := range path {
+		pointer.WriteString("/")
+		pointer.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return pointer.String()
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	current := doc
+	for i, token := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(path[:i+1])
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, notFound(path[:i+1])
+			}
+			current = node[index]
+		default:
+			return nil, notFound(path[:i+1])
+		}
+	}
+	return current, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return modifyParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(node)); err != nil {
+					return nil, notFound(path)
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, notFound(path)
+		}
+	})
+}
+
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrMalformedPatch)
+	}
+	return modifyParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, notFound(path)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, notFound(path)
+			}
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, notFound(path)
+		}
+	})
+}
+
+// modifyParent walks to the parent of the last token of path and replaces
+// it with what fn returns, which arrays need since growing or shrinking
+// them makes a new slice.
+func modifyParent(node interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	child, err := get(node, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = modifyParent(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := node.(type) {
+	case map[string]interface{}:
+		parent[path[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(path[0], len(parent)-1)
+		parent[index] = child
+	}
+	return node, nil
+}
+
+// arrayIndex parses an array index token, which must be a plain decimal
+// number no greater than max.
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+func notFound(path []string) error {
+	return fmt.Errorf("%w: path %s does not exist", ErrConflict, formatPointer(path))
+}
+
+func deepCopy(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// equal compares two JSON values, treating numbers as equal when they have
+// the same value however they are written.
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		af, errA := a.Float64()
+		bf, errB := b.Float64()
+		return errA == nil && errB == nil && af == bf
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
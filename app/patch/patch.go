@@ -0,0 +1,226 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to the JSON form of a record.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrMalformedPatch means the patch document itself is invalid.
+	ErrMalformedPatch = errors.New("malformed patch document")
+	// ErrConflict means the patch doesn't apply to the current state of the
+	// record, such as a path that doesn't exist or a failed test operation.
+	ErrConflict = errors.New("patch does not apply to the current record")
+	// ErrInvalidDocument means the patched document doesn't describe a
+	// valid record, such as a field of the wrong type or an unknown field.
+	ErrInvalidDocument = errors.New("patched document is invalid")
+	ErrUnsupportedType = errors.New("unsupported patch type")
+)
+
+// Patch is a patch document together with its media type.
+type Patch struct {
+	Type string
+	Body []byte
+}
+
+// Apply patches the JSON form of v in place. v must be a pointer, and is
+// decoded from scratch from the patched document, so fields removed by the
+// patch end up as zero values.
+func (p *Patch) Apply(v interface{}) error {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	patched, err := p.patch(doc)
+	if err != nil {
+		return err
+	}
+	return into(v, patched)
+}
+
+// ApplyExcept is Apply for records with members that must not be patched,
+// such as images, which only change through their own upload path. The
+// members are hidden from the patch and keep their values, and a patch
+// that refers to any of them fails with ErrInvalidDocument.
+func (p *Patch) ApplyExcept(v interface{}, members ...string) error {
+	for _, member := range members {
+		if p.refersTo(member) {
+			return fmt.Errorf("%w: %s can't be patched", ErrInvalidDocument, member)
+		}
+	}
+
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return err
+	}
+	hidden := make(map[string]json.RawMessage, len(members))
+	for _, member := range members {
+		if value, ok := fields[member]; ok {
+			hidden[member] = value
+			delete(fields, member)
+		}
+	}
+	if doc, err = json.Marshal(fields); err != nil {
+		return err
+	}
+
+	patched, err := p.patch(doc)
+	if err != nil {
+		return err
+	}
+	fields = nil
+	if err := json.Unmarshal(patched, &fields); err != nil || fields == nil {
+		return fmt.Errorf("%w: the patched document is not an object", ErrInvalidDocument)
+	}
+	for member, value := range hidden {
+		fields[member] = value
+	}
+	if patched, err = json.Marshal(fields); err != nil {
+		return err
+	}
+	return into(v, patched)
+}
+
+func (p *Patch) patch(doc []byte) ([]byte, error) {
+	switch p.Type {
+	case MergePatchType:
+		return MergePatch(doc, p.Body)
+	case JSONPatchType:
+		return JSONPatch(doc, p.Body)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, p.Type)
+	}
+}
+
+// refersTo reports whether the patch sets, reads or removes the top-level
+// member, counting operations on the whole document. Patches that aren't
+// well formed are left for the patch itself to reject.
+func (p *Patch) refersTo(member string) bool {
+	switch p.Type {
+	case MergePatchType:
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(p.Body, &doc); err != nil {
+			return false
+		}
+		_, ok := doc[member]
+		return ok
+	case JSONPatchType:
+		var operations []map[string]json.RawMessage
+		if err := json.Unmarshal(p.Body, &operations); err != nil {
+			return false
+		}
+		for _, operation := range operations {
+			for _, name := range []string{"path", "from"} {
+				if _, ok := operation[name]; !ok {
+					continue
+				}
+				path, err := pointerMember(operation, name)
+				if err == nil && (len(path) == 0 || path[0] == member) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// into decodes the patched document into v from scratch.
+func into(v interface{}, patched []byte) error {
+	target := reflect.ValueOf(v).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	return nil
+}
+
+// Take removes a member from the top level of the patch and returns the
+// value it sets, or nil when the patch doesn't set it, so requests can
+// carry members that aren't fields of the record along with the patch.
+// JSON Patches set members with add and replace operations. Patches that
+// aren't well formed are left for Apply to reject.
+func (p *Patch) Take(member string) (json.RawMessage, error) {
+	switch p.Type {
+	case MergePatchType:
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(p.Body, &doc); err != nil || doc == nil {
+			return nil, nil
+		}
+		value, ok := doc[member]
+		if !ok {
+			return nil, nil
+		}
+		delete(doc, member)
+		body, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		p.Body = body
+		if string(value) == "null" {
+			return nil, nil
+		}
+		return value, nil
+	case JSONPatchType:
+		var operations []map[string]json.RawMessage
+		if err := json.Unmarshal(p.Body, &operations); err != nil {
+			return nil, nil
+		}
+		pointer := formatPointer([]string{member})
+		var value json.RawMessage
+		kept := make([]map[string]json.RawMessage, 0, len(operations))
+		for _, operation := range operations {
+			var op, path string
+			_ = json.Unmarshal(operation["op"], &op)
+			_ = json.Unmarshal(operation["path"], &path)
+			if path == pointer && (op == "add" || op == "replace") {
+				value = operation["value"]
+				continue
+			}
+			kept = append(kept, operation)
+		}
+		if value == nil {
+			return nil, nil
+		}
+		body, err := json.Marshal(kept)
+		if err != nil {
+			return nil, err
+		}
+		p.Body = body
+		return value, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decode reads a JSON value keeping numbers as json.Number, so they are
+// written back exactly as they were read.
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
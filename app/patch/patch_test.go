@@ -0,0 +1,444 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("result %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("expected %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"name":"Dune","tags":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   error
+	}{
+		{
+			name:  "add member",
+			patch: `[{"op":"add","path":"/author","value":"Herbert"}]`,
+			want:  `{"name":"Dune","author":"Herbert","tags":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "add replaces existing member",
+			patch: `[{"op":"add","path":"/name","value":"Emma"}]`,
+			want:  `{"name":"Emma","tags":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "add inserts into array",
+			patch: `[{"op":"add","path":"/tags/1","value":"x"}]`,
+			want:  `{"name":"Dune","tags":["a","x","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "add at array length appends",
+			patch: `[{"op":"add","path":"/tags/3","value":"x"}]`,
+			want:  `{"name":"Dune","tags":["a","b","c","x"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "add with dash appends",
+			patch: `[{"op":"add","path":"/tags/-","value":"x"}]`,
+			want:  `{"name":"Dune","tags":["a","b","c","x"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "add past array length",
+			patch: `[{"op":"add","path":"/tags/4","value":"x"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "add with leading zero index",
+			patch: `[{"op":"add","path":"/tags/01","value":"x"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "add with negative index",
+			patch: `[{"op":"add","path":"/tags/-1","value":"x"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "add under missing parent",
+			patch: `[{"op":"add","path":"/missing/child","value":1}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "remove member",
+			patch: `[{"op":"remove","path":"/meta"}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"]}`,
+		},
+		{
+			name:  "remove array element",
+			patch: `[{"op":"remove","path":"/tags/0"}]`,
+			want:  `{"name":"Dune","tags":["b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "remove with dash",
+			patch: `[{"op":"remove","path":"/tags/-"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "remove past array end",
+			patch: `[{"op":"remove","path":"/tags/3"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "remove missing member",
+			patch: `[{"op":"remove","path":"/author"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "remove whole document",
+			patch: `[{"op":"remove","path":""}]`,
+			err:   ErrMalformedPatch,
+		},
+		{
+			name:  "replace member",
+			patch: `[{"op":"replace","path":"/meta/pages","value":500}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"],"meta":{"pages":500,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "replace array element",
+			patch: `[{"op":"replace","path":"/tags/2","value":"z"}]`,
+			want:  `{"name":"Dune","tags":["a","b","z"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "replace missing member",
+			patch: `[{"op":"replace","path":"/author","value":"x"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "replace whole document",
+			patch: `[{"op":"replace","path":"","value":{"name":"Emma"}}]`,
+			want:  `{"name":"Emma"}`,
+		},
+		{
+			name:  "move member",
+			patch: `[{"op":"move","from":"/name","path":"/title"}]`,
+			want:  `{"title":"Dune","tags":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "move array element",
+			patch: `[{"op":"move","from":"/tags/0","path":"/tags/-"}]`,
+			want:  `{"name":"Dune","tags":["b","c","a"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "move into own child",
+			patch: `[{"op":"move","from":"/meta","path":"/meta/inner"}]`,
+			err:   ErrMalformedPatch,
+		},
+		{
+			name:  "move missing member",
+			patch: `[{"op":"move","from":"/author","path":"/writer"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "copy member",
+			patch: `[{"op":"copy","from":"/tags","path":"/labels"}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"],"labels":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "copy is deep",
+			patch: `[{"op":"copy","from":"/tags","path":"/labels"},{"op":"add","path":"/labels/-","value":"d"}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"],"labels":["a","b","c","d"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "test passes",
+			patch: `[{"op":"test","path":"/tags","value":["a","b","c"]},{"op":"replace","path":"/name","value":"Emma"}]`,
+			want:  `{"name":"Emma","tags":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2}}`,
+		},
+		{
+			name:  "test compares numbers by value",
+			patch: `[{"op":"test","path":"/meta/pages","value":412.0}]`,
+			want:  doc,
+		},
+		{
+			name:  "test fails",
+			patch: `[{"op":"test","path":"/name","value":"Emma"},{"op":"replace","path":"/name","value":"x"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "test missing member",
+			patch: `[{"op":"test","path":"/author","value":null}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "escaped slash",
+			patch: `[{"op":"replace","path":"/meta/a~1b","value":10}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"],"meta":{"pages":412,"a/b":10,"m~n":2}}`,
+		},
+		{
+			name:  "escaped tilde",
+			patch: `[{"op":"remove","path":"/meta/m~0n"}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"],"meta":{"pages":412,"a/b":1}}`,
+		},
+		{
+			name:  "tilde one is not unescaped twice",
+			patch: `[{"op":"add","path":"/meta/~01","value":3}]`,
+			want:  `{"name":"Dune","tags":["a","b","c"],"meta":{"pages":412,"a/b":1,"m~n":2,"~1":3}}`,
+		},
+		{
+			name:  "path without leading slash",
+			patch: `[{"op":"remove","path":"name"}]`,
+			err:   ErrMalformedPatch,
+		},
+		{
+			name:  "unknown op",
+			patch: `[{"op":"rename","path":"/name"}]`,
+			err:   ErrMalformedPatch,
+		},
+		{
+			name:  "missing value",
+			patch: `[{"op":"add","path":"/name"}]`,
+			err:   ErrMalformedPatch,
+		},
+		{
+			name:  "not an array",
+			patch: `{"op":"add","path":"/name","value":"x"}`,
+			err:   ErrMalformedPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JSONPatch: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null removes member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"null for missing member", `{"a":"b"}`, `{"c":null}`, `{"a":"b"}`},
+		{"arrays are replaced", `{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{"nested merge", `{"a":{"b":"c","d":"e"}}`, `{"a":{"b":"x","d":null}}`, `{"a":{"b":"x"}}`},
+		{"object over scalar", `{"a":"b"}`, `{"a":{"c":"d"}}`, `{"a":{"c":"d"}}`},
+		{"nulls inside new object are dropped", `{}`, `{"a":{"b":null,"c":"d"}}`, `{"a":{"c":"d"}}`},
+		{"null inside array is kept", `{}`, `{"a":[null]}`, `{"a":[null]}`},
+		{"non-object replaces document", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"empty patch", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+		{"numbers keep their form", `{"a":1}`, `{"b":12345678901234567890}`, `{"a":1,"b":12345678901234567890}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); !errors.Is(err, ErrMalformedPatch) {
+		t.Errorf("malformed patch error = %v, want %v", err, ErrMalformedPatch)
+	}
+}
+
+type record struct {
+	Name  string   `json:"name"`
+	Image string   `json:"image"`
+	Tags  []string `json:"tags"`
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch Patch
+		want  record
+		err   error
+	}{
+		{
+			name:  "merge patch",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"name":"Emma","tags":null}`)},
+			want:  record{Name: "Emma", Image: "cover.png"},
+		},
+		{
+			name:  "JSON patch",
+			patch: Patch{Type: JSONPatchType, Body: []byte(`[{"op":"add","path":"/tags/-","value":"c"}]`)},
+			want:  record{Name: "Dune", Image: "cover.png", Tags: []string{"a", "b", "c"}},
+		},
+		{
+			name:  "unknown field",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"title":"x"}`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "wrong type",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"name":1}`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "unsupported type",
+			patch: Patch{Type: "application/json", Body: []byte(`{}`)},
+			err:   ErrUnsupportedType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &record{Name: "Dune", Image: "cover.png", Tags: []string{"a", "b"}}
+			err := tt.patch.Apply(v)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !reflect.DeepEqual(*v, tt.want) {
+				t.Errorf("got %+v, want %+v", *v, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyExcept(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch Patch
+		want  record
+		err   error
+	}{
+		{
+			name:  "other members",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"name":"Emma"}`)},
+			want:  record{Name: "Emma", Image: "cover.png", Tags: []string{"a", "b"}},
+		},
+		{
+			name:  "null removes other members",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"name":"Emma","tags":null}`)},
+			want:  record{Name: "Emma", Image: "cover.png"},
+		},
+		{
+			name:  "merge patch sets member",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"image":"other.png"}`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "merge patch removes member",
+			patch: Patch{Type: MergePatchType, Body: []byte(`{"image":null}`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "JSON patch replaces member",
+			patch: Patch{Type: JSONPatchType, Body: []byte(`[{"op":"replace","path":"/image","value":"x"}]`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "JSON patch copies member",
+			patch: Patch{Type: JSONPatchType, Body: []byte(`[{"op":"copy","from":"/image","path":"/name"}]`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "JSON patch tests member",
+			patch: Patch{Type: JSONPatchType, Body: []byte(`[{"op":"test","path":"/image","value":"cover.png"}]`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "JSON patch replaces document",
+			patch: Patch{Type: JSONPatchType, Body: []byte(`[{"op":"replace","path":"","value":{"name":"x"}}]`)},
+			err:   ErrInvalidDocument,
+		},
+		{
+			name:  "JSON patch on other members",
+			patch: Patch{Type: JSONPatchType, Body: []byte(`[{"op":"move","from":"/tags/0","path":"/tags/-"}]`)},
+			want:  record{Name: "Dune", Image: "cover.png", Tags: []string{"b", "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &record{Name: "Dune", Image: "cover.png", Tags: []string{"a", "b"}}
+			err := tt.patch.ApplyExcept(v, "image")
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyExcept: %v", err)
+			}
+			if !reflect.DeepEqual(*v, tt.want) {
+				t.Errorf("got %+v, want %+v", *v, tt.want)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    Patch
+		want     string
+		wantBody string
+	}{
+		{
+			name:     "merge patch member",
+			patch:    Patch{Type: MergePatchType, Body: []byte(`{"name":"x","image_url":"http://example.com/a.png"}`)},
+			want:     `"http://example.com/a.png"`,
+			wantBody: `{"name":"x"}`,
+		},
+		{
+			name:     "merge patch null",
+			patch:    Patch{Type: MergePatchType, Body: []byte(`{"image_url":null}`)},
+			wantBody: `{}`,
+		},
+		{
+			name:     "merge patch without member",
+			patch:    Patch{Type: MergePatchType, Body: []byte(`{"name":"x"}`)},
+			wantBody: `{"name":"x"}`,
+		},
+		{
+			name:     "JSON patch add",
+			patch:    Patch{Type: JSONPatchType, Body: []byte(`[{"op":"add","path":"/image_url","value":"u"},{"op":"remove","path":"/tags"}]`)},
+			want:     `"u"`,
+			wantBody: `[{"op":"remove","path":"/tags"}]`,
+		},
+		{
+			name:     "JSON patch leaves other ops",
+			patch:    Patch{Type: JSONPatchType, Body: []byte(`[{"op":"remove","path":"/image_url"}]`)},
+			wantBody: `[{"op":"remove","path":"/image_url"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.patch.Take("image_url")
+			if err != nil {
+				t.Fatalf("Take: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("value = %s, want %s", got, tt.want)
+			}
+			assertJSON(t, tt.patch.Body, tt.wantBody)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is how much of the file is sent to clamd at a time.
+const chunkSize = 64 << 10
+
+// ClamAV scans files with a clamd daemon, streaming them over its INSTREAM
+// command so the daemon needn't share a filesystem with the API.
+type ClamAV struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAV talks to clamd at address, a host:port or the path of a unix
+// socket. Scans taking longer than timeout fail.
+func NewClamAV(address string, timeout time.Duration) *ClamAV {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	return &ClamAV{network: network, address: address, timeout: timeout}
+}
+
+func (s *ClamAV) Scan(ctx context.Context, body io.Reader) (*Result, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The z prefix makes clamd read and end its replies with a null byte.
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	// clamd stops reading and replies early when, for instance, the file
+	// is over its size limit, so the reply is read even if sending failed.
+	writeErr, err := sendChunks(conn, body)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if writeErr != nil {
+			err = writeErr
+		}
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	return parseReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// sendChunks streams the file as length prefixed chunks, ended by an
+// empty one. Errors writing to clamd are told apart from those reading the
+// file.
+func sendChunks(conn net.Conn, body io.Reader) (writeErr, readErr error) {
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(body, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return err, nil
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err, nil
+}
+
+// parseReply reads replies such as "stream: OK", "stream: Eicar-Signature
+// FOUND" and "INSTREAM size limit exceeded. ERROR".
+func parseReply(reply string) (*Result, error) {
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if i := strings.Index(signature, ":"); i >= 0 {
+			signature = strings.TrimSpace(signature[i+1:])
+		}
+		return &Result{Infected: true, Signature: signature}, nil
+	case strings.HasSuffix(reply, ": OK"):
+		return &Result{}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
@@ -0,0 +1,174 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd speaks the INSTREAM command of clamd on a local port. It reads
+// the streamed file, up to limit bytes when limit is set, and answers with
+// reply.
+type fakeClamd struct {
+	listener net.Listener
+	reply    string
+	limit    int
+	received chan []byte
+}
+
+func newFakeClamd(t *testing.T, reply string, limit int) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	clamd := &fakeClamd{listener: listener, reply: reply, limit: limit, received: make(chan []byte, 1)}
+	go clamd.serve(t)
+	return clamd
+}
+
+func (f *fakeClamd) serve(t *testing.T) {
+	defer close(f.received)
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	command, err := reader.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		t.Errorf("command = %q, %v", command, err)
+		return
+	}
+
+	var data []byte
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			t.Errorf("reading chunk size: %v", err)
+			return
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n == 0 {
+			break
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			t.Errorf("reading chunk: %v", err)
+			return
+		}
+		data = append(data, chunk...)
+		if f.limit > 0 && len(data) > f.limit {
+			break
+		}
+	}
+	f.received <- data
+
+	conn.Write([]byte(f.reply + "\x00"))
+	// clamd hangs up here. Closing with the rest of the file unread would
+	// reset the connection and could drop the reply before the client
+	// reads it, so the rest is read and discarded instead.
+	io.Copy(io.Discard, reader)
+}
+
+func (f *fakeClamd) scanner() *ClamAV {
+	return NewClamAV(f.listener.Addr().String(), 5*time.Second)
+}
+
+func TestClamAVScan(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789abcdef"), chunkSize/8+3)
+	tests := []struct {
+		name    string
+		reply   string
+		limit   int
+		want    *Result
+		wantErr string
+	}{
+		{name: "clean", reply: "stream: OK", want: &Result{}},
+		{name: "infected", reply: "stream: Eicar-Signature FOUND", want: &Result{Infected: true, Signature: "Eicar-Signature"}},
+		{name: "error", reply: "UNKNOWN COMMAND", wantErr: "clamd: UNKNOWN COMMAND"},
+		{name: "size limit", reply: "INSTREAM size limit exceeded. ERROR", limit: chunkSize, wantErr: "clamd: INSTREAM size limit exceeded. ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clamd := newFakeClamd(t, tt.reply, tt.limit)
+
+			result, err := clamd.scanner().Scan(context.Background(), bytes.NewReader(body))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Scan error = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Scan: %v", err)
+				}
+				if *result != *tt.want {
+					t.Errorf("Scan = %+v, want %+v", *result, *tt.want)
+				}
+			}
+
+			received := <-clamd.received
+			if tt.limit == 0 && !bytes.Equal(received, body) {
+				t.Errorf("clamd received %d bytes, want the %d of the file", len(received), len(body))
+			}
+		})
+	}
+}
+
+func TestClamAVScanEmptyFile(t *testing.T) {
+	clamd := newFakeClamd(t, "stream: OK", 0)
+	if _, err := clamd.scanner().Scan(context.Background(), strings.NewReader("")); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if received := <-clamd.received; len(received) != 0 {
+		t.Errorf("clamd received %d bytes, want none", len(received))
+	}
+}
+
+func TestClamAVScanUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewClamAV(address, time.Second).Scan(context.Background(), strings.NewReader("data"))
+	if err == nil || !strings.HasPrefix(err.Error(), "clamd: ") {
+		t.Errorf("Scan error = %v, want a clamd error", err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  *Result
+	}{
+		{"stream: OK", &Result{}},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", &Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}},
+		{"1: stream: OK", &Result{}},
+		{"INSTREAM size limit exceeded. ERROR", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		result, err := parseReply(tt.reply)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parseReply(%q) = %+v, want an error", tt.reply, result)
+			}
+			continue
+		}
+		if err != nil || *result != *tt.want {
+			t.Errorf("parseReply(%q) = %+v, %v, want %+v", tt.reply, result, err, *tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package scanner checks uploaded files for malware before they are
+// stored, with ClamAV or not at all.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/1rhino/clean_architecture/config"
+)
+
+const (
+	BackendNone   = "none"
+	BackendClamAV = "clamav"
+)
+
+// Result is the verdict on a scanned file. Signature names the malware
+// found in infected files.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner reads a file through and returns its verdict. Files it can't
+// scan, whatever the reason, return an error.
+type Scanner interface {
+	// Scan returns a nil result when it doesn't actually look at files.
+	Scan(ctx context.Context, body io.Reader) (*Result, error)
+}
+
+// New builds the scanner selected by the configuration. The returned
+// scanner is safe for concurrent use.
+func New(cfg config.ScannerConfig) (Scanner, error) {
+	switch cfg.Backend {
+	case BackendNone:
+		return Noop{}, nil
+	case BackendClamAV:
+		return NewClamAV(cfg.Address, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown scanner backend %q", cfg.Backend)
+	}
+}
+
+// Noop lets every file through unscanned.
+type Noop struct{}
+
+func (Noop) Scan(ctx context.Context, body io.Reader) (*Result, error) {
+	return nil, nil
+}
@@ -1,63 +1,303 @@
 package server
 
 import (
+	"github.com/1rhino/clean_architecture/app/jobs"
 	"github.com/1rhino/clean_architecture/app/middleware"
+	"github.com/1rhino/clean_architecture/app/models"
 	handlerBookCategory "github.com/1rhino/clean_architecture/app/modules/book_category/handlers"
 	repositoryBookCategory "github.com/1rhino/clean_architecture/app/modules/book_category/repositories"
 	bookCategoryUseCase "github.com/1rhino/clean_architecture/app/modules/book_category/usecase"
 	handlerBook "github.com/1rhino/clean_architecture/app/modules/books/handlers"
 	repositoryBook "github.com/1rhino/clean_architecture/app/modules/books/repositories"
 	bookUseCase "github.com/1rhino/clean_architecture/app/modules/books/usecase"
+	handlerCopy "github.com/1rhino/clean_architecture/app/modules/copies/handlers"
+	repositoryCopy "github.com/1rhino/clean_architecture/app/modules/copies/repositories"
+	copyUseCase "github.com/1rhino/clean_architecture/app/modules/copies/usecase"
+	handlerEbook "github.com/1rhino/clean_architecture/app/modules/ebooks/handlers"
+	repositoryEbook "github.com/1rhino/clean_architecture/app/modules/ebooks/repositories"
+	ebookUseCase "github.com/1rhino/clean_architecture/app/modules/ebooks/usecase"
+	handlerFavorite "github.com/1rhino/clean_architecture/app/modules/favorites/handlers"
+	repositoryFavorite "github.com/1rhino/clean_architecture/app/modules/favorites/repositories"
+	favoriteUseCase "github.com/1rhino/clean_architecture/app/modules/favorites/usecase"
+	handlerFine "github.com/1rhino/clean_architecture/app/modules/fines/handlers"
+	repositoryFine "github.com/1rhino/clean_architecture/app/modules/fines/repositories"
+	fineUseCase "github.com/1rhino/clean_architecture/app/modules/fines/usecase"
+	handlerHold "github.com/1rhino/clean_architecture/app/modules/holds/handlers"
+	repositoryHold "github.com/1rhino/clean_architecture/app/modules/holds/repositories"
+	holdUseCase "github.com/1rhino/clean_architecture/app/modules/holds/usecase"
+	handlerLoan "github.com/1rhino/clean_architecture/app/modules/loans/handlers"
+	repositoryLoan "github.com/1rhino/clean_architecture/app/modules/loans/repositories"
+	loanUseCase "github.com/1rhino/clean_architecture/app/modules/loans/usecase"
+	handlerReadingList "github.com/1rhino/clean_architecture/app/modules/reading_lists/handlers"
+	repositoryReadingList "github.com/1rhino/clean_architecture/app/modules/reading_lists/repositories"
+	readingListUseCase "github.com/1rhino/clean_architecture/app/modules/reading_lists/usecase"
+	handlerRecommendation "github.com/1rhino/clean_architecture/app/modules/recommendations/handlers"
+	repositoryRecommendation "github.com/1rhino/clean_architecture/app/modules/recommendations/repositories"
+	recommendationUseCase "github.com/1rhino/clean_architecture/app/modules/recommendations/usecase"
+	handlerReview "github.com/1rhino/clean_architecture/app/modules/reviews/handlers"
+	repositoryReview "github.com/1rhino/clean_architecture/app/modules/reviews/repositories"
+	reviewUseCase "github.com/1rhino/clean_architecture/app/modules/reviews/usecase"
+	handlerRevision "github.com/1rhino/clean_architecture/app/modules/revisions/handlers"
+	repositoryRevision "github.com/1rhino/clean_architecture/app/modules/revisions/repositories"
+	revisionUseCase "github.com/1rhino/clean_architecture/app/modules/revisions/usecase"
+	handlerShelf "github.com/1rhino/clean_architecture/app/modules/shelves/handlers"
+	repositoryShelf "github.com/1rhino/clean_architecture/app/modules/shelves/repositories"
+	shelfUseCase "github.com/1rhino/clean_architecture/app/modules/shelves/usecase"
+	handlerTrash "github.com/1rhino/clean_architecture/app/modules/trash/handlers"
+	repositoryTrash "github.com/1rhino/clean_architecture/app/modules/trash/repositories"
+	trashUseCase "github.com/1rhino/clean_architecture/app/modules/trash/usecase"
+	handlerUpload "github.com/1rhino/clean_architecture/app/modules/uploads/handlers"
+	repositoryUpload "github.com/1rhino/clean_architecture/app/modules/uploads/repositories"
+	uploadUseCase "github.com/1rhino/clean_architecture/app/modules/uploads/usecase"
 	handlerUser "github.com/1rhino/clean_architecture/app/modules/users/handlers"
 	repositoryUser "github.com/1rhino/clean_architecture/app/modules/users/repositories"
 	userUseCase "github.com/1rhino/clean_architecture/app/modules/users/usecase"
+	"github.com/1rhino/clean_architecture/app/storage"
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(server *Server) {
-	// routes
-	r := gin.Default()
+	// routes, on the engine the CORS middleware was added to
+	r := server.Router
 	api := r.Group("/api/v1")
 
+	if server.Config.Storage.Backend == storage.BackendLocal {
+		r.StaticFS(storage.LocalRoute, storage.PublicFS(gin.Dir(server.Config.Storage.LocalDir, false)))
+	}
+
+	// Uploads
+	uploadRepo := repositoryUpload.NewUploadRepo(server.DB)
+	uploadUseCase := uploadUseCase.NewUploadUseCase(uploadRepo, server.Storage, server.Scanner, server.Config.Uploads, server.Clock)
+	uploadHandler := handlerUpload.NewUploadHandlers(uploadUseCase)
+
+	server.Scheduler.Register(jobs.Job{
+		Name:     "collect-uploads",
+		Interval: server.Config.Uploads.GCInterval,
+		Run:      uploadUseCase.CollectGarbage,
+	})
+
 	// User
 	userRepo := repositoryUser.NewUserRepo(server.DB)
-	userUseCase := userUseCase.NewUserUseCase(userRepo)
-	userHandler := handlerUser.NewUserHandlers(userUseCase)
+	userUseCase := userUseCase.NewUserUseCase(userRepo, uploadUseCase)
+	userHandler := handlerUser.NewUserHandlers(userUseCase, uploadUseCase)
 	authMiddleware := middleware.AuthMiddleware("your_secret_key")
+	ifMatch := middleware.RequireIfMatch(server.Config.HTTP.RequireIfMatch)
 
 	user := api.Group("/users")
 	user.POST("/signup", userHandler.SignUpUser)
 	user.POST("/login", userHandler.LoginUser)
 	user.GET("/profile", authMiddleware, userHandler.GetUserProfile)
 	user.DELETE("/logout", authMiddleware, userHandler.LogoutUser)
-	user.PATCH("/update", authMiddleware, userHandler.UpdateUser)
-	user.DELETE("/delete", authMiddleware, userHandler.DeleteUser)
+	user.PATCH("/update", authMiddleware, ifMatch, userHandler.UpdateUser)
+	user.DELETE("/delete", authMiddleware, ifMatch, userHandler.DeleteUser)
+	user.POST("/image", authMiddleware, ifMatch, userHandler.AttachUserImage)
+
+	uploads := api.Group("/uploads")
+	uploads.POST("/presign", authMiddleware, uploadHandler.PresignUpload)
+	// Authorized by the signature in the URL rather than a token.
+	uploads.PUT("/direct/*key", uploadHandler.PutDirectUpload)
+
+	files := api.Group("/files")
+	files.GET("/*key", handlerUpload.SignedOr(authMiddleware), uploadHandler.GetFile)
+
+	// Revisions
+	revisionRepo := repositoryRevision.NewRevisionRepo(server.DB)
+	revisionUseCase := revisionUseCase.NewRevisionUseCase(revisionRepo)
+	revisionHandler := handlerRevision.NewRevisionHandlers(revisionUseCase)
 
 	// Books
 	bookRepo := repositoryBook.NewBookRepo(server.DB)
-	bookUseCase := bookUseCase.NewBookUseCase(bookRepo)
-	bookHandler := handlerBook.NewBookHandlers(bookUseCase)
+	bookUseCase := bookUseCase.NewBookUseCase(bookRepo, revisionRepo, uploadUseCase)
+	bookHandler := handlerBook.NewBookHandlers(bookUseCase, uploadUseCase)
 
 	books := api.Group("/books")
 	books.POST("/create", authMiddleware, bookHandler.CreateBook)
 	books.GET("/lists", authMiddleware, bookHandler.GetAllBooks)
 	books.GET("/user/lists", authMiddleware, bookHandler.GetBooks)
 	books.GET("/detail/:id", authMiddleware, bookHandler.GetBookDetail)
-	books.PATCH("/update/:id", authMiddleware, bookHandler.UpdateBook)
-	books.DELETE("/delete/:id", authMiddleware, bookHandler.DeleteBook)
+	books.PATCH("/update/:id", authMiddleware, ifMatch, bookHandler.UpdateBook)
+	books.DELETE("/delete/:id", authMiddleware, ifMatch, bookHandler.DeleteBook)
+	books.POST("/image/:id", authMiddleware, ifMatch, bookHandler.AttachBookImage)
+	books.GET("/history/:id", authMiddleware, revisionHandler.GetHistory(models.RevisionEntityBook))
+	books.GET("/history/:id/:revision", authMiddleware, revisionHandler.GetRevision(models.RevisionEntityBook))
+	books.POST("/revert/:id/:revision", authMiddleware, ifMatch, bookHandler.RevertBook)
 
 	// Book Category
 	bookCategoryRepo := repositoryBookCategory.NewBookCategoryRepo(server.DB)
-	bookCategoryUseCase := bookCategoryUseCase.NewBookCategoryUseCase(bookCategoryRepo)
-	bookCategoryHandler := handlerBookCategory.NewBookCategoryHandlers(bookCategoryUseCase)
+	bookCategoryUseCase := bookCategoryUseCase.NewBookCategoryUseCase(bookCategoryRepo, revisionRepo, uploadUseCase)
+	bookCategoryHandler := handlerBookCategory.NewBookCategoryHandlers(bookCategoryUseCase, uploadUseCase)
 
 	bookCategories := api.Group("/book_categories")
 	bookCategories.POST("/create", authMiddleware, bookCategoryHandler.CreateBookCategory)
 	bookCategories.GET("/user/lists", authMiddleware, bookCategoryHandler.GetBookCategories)
 	bookCategories.GET("/lists", authMiddleware, bookCategoryHandler.GetAllBookCategories)
 	bookCategories.GET("/detail/:id", authMiddleware, bookCategoryHandler.GetBookCategoryDetail)
-	bookCategories.PATCH("/update/:id", authMiddleware, bookCategoryHandler.UpdateBookCategory)
-	bookCategories.DELETE("/delete/:id", authMiddleware, bookCategoryHandler.DeleteBookCategory)
+	bookCategories.PATCH("/update/:id", authMiddleware, ifMatch, bookCategoryHandler.UpdateBookCategory)
+	bookCategories.DELETE("/delete/:id", authMiddleware, ifMatch, bookCategoryHandler.DeleteBookCategory)
+	bookCategories.POST("/image/:id", authMiddleware, ifMatch, bookCategoryHandler.AttachBookCategoryImage)
+	bookCategories.POST("/merge/:id", authMiddleware, ifMatch, bookCategoryHandler.MergeBookCategory)
+	bookCategories.GET("/history/:id", authMiddleware, revisionHandler.GetHistory(models.RevisionEntityBookCategory))
+	bookCategories.GET("/history/:id/:revision", authMiddleware, revisionHandler.GetRevision(models.RevisionEntityBookCategory))
+	bookCategories.POST("/revert/:id/:revision", authMiddleware, ifMatch, bookCategoryHandler.RevertBookCategory)
+
+	// Trash
+	trashRepo := repositoryTrash.NewTrashRepo(server.DB)
+	trashUseCase := trashUseCase.NewTrashUseCase(trashRepo, server.Config.Trash, server.Clock, uploadUseCase)
+	trashHandler := handlerTrash.NewTrashHandlers(trashUseCase)
+
+	books.GET("/trash", authMiddleware, trashHandler.GetDeletedBooks)
+	books.POST("/restore/:id", authMiddleware, trashHandler.RestoreBook)
+	books.DELETE("/purge/:id", authMiddleware, trashHandler.PurgeBook)
+	bookCategories.GET("/trash", authMiddleware, trashHandler.GetDeletedBookCategories)
+	bookCategories.POST("/restore/:id", authMiddleware, trashHandler.RestoreBookCategory)
+	bookCategories.DELETE("/purge/:id", authMiddleware, trashHandler.PurgeBookCategory)
+
+	server.Scheduler.Register(jobs.Job{
+		Name:     "purge-trash",
+		Interval: server.Config.Trash.PurgeInterval,
+		Run:      trashUseCase.PurgeExpired,
+	})
+
+	// Reviews
+	reviewRepo := repositoryReview.NewReviewRepo(server.DB)
+	reviewUseCase := reviewUseCase.NewReviewUseCase(reviewRepo, bookRepo)
+	reviewHandler := handlerReview.NewReviewHandlers(reviewUseCase)
+
+	reviews := api.Group("/reviews")
+	reviews.POST("/create", authMiddleware, reviewHandler.CreateReview)
+	reviews.GET("/book/:id", authMiddleware, reviewHandler.GetBookReviews)
+	reviews.GET("/user/lists", authMiddleware, reviewHandler.GetReviews)
+	reviews.GET("/detail/:id", authMiddleware, reviewHandler.GetReviewDetail)
+	reviews.PATCH("/update/:id", authMiddleware, reviewHandler.UpdateReview)
+	reviews.DELETE("/delete/:id", authMiddleware, reviewHandler.DeleteReview)
+
+	// Shelves
+	shelfRepo := repositoryShelf.NewShelfRepo(server.DB)
+	shelfUseCase := shelfUseCase.NewShelfUseCase(shelfRepo, bookRepo)
+	shelfHandler := handlerShelf.NewShelfHandlers(shelfUseCase)
+
+	shelves := api.Group("/shelves")
+	shelves.GET("/lists", authMiddleware, shelfHandler.GetShelf)
+	shelves.GET("/stats", authMiddleware, shelfHandler.GetStats)
+	shelves.GET("/detail/:id", authMiddleware, shelfHandler.GetShelfEntry)
+	shelves.PATCH("/status/:id", authMiddleware, shelfHandler.UpdateStatus)
+	shelves.POST("/progress/:id", authMiddleware, shelfHandler.RecordProgress)
+	shelves.DELETE("/delete/:id", authMiddleware, shelfHandler.DeleteShelfEntry)
+
+	// Reading Lists
+	readingListRepo := repositoryReadingList.NewReadingListRepo(server.DB)
+	readingListUseCase := readingListUseCase.NewReadingListUseCase(readingListRepo, bookRepo)
+	readingListHandler := handlerReadingList.NewReadingListHandlers(readingListUseCase)
+
+	readingLists := api.Group("/reading_lists")
+	readingLists.POST("/create", authMiddleware, readingListHandler.CreateReadingList)
+	readingLists.GET("/user/lists", authMiddleware, readingListHandler.GetReadingLists)
+	readingLists.GET("/detail/:id", authMiddleware, readingListHandler.GetReadingListDetail)
+	readingLists.PATCH("/update/:id", authMiddleware, readingListHandler.UpdateReadingList)
+	readingLists.DELETE("/delete/:id", authMiddleware, readingListHandler.DeleteReadingList)
+	readingLists.POST("/items/:id", authMiddleware, readingListHandler.AddItem)
+	readingLists.PATCH("/items/:id/:item_id", authMiddleware, readingListHandler.UpdateItem)
+	readingLists.DELETE("/items/:id/:item_id", authMiddleware, readingListHandler.DeleteItem)
+	readingLists.PATCH("/reorder/:id", authMiddleware, readingListHandler.Reorder)
+
+	// Copies
+	copyRepo := repositoryCopy.NewCopyRepo(server.DB)
+	copyUseCase := copyUseCase.NewCopyUseCase(copyRepo, bookRepo)
+	copyHandler := handlerCopy.NewCopyHandlers(copyUseCase)
+
+	copies := api.Group("/copies")
+	copies.POST("/create", authMiddleware, copyHandler.CreateCopy)
+	copies.GET("/book/:id", authMiddleware, copyHandler.GetBookCopies)
+	copies.GET("/detail/:id", authMiddleware, copyHandler.GetCopyDetail)
+	copies.PATCH("/update/:id", authMiddleware, copyHandler.UpdateCopy)
+	copies.DELETE("/delete/:id", authMiddleware, copyHandler.DeleteCopy)
+
+	// Fines
+	fineRepo := repositoryFine.NewFineRepo(server.DB)
+	fineUseCase := fineUseCase.NewFineUseCase(fineRepo, bookRepo, server.Config.Fines, server.Clock)
+	fineHandler := handlerFine.NewFineHandlers(fineUseCase)
+
+	fines := api.Group("/fines")
+	fines.GET("/user/lists", authMiddleware, fineHandler.GetFines)
+	fines.GET("/detail/:id", authMiddleware, fineHandler.GetFineDetail)
+	fines.POST("/pay/:id", authMiddleware, fineHandler.RecordPayment)
+	fines.POST("/waive/:id", authMiddleware, fineHandler.WaiveFine)
+
+	server.Scheduler.Register(jobs.Job{
+		Name:     "scan-overdue-loans",
+		Interval: server.Config.Fines.OverdueInterval,
+		Run:      fineUseCase.ScanOverdue,
+	})
+
+	// Loans
+	loanRepo := repositoryLoan.NewLoanRepo(server.DB)
+	loanUseCase := loanUseCase.NewLoanUseCase(loanRepo, bookRepo, fineUseCase, server.Config.Library, server.Config.Fines, server.Clock)
+	loanHandler := handlerLoan.NewLoanHandlers(loanUseCase)
+
+	loans := api.Group("/loans")
+	loans.POST("/checkout", authMiddleware, loanHandler.Checkout)
+	loans.POST("/return/:id", authMiddleware, loanHandler.ReturnLoan)
+	loans.POST("/renew/:id", authMiddleware, loanHandler.RenewLoan)
+	loans.GET("/user/lists", authMiddleware, loanHandler.GetLoans)
+	loans.GET("/detail/:id", authMiddleware, loanHandler.GetLoanDetail)
+
+	// Holds
+	holdRepo := repositoryHold.NewHoldRepo(server.DB)
+	holdUseCase := holdUseCase.NewHoldUseCase(holdRepo, bookRepo, server.Config.Library, server.Clock)
+	holdHandler := handlerHold.NewHoldHandlers(holdUseCase)
+
+	holds := api.Group("/holds")
+	holds.POST("/create", authMiddleware, holdHandler.PlaceHold)
+	holds.GET("/user/lists", authMiddleware, holdHandler.GetHolds)
+	holds.GET("/detail/:id", authMiddleware, holdHandler.GetHoldDetail)
+	holds.DELETE("/cancel/:id", authMiddleware, holdHandler.CancelHold)
+
+	server.Scheduler.Register(jobs.Job{
+		Name:     "expire-holds",
+		Interval: server.Config.Library.HoldExpiryInterval,
+		Run:      holdUseCase.ExpireHolds,
+	})
+
+	// Favorites
+	favoriteRepo := repositoryFavorite.NewFavoriteRepo(server.DB)
+	favoriteUseCase := favoriteUseCase.NewFavoriteUseCase(favoriteRepo, bookRepo)
+	favoriteHandler := handlerFavorite.NewFavoriteHandlers(favoriteUseCase)
+
+	favorites := api.Group("/favorites")
+	favorites.POST("/create", authMiddleware, favoriteHandler.AddFavorite)
+	favorites.GET("/user/lists", authMiddleware, favoriteHandler.GetFavorites)
+	favorites.DELETE("/delete/:id", authMiddleware, favoriteHandler.RemoveFavorite)
+
+	// E-books
+	ebookRepo := repositoryEbook.NewEbookRepo(server.DB)
+	ebookUseCase := ebookUseCase.NewEbookUseCase(ebookRepo, bookRepo, uploadUseCase, server.Storage, server.Config.Ebooks, server.Clock)
+	ebookHandler := handlerEbook.NewEbookHandlers(ebookUseCase)
+
+	ebooks := api.Group("/ebooks")
+	ebooks.POST("/book/:id", authMiddleware, ifMatch, ebookHandler.AttachEbook)
+	ebooks.GET("/book/:id", authMiddleware, ebookHandler.GetBookEbooks)
+	ebooks.GET("/download/:id", authMiddleware, ebookHandler.DownloadEbook)
+	ebooks.HEAD("/download/:id", authMiddleware, ebookHandler.DownloadEbook)
+	ebooks.DELETE("/delete/:id", authMiddleware, ebookHandler.DeleteEbook)
+	ebooks.GET("/user/downloads", authMiddleware, ebookHandler.GetDownloads)
+
+	// Recommendations
+	recommendationRepo := repositoryRecommendation.NewRecommendationRepo(server.DB)
+	recommendationUseCase := recommendationUseCase.NewRecommendationUseCase(recommendationRepo, bookRepo, server.Clock)
+	recommendationHandler := handlerRecommendation.NewRecommendationHandlers(recommendationUseCase)
+
+	books.GET("/:id/similar", authMiddleware, recommendationHandler.GetSimilarBooks)
+	user.GET("/recommendations", authMiddleware, recommendationHandler.GetRecommendations)
+
+	server.Scheduler.Register(jobs.Job{
+		Name:     "refresh-recommendations",
+		Interval: server.Config.Recommendations.RefreshInterval,
+		Run:      recommendationUseCase.RefreshRecommendations,
+	})
 
-	server.Router = r
+	// Public, no authentication
+	public := api.Group("/public")
+	public.GET("/reading_lists", readingListHandler.GetPublicReadingLists)
+	public.GET("/reading_lists/detail/:id", readingListHandler.GetPublicReadingList)
+	public.GET("/reading_lists/shared/:token", readingListHandler.GetSharedReadingList)
 }
@@ -1,6 +1,12 @@
 package server
 
 import (
+	"context"
+
+	"github.com/1rhino/clean_architecture/app/clock"
+	"github.com/1rhino/clean_architecture/app/jobs"
+	"github.com/1rhino/clean_architecture/app/scanner"
+	"github.com/1rhino/clean_architecture/app/storage"
 	"github.com/1rhino/clean_architecture/config"
 	"github.com/1rhino/clean_architecture/db"
 	"github.com/gin-contrib/cors"
@@ -10,17 +16,34 @@ import (
 
 // Server struct
 type Server struct {
-	Router *gin.Engine
-	DB     *gorm.DB
-	Config *config.Config
+	Router    *gin.Engine
+	DB        *gorm.DB
+	Config    *config.Config
+	Scheduler *jobs.Scheduler
+	Clock     clock.Clock
+	Storage   storage.Store
+	Scanner   scanner.Scanner
 }
 
 // NewServer function
 func NewServer(cfg *config.Config) *Server {
+	store, err := storage.New(context.Background(), cfg.Storage)
+	if err != nil {
+		panic(err.Error())
+	}
+	fileScanner, err := scanner.New(cfg.Scanner)
+	if err != nil {
+		panic(err.Error())
+	}
+
 	return &Server{
-		Router: gin.Default(),
-		DB:     db.Init(cfg),
-		Config: cfg,
+		Router:    gin.Default(),
+		DB:        db.Init(cfg),
+		Config:    cfg,
+		Scheduler: jobs.NewScheduler(),
+		Clock:     clock.New(),
+		Storage:   store,
+		Scanner:   fileScanner,
 	}
 }
 
@@ -29,11 +52,13 @@ func (server *Server) Start() error {
 	server.Router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Content-Length", "Accept-Language", "Accept-Encoding", "Connection", "Access-Control-Allow-Origin"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Content-Length", "Accept-Language", "Accept-Encoding", "Connection", "Access-Control-Allow-Origin", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"ETag"},
 		AllowCredentials: true,
 	}))
 
 	SetupRoutes(server)
+	server.Scheduler.Start(context.Background())
 
 	return server.Router.Run(":" + server.Config.HTTP.Port)
 }
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalRoute is the path the router serves the local store's files from.
+const LocalRoute = "/uploads"
+
+// Local keeps files in a directory on disk. It needs no network access,
+// which makes it the backend for development and tests.
+type Local struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocal stores files under dir. Their URLs start with publicURL, which
+// defaults to LocalRoute on the API's own host.
+func NewLocal(dir, publicURL string) (*Local, error) {
+	if publicURL == "" {
+		publicURL = LocalRoute
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir, publicURL: publicURL}, nil
+}
+
+// Put writes to a temporary file first, so readers never see a partly
+// written file.
+func (s *Local) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *Local) Get(ctx context.Context, key string) (*Object, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+func (s *Local) GetRange(ctx context.Context, key string, offset, length int64) (*Object, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var body io.ReadCloser = file
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if length >= 0 {
+		body = limitedFile{io.LimitReader(file, length), file}
+	}
+	return &Object{
+		Body:        body,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+	}, nil
+}
+
+type limitedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// Delete treats a missing file as already deleted.
+func (s *Local) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List skips the temporary files of writes in progress.
+func (s *Local) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(s.dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			// Deleted since the directory was read.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+}
+
+func (s *Local) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
+
+func (s *Local) Key(location string) (string, error) {
+	return trimURL(s.publicURL, location)
+}
+
+// PublicFS hides the private files of a local store's directory, for
+// serving it on LocalRoute.
+func PublicFS(fs http.FileSystem) http.FileSystem {
+	return publicFS{fs}
+}
+
+type publicFS struct {
+	http.FileSystem
+}
+
+func (fs publicFS) Open(name string) (http.File, error) {
+	// The trailing slash also catches the private directory itself.
+	if IsPrivate(strings.TrimPrefix(path.Clean("/"+name), "/") + "/") {
+		return nil, os.ErrNotExist
+	}
+	return fs.FileSystem.Open(name)
+}
+
+// path maps a key to a file inside the store's directory, refusing keys
+// that would escape it.
+func (s *Local) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func newTestLocal(t *testing.T) (*Local, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewLocal(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func readObject(t *testing.T, object *Object) string {
+	t.Helper()
+	defer object.Body.Close()
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLocalPutGet(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestLocal(t)
+
+	if err := store.Put(ctx, "books/1/cover.png", strings.NewReader("first"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "books/1/cover.png", strings.NewReader("second"), "image/png"); err != nil {
+		t.Fatalf("Put over existing file: %v", err)
+	}
+
+	object, err := store.Get(ctx, "books/1/cover.png")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := readObject(t, object); got != "second" {
+		t.Errorf("body = %q, want %q", got, "second")
+	}
+	if object.Size != 6 {
+		t.Errorf("size = %d, want 6", object.Size)
+	}
+	if object.ContentType != "image/png" {
+		t.Errorf("content type = %q, want image/png", object.ContentType)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "books", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the file", len(entries))
+	}
+
+	if _, err := store.Get(ctx, "books/1/missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing file error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestLocalGetRange(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestLocal(t)
+	if err := store.Put(ctx, "file.txt", strings.NewReader("0123456789"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{0, 4, "0123"},
+		{6, -1, "6789"},
+		{3, 2, "34"},
+		{8, 10, "89"},
+	}
+	for _, tt := range tests {
+		object, err := store.GetRange(ctx, "file.txt", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		if got := readObject(t, object); got != tt.want {
+			t.Errorf("GetRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+		if object.Size != 10 {
+			t.Errorf("GetRange(%d, %d) size = %d, want the whole file's", tt.offset, tt.length, object.Size)
+		}
+	}
+}
+
+func TestLocalDelete(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestLocal(t)
+	if err := store.Put(ctx, "a/b.txt", strings.NewReader("x"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete(ctx, "a/b.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "a/b.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want %v", err, ErrNotFound)
+	}
+	if err := store.Delete(ctx, "a/b.txt"); err != nil {
+		t.Errorf("Delete of a missing file: %v", err)
+	}
+}
+
+func TestLocalList(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestLocal(t)
+	for _, key := range []string{"books/1.png", "books/2.png", "categories/1.png", PrivatePrefix + "books/3.pdf"} {
+		if err := store.Put(ctx, key, strings.NewReader(key), "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A write in progress.
+	if err := os.WriteFile(filepath.Join(dir, "books", ".upload-123"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	err := store.List(ctx, "books/", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		if info.Size != int64(len(info.Key)) {
+			t.Errorf("%s size = %d, want %d", info.Key, info.Size, len(info.Key))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "books/1.png,books/2.png" {
+		t.Errorf("keys = %v", keys)
+	}
+}
+
+func TestLocalRejectsEscapingKeys(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestLocal(t)
+	outside := filepath.Join(filepath.Dir(dir), "outside.txt")
+
+	keys := []string{
+		"",
+		"/",
+		"../outside.txt",
+		"a/../../outside.txt",
+		"a/../b.txt",
+		"./a.txt",
+		"/a.txt",
+		"a//b.txt",
+		"a/",
+	}
+	for _, key := range keys {
+		if err := store.Put(ctx, key, strings.NewReader("x"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+		if err := store.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+	}
+	if _, err := os.Stat(outside); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file was written outside the store: %v", err)
+	}
+}
+
+func TestLocalURLAndKey(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "https://cdn.example.com/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := store.URL("books/a b#1.png")
+	if url != "https://cdn.example.com/files/books/a%20b%231.png" {
+		t.Errorf("URL = %q", url)
+	}
+	key, err := store.Key(url)
+	if err != nil || key != "books/a b#1.png" {
+		t.Errorf("Key(%q) = %q, %v", url, key, err)
+	}
+	if _, err := store.Key("https://elsewhere.example.com/files/books/1.png"); !errors.Is(err, ErrForeignURL) {
+		t.Errorf("Key of another host error = %v, want %v", err, ErrForeignURL)
+	}
+	if _, err := store.Key("/other/books/1.png"); !errors.Is(err, ErrForeignURL) {
+		t.Errorf("Key outside the base path error = %v, want %v", err, ErrForeignURL)
+	}
+}
+
+func TestPublicFSHidesPrivateFiles(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestLocal(t)
+	for _, key := range []string{"books/1.png", PrivatePrefix + "books/2.pdf"} {
+		if err := store.Put(ctx, key, strings.NewReader("data"), "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := http.FileServer(PublicFS(http.Dir(dir)))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/books/1.png", http.StatusOK},
+		{"/" + PrivatePrefix + "books/2.pdf", http.StatusNotFound},
+		{"/" + strings.TrimSuffix(PrivatePrefix, "/"), http.StatusNotFound},
+		{"/" + PrivatePrefix, http.StatusNotFound},
+		{"/books/../" + PrivatePrefix + "books/2.pdf", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.URL.Path = tt.path
+		server.ServeHTTP(recorder, request)
+		if recorder.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, recorder.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// RangeReader reads a stored file from any offset, fetching the file from
+// there on on the first read after a seek. It lets http.ServeContent
+// answer range requests without reading the whole file.
+type RangeReader struct {
+	ctx    context.Context
+	store  Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewRangeReader reads the file of the given size stored under key.
+// Callers must close it.
+func NewRangeReader(ctx context.Context, store Store, key string, size int64) *RangeReader {
+	return &RangeReader{ctx: ctx, store: store, key: key, size: size}
+}
+
+func (r *RangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		object, err := r.store.GetRange(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = object.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("storage: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *RangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type S3Options struct {
+	Bucket string
+	// Region falls back to the AWS SDK's usual sources when empty.
+	Region string
+	// Endpoint points the client at an S3-compatible server such as MinIO
+	// instead of AWS.
+	Endpoint string
+	// PublicURL replaces the bucket's own URL in file URLs, for buckets
+	// served through a CDN.
+	PublicURL string
+	// PathStyle addresses the bucket as endpoint/bucket/key, which most
+	// S3-compatible servers need.
+	PathStyle bool
+}
+
+// S3 keeps files in an S3 bucket. Credentials come from the AWS SDK's
+// default chain, such as the AWS_ACCESS_KEY_ID variables.
+type S3 struct {
+	client    *s3.Client
+	uploader  *manager.Uploader
+	presigner *s3.PresignClient
+	options   S3Options
+	baseURL   string
+}
+
+func NewS3(ctx context.Context, options S3Options) (*S3, error) {
+	if options.Bucket == "" {
+		return nil, errors.New("the s3 storage backend needs STORAGE_BUCKET")
+	}
+
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if options.Region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(options.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if options.Endpoint != "" {
+			o.BaseEndpoint = aws.String(options.Endpoint)
+		}
+		o.UsePathStyle = options.PathStyle
+	})
+
+	baseURL := options.PublicURL
+	switch {
+	case baseURL != "":
+	case options.Endpoint != "" && options.PathStyle:
+		baseURL = strings.TrimSuffix(options.Endpoint, "/") + "/" + options.Bucket
+	case options.Endpoint != "":
+		endpoint, err := url.Parse(options.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		baseURL = fmt.Sprintf("%s://%s.%s", endpoint.Scheme, options.Bucket, endpoint.Host)
+	case cfg.Region != "":
+		baseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", options.Bucket, cfg.Region)
+	default:
+		baseURL = fmt.Sprintf("https://%s.s3.amazonaws.com", options.Bucket)
+	}
+
+	return &S3{
+		client:    client,
+		uploader:  manager.NewUploader(client),
+		presigner: s3.NewPresignClient(client),
+		options:   options,
+		baseURL:   baseURL,
+	}, nil
+}
+
+// Put streams the body in parts, so its size needn't be known up front.
+// Files are made public unless their key is private.
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.options.Bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if !IsPrivate(key) {
+		input.ACL = types.ObjectCannedACLPublicRead
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	_, err := s.uploader.Upload(ctx, input)
+	return err
+}
+
+// PresignPut signs the content type and length into the URL, so S3 rejects
+// uploads that don't match them. Unlike Put, the file stays private.
+func (s *S3) PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedRequest, error) {
+	request, err := s.presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.options.Bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	for name, values := range request.SignedHeader {
+		// Clients set the host from the URL themselves.
+		if name == "Host" {
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+	return &PresignedRequest{Method: request.Method, URL: request.URL, Headers: headers}, nil
+}
+
+func (s *S3) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	request, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.options.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (*Object, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.options.Bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Body:        output.Body,
+		Size:        aws.ToInt64(output.ContentLength),
+		ContentType: aws.ToString(output.ContentType),
+	}, nil
+}
+
+func (s *S3) GetRange(ctx context.Context, key string, offset, length int64) (*Object, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		byteRange += strconv.FormatInt(offset+length-1, 10)
+	}
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.options.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(byteRange),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Body:        output.Body,
+		Size:        contentRangeSize(aws.ToString(output.ContentRange)),
+		ContentType: aws.ToString(output.ContentType),
+	}, nil
+}
+
+// contentRangeSize reads the size of the whole file from a Content-Range
+// header such as "bytes 0-99/1234".
+func contentRangeSize(contentRange string) int64 {
+	_, total, found := strings.Cut(contentRange, "/")
+	if !found {
+		return -1
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// Delete succeeds for keys that don't exist, as S3 itself does.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.options.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.options.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			err := fn(ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
+
+// Key also understands the virtual-hosted (bucket.s3.amazonaws.com/key) and
+// path style (s3.amazonaws.com/bucket/key) locations the upload manager
+// returned before URLs were built by the store.
+func (s *S3) Key(location string) (string, error) {
+	if key, err := trimURL(s.baseURL, location); err == nil {
+		return key, nil
+	}
+
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	switch {
+	case strings.HasPrefix(parsed.Host, s.options.Bucket+"."):
+	case strings.HasPrefix(key, s.options.Bucket+"/"):
+		key = strings.TrimPrefix(key, s.options.Bucket+"/")
+	default:
+		return "", ErrForeignURL
+	}
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
@@ -0,0 +1,154 @@
+// Package storage keeps uploaded files in a configurable backend: the local
+// filesystem, AWS S3 or an S3-compatible server such as MinIO.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/1rhino/clean_architecture/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+	BackendMinIO = "minio"
+)
+
+var (
+	ErrNotFound   = errors.New("stored file not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+	// ErrForeignURL is returned by Key for URLs that don't point into the
+	// store, such as images linked from elsewhere.
+	ErrForeignURL = errors.New("url does not belong to this store")
+)
+
+// PrivatePrefix starts the keys of files that must not be readable by
+// anyone holding their URL. S3 stores them without the public-read ACL and
+// the local route doesn't serve them; they are read through signed URLs or
+// through the API instead.
+const PrivatePrefix = "private/"
+
+// IsPrivate reports whether the file stored under key is private.
+func IsPrivate(key string) bool {
+	return strings.HasPrefix(key, PrivatePrefix)
+}
+
+// Store keeps files under slash separated keys.
+type Store interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	// GetRange reads length bytes of the file from offset on, or up to its
+	// end when length is negative. Size is that of the whole file.
+	GetRange(ctx context.Context, key string, offset, length int64) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every file whose key starts with prefix, stopping
+	// at the first error fn returns.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+	// URL is where clients can fetch the file stored under key.
+	URL(key string) string
+	// Key is the inverse of URL, for records that only kept the URL.
+	Key(location string) (string, error)
+}
+
+// Presigner is implemented by stores clients can upload to and download
+// from directly, so the file never passes through the API.
+type Presigner interface {
+	// PresignPut returns a request storing a file of the given type and
+	// size under key, valid until it expires.
+	PresignPut(ctx context.Context, key, contentType string, size int64, expires time.Duration) (*PresignedRequest, error)
+	// PresignGet returns a URL reading the file stored under key, private
+	// or not, valid until it expires.
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// PresignedRequest is a request for the client to make, sending Headers
+// along unchanged.
+type PresignedRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+}
+
+// ObjectInfo describes a stored file without reading it.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Object is a stored file. Callers must close Body.
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+}
+
+// New builds the store selected by the configuration. It is meant to be
+// called once at startup; the returned store is safe for concurrent use.
+func New(ctx context.Context, cfg config.StorageConfig) (Store, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		return NewLocal(cfg.LocalDir, cfg.PublicURL)
+	case BackendS3:
+		return NewS3(ctx, S3Options{
+			Bucket:    cfg.Bucket,
+			Region:    cfg.Region,
+			Endpoint:  cfg.Endpoint,
+			PublicURL: cfg.PublicURL,
+		})
+	case BackendMinIO:
+		if cfg.Endpoint == "" {
+			return nil, errors.New("the minio storage backend needs STORAGE_ENDPOINT")
+		}
+		return NewS3(ctx, S3Options{
+			Bucket:    cfg.Bucket,
+			Region:    cfg.Region,
+			Endpoint:  cfg.Endpoint,
+			PublicURL: cfg.PublicURL,
+			PathStyle: true,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// joinURL appends an escaped key to a base URL.
+func joinURL(base, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.Join(segments, "/")
+}
+
+// trimURL returns the key of a location under base, which is compared by
+// path only so relative and absolute forms of the same URL both match.
+func trimURL(base, location string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	locationURL, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	if baseURL.Host != "" && locationURL.Host != "" && baseURL.Host != locationURL.Host {
+		return "", ErrForeignURL
+	}
+
+	prefix := strings.TrimSuffix(baseURL.Path, "/") + "/"
+	if !strings.HasPrefix(locationURL.Path, prefix) {
+		return "", ErrForeignURL
+	}
+	key := strings.TrimPrefix(locationURL.Path, prefix)
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
@@ -1,6 +1,12 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type DBConfig struct {
 	User     string
@@ -15,11 +21,119 @@ type HTTPConfig struct {
 	Host       string
 	Port       string
 	ExposePort string
+	// RequireIfMatch rejects updates and deletes of versioned records that
+	// don't send If-Match, instead of applying them unconditionally.
+	RequireIfMatch bool
+}
+
+type LibraryConfig struct {
+	LoanPeriodDays     int
+	MaxRenewals        int
+	MaxActiveLoans     int
+	HoldPickupDays     int
+	HoldExpiryInterval time.Duration
+}
+
+// FinesConfig amounts are in cents.
+type FinesConfig struct {
+	PerDay          int64
+	Cap             int64
+	BlockThreshold  int64
+	OverdueInterval time.Duration
+}
+
+type RecommendationsConfig struct {
+	RefreshInterval time.Duration
+}
+
+// TrashConfig controls how long deleted books and categories stay
+// restorable before the purge job removes them for good.
+type TrashConfig struct {
+	RetentionDays int
+	PurgeInterval time.Duration
+}
+
+// StorageConfig selects where uploads are kept: "local" writes them under
+// LocalDir, "s3" uses an AWS S3 bucket and "minio" an S3-compatible server
+// at Endpoint. PublicURL overrides the base of the URLs handed out.
+type StorageConfig struct {
+	Backend   string
+	LocalDir  string
+	PublicURL string
+	Bucket    string
+	Region    string
+	Endpoint  string
+}
+
+// UploadsConfig limits uploaded images to MaxBytes and to MaxWidth by
+// MaxHeight pixels, and sets how they are stored and served.
+type UploadsConfig struct {
+	MaxBytes  int64
+	MaxWidth  int
+	MaxHeight int
+	// Renditions are the sizes every image is also stored scaled down to.
+	Renditions []RenditionConfig
+	// WebP stores renditions as lossless WebP instead of JPEG or PNG.
+	WebP        bool
+	JPEGQuality int
+	// PresignTTL is how long presigned uploads stay valid.
+	PresignTTL time.Duration
+	// SigningSecret signs URLs for stores that can't presign. A random one
+	// is used when empty, which breaks with more than one instance.
+	SigningSecret string
+	// Visibility maps entity types to the "public", "signed" or "proxy"
+	// visibility their uploads get unless they ask for another. Signed URLs
+	// expire after PresignTTL. Signed and proxied images are only shown to
+	// their owner.
+	Visibility map[string]string
+	// Uploads nothing references are deleted after OrphanGrace by a
+	// collector running every GCInterval, which also deletes stored files
+	// no upload accounts for.
+	OrphanGrace time.Duration
+	GCInterval  time.Duration
+
+	// Images imported by URL are only fetched from public addresses,
+	// following ImportMaxRedirects redirects at most, and must arrive
+	// within ImportTimeout.
+	ImportTimeout      time.Duration
+	ImportMaxRedirects int
+}
+
+// RenditionConfig is a named size, MaxSize being the longest side in
+// pixels.
+type RenditionConfig struct {
+	Name    string
+	MaxSize int
+}
+
+// ScannerConfig selects how uploads are checked for malware before they
+// are stored: "none" doesn't check them and "clamav" streams them to the
+// clamd daemon at Address, a host:port or the path of its unix socket.
+// Scans taking longer than Timeout fail. Files over clamd's
+// StreamMaxLength can't be scanned, so it should be at least as large as
+// the largest upload allowed.
+type ScannerConfig struct {
+	Backend string
+	Address string
+	Timeout time.Duration
+}
+
+// EbooksConfig limits e-book files attached to books to MaxBytes.
+type EbooksConfig struct {
+	MaxBytes int64
 }
 
 type Config struct {
-	DB   DBConfig
-	HTTP HTTPConfig
+	DB              DBConfig
+	HTTP            HTTPConfig
+	Library         LibraryConfig
+	Fines           FinesConfig
+	Recommendations RecommendationsConfig
+	Trash           TrashConfig
+	Storage         StorageConfig
+	Uploads         UploadsConfig
+	Ebooks          EbooksConfig
+	Scanner         ScannerConfig
 }
 
 func LoadConfig() *Config {
@@ -33,9 +147,156 @@ func LoadConfig() *Config {
 			Port:     os.Getenv("DB_PORT"),
 		},
 		HTTP: HTTPConfig{
-			Host:       os.Getenv("APP_HOST"),
-			Port:       os.Getenv("APP_PORT"),
-			ExposePort: os.Getenv("EXPOSE_PORT"),
+			Host:           os.Getenv("APP_HOST"),
+			Port:           os.Getenv("APP_PORT"),
+			ExposePort:     os.Getenv("EXPOSE_PORT"),
+			RequireIfMatch: getEnvBool("REQUIRE_IF_MATCH", false),
+		},
+		Library: LibraryConfig{
+			LoanPeriodDays:     getEnvInt("LOAN_PERIOD_DAYS", 14),
+			MaxRenewals:        getEnvInt("LOAN_MAX_RENEWALS", 2),
+			MaxActiveLoans:     getEnvInt("LOAN_MAX_ACTIVE", 5),
+			HoldPickupDays:     getEnvInt("HOLD_PICKUP_DAYS", 3),
+			HoldExpiryInterval: getEnvDuration("HOLD_EXPIRY_INTERVAL", 15*time.Minute),
+		},
+		Fines: FinesConfig{
+			PerDay:          int64(getEnvInt("FINE_PER_DAY_CENTS", 25)),
+			Cap:             int64(getEnvInt("FINE_CAP_CENTS", 1000)),
+			BlockThreshold:  int64(getEnvInt("FINE_BLOCK_THRESHOLD_CENTS", 500)),
+			OverdueInterval: getEnvDuration("OVERDUE_SCAN_INTERVAL", time.Hour),
+		},
+		Recommendations: RecommendationsConfig{
+			RefreshInterval: getEnvDuration("RECOMMENDATIONS_REFRESH_INTERVAL", 6*time.Hour),
+		},
+		Trash: TrashConfig{
+			RetentionDays: getEnvInt("TRASH_RETENTION_DAYS", 30),
+			PurgeInterval: getEnvDuration("TRASH_PURGE_INTERVAL", 24*time.Hour),
+		},
+		Storage: StorageConfig{
+			Backend:   getEnv("STORAGE_BACKEND", "s3"),
+			LocalDir:  getEnv("STORAGE_LOCAL_DIR", "uploads"),
+			PublicURL: os.Getenv("STORAGE_PUBLIC_URL"),
+			Bucket:    getEnv("STORAGE_BUCKET", os.Getenv("BUCKET_NAME")),
+			Region:    os.Getenv("STORAGE_REGION"),
+			Endpoint:  os.Getenv("STORAGE_ENDPOINT"),
+		},
+		Uploads: UploadsConfig{
+			MaxBytes:      int64(getEnvInt("UPLOAD_MAX_BYTES", 5<<20)),
+			MaxWidth:      getEnvInt("UPLOAD_MAX_WIDTH", 6000),
+			MaxHeight:     getEnvInt("UPLOAD_MAX_HEIGHT", 6000),
+			Renditions:    getEnvRenditions("UPLOAD_RENDITIONS", "thumb:160,medium:640"),
+			WebP:          getEnvBool("UPLOAD_WEBP", false),
+			JPEGQuality:   getEnvInt("UPLOAD_JPEG_QUALITY", 85),
+			PresignTTL:    getEnvDuration("UPLOAD_PRESIGN_TTL", 15*time.Minute),
+			SigningSecret: os.Getenv("UPLOAD_SIGNING_SECRET"),
+			Visibility:    getEnvVisibility("UPLOAD_VISIBILITY"),
+			OrphanGrace:   getEnvDuration("UPLOAD_ORPHAN_GRACE", 24*time.Hour),
+			GCInterval:    getEnvDuration("UPLOAD_GC_INTERVAL", 6*time.Hour),
+
+			ImportTimeout:      getEnvDuration("UPLOAD_IMPORT_TIMEOUT", 10*time.Second),
+			ImportMaxRedirects: getEnvInt("UPLOAD_IMPORT_MAX_REDIRECTS", 3),
 		},
+		Ebooks: EbooksConfig{
+			MaxBytes: int64(getEnvInt("EBOOK_MAX_BYTES", 50<<20)),
+		},
+		Scanner: ScannerConfig{
+			Backend: getEnv("SCANNER_BACKEND", "none"),
+			Address: getEnv("CLAMD_ADDRESS", "localhost:3310"),
+			Timeout: getEnvDuration("SCANNER_TIMEOUT", 30*time.Second),
+		},
+	}
+}
+
+// getEnv reads a string variable, falling back when it is unset.
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt reads an integer variable, falling back when it is unset or invalid.
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool reads a boolean variable such as "true" or "1", falling back
+// when it is unset or invalid.
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvDuration reads a duration such as "15m", falling back when it is
+// unset or invalid.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvRenditions reads a list of sizes such as "thumb:160,medium:640",
+// falling back when it is unset or any entry is invalid. "none" turns
+// renditions off.
+func getEnvRenditions(key, fallback string) []RenditionConfig {
+	value := getEnv(key, fallback)
+	if value == "none" {
+		return nil
+	}
+	renditions, err := parseRenditions(value)
+	if err != nil {
+		renditions, _ = parseRenditions(fallback)
+	}
+	return renditions
+}
+
+func parseRenditions(value string) ([]RenditionConfig, error) {
+	var renditions []RenditionConfig
+	for _, entry := range strings.Split(value, ",") {
+		name, size, found := strings.Cut(strings.TrimSpace(entry), ":")
+		maxSize, err := strconv.Atoi(size)
+		// Names end up in storage keys, so they are kept to lower case
+		// letters, digits, dashes and underscores.
+		validName := name != "" && name != "original" && strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-_") == ""
+		if !found || !validName || err != nil || maxSize < 1 {
+			return nil, fmt.Errorf("invalid rendition %q", entry)
+		}
+		renditions = append(renditions, RenditionConfig{Name: name, MaxSize: maxSize})
+	}
+	return renditions, nil
+}
+
+// getEnvVisibility reads default image visibilities per entity type such
+// as "book:public,user:signed". It returns nil, making public the default
+// for every entity type, when the variable is unset or any entry is
+// invalid.
+func getEnvVisibility(key string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	visibility := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entityType, mode, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		switch mode {
+		case "public", "signed", "proxy":
+		default:
+			return nil
+		}
+		if entityType == "" {
+			return nil
+		}
+		visibility[entityType] = mode
 	}
+	return visibility
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/1rhino/clean_architecture/app/models"
 	"github.com/1rhino/clean_architecture/config"
@@ -18,17 +19,49 @@ func Init(cfg *config.Config) *gorm.DB {
 		cfg.DB.Port,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-
-	db.AutoMigrate(
-		&models.User{},
-		&models.Book{},
-		&models.BookCategory{},
-	)
-
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err.Error())
 	}
 
+	if err := migrate(db, time.Duration(cfg.Library.HoldPickupDays)*24*time.Hour); err != nil {
+		panic(err.Error())
+	}
+
 	return db
 }
+
+// migrate repairs data the current schema would reject and then brings the
+// schema up to date. Copies freed by the repairs are held for pickupTTL.
+func migrate(db *gorm.DB, pickupTTL time.Duration) error {
+	if err := repairBookCategories(db); err != nil {
+		return err
+	}
+	if err := repairHolds(db, pickupTTL); err != nil {
+		return err
+	}
+
+	return db.AutoMigrate(
+		&models.User{},
+		&models.Book{},
+		&models.BookCategory{},
+		&models.Review{},
+		&models.ShelfEntry{},
+		&models.ReadingProgress{},
+		&models.ReadingList{},
+		&models.ReadingListItem{},
+		&models.Copy{},
+		&models.Loan{},
+		&models.Hold{},
+		&models.Fine{},
+		&models.FineTransaction{},
+		&models.Favorite{},
+		&models.BookSimilarity{},
+		&models.UserRecommendation{},
+		&models.Revision{},
+		&models.Upload{},
+		&models.UploadReference{},
+		&models.Ebook{},
+		&models.EbookDownload{},
+	)
+}
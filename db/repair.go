@@ -0,0 +1,105 @@
+package db
+
+import (
+	"log"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	holdRepository "github.com/1rhino/clean_architecture/app/modules/holds/repositories"
+	"gorm.io/gorm"
+)
+
+// bookCategoryConstraint is the name gorm gives the foreign key from
+// books.category_id to book_categories.
+const bookCategoryConstraint = "fk_book_categories_books"
+
+// repairBookCategories prepares the books table for the category foreign
+// key before AutoMigrate adds it. Books filed under category 0, which meant
+// no category before the column became nullable, or under a category that
+// no longer exists are moved to no category and reported. A constraint
+// created without ON DELETE SET NULL is dropped so AutoMigrate recreates it.
+func repairBookCategories(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Book{}) || !migrator.HasTable(&models.BookCategory{}) {
+		return nil
+	}
+
+	var orphanIDs []uint
+	err := db.Unscoped().Model(&models.Book{}).
+		Where("category_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM book_categories WHERE book_categories.id = books.category_id)").
+		Order("id").
+		Pluck("id", &orphanIDs).Error
+	if err != nil {
+		return err
+	}
+	if len(orphanIDs) > 0 {
+		log.Printf("moving %d books with a missing category to no category: %v", len(orphanIDs), orphanIDs)
+		columns := map[string]interface{}{"category_id": nil}
+		// Databases from before books were versioned get the column from
+		// AutoMigrate afterwards.
+		if migrator.HasColumn(&models.Book{}, "version") {
+			columns["version"] = gorm.Expr("version + 1")
+		}
+		err := db.Unscoped().Model(&models.Book{}).Where("id IN ?", orphanIDs).UpdateColumns(columns).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var deleteAction string
+	err = db.Raw("SELECT confdeltype::text FROM pg_constraint WHERE conname = ?", bookCategoryConstraint).
+		Scan(&deleteAction).Error
+	if err != nil {
+		return err
+	}
+	// "n" is SET NULL.
+	if deleteAction != "" && deleteAction != "n" {
+		log.Printf("recreating %s with ON DELETE SET NULL", bookCategoryConstraint)
+		return migrator.DropConstraint(&models.BookCategory{}, bookCategoryConstraint)
+	}
+	return nil
+}
+
+// repairHolds cancels duplicate active holds left by concurrent requests
+// before AutoMigrate adds the unique index on them. The oldest waiting or
+// ready hold of each user on each book is kept. A copy set aside for a
+// cancelled ready hold moves on to the next hold in the queue.
+func repairHolds(db *gorm.DB, pickupTTL time.Duration) error {
+	if !db.Migrator().HasTable(&models.Hold{}) {
+		return nil
+	}
+
+	active := []string{models.HoldStatusWaiting, models.HoldStatusReady}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var duplicates []*models.Hold
+		err := tx.Unscoped().
+			Where("status IN ?", active).
+			Where("EXISTS (SELECT 1 FROM holds AS other WHERE other.book_id = holds.book_id AND other.user_id = holds.user_id AND other.status IN ? AND (other.created_at, other.id) < (holds.created_at, holds.id))", active).
+			Order("id").
+			Find(&duplicates).Error
+		if err != nil || len(duplicates) == 0 {
+			return err
+		}
+
+		ids := make([]uint, 0, len(duplicates))
+		for _, hold := range duplicates {
+			ids = append(ids, hold.ID)
+		}
+		err = tx.Unscoped().Model(&models.Hold{}).Where("id IN ?", ids).
+			Update("status", models.HoldStatusCancelled).Error
+		if err != nil {
+			return err
+		}
+		log.Printf("cancelled %d duplicate holds", len(duplicates))
+
+		now := time.Now()
+		for _, hold := range duplicates {
+			if hold.Status == models.HoldStatusReady && hold.CopyID != nil {
+				if err := holdRepository.ReleaseCopy(tx, *hold.CopyID, hold.BookID, now, pickupTTL); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/1rhino/clean_architecture/app/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the database named by TEST_DATABASE_DSN, a
+// key=value connection string, inside a schema of its own that is dropped
+// when the test ends.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("repair_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// baselineSchema is the books and categories schema from before category_id
+// became a nullable foreign key and books were versioned.
+const baselineSchema = `
+CREATE TABLE users (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	name varchar(255),
+	email varchar(255),
+	password varchar(255),
+	image varchar(255)
+);
+CREATE TABLE book_categories (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	name varchar(255),
+	image varchar(255),
+	description varchar(255),
+	user_id bigint
+);
+CREATE TABLE books (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	name varchar(255),
+	image varchar(255),
+	author varchar(255),
+	public_date timestamptz,
+	description varchar(255),
+	category_id bigint,
+	user_id bigint
+);
+INSERT INTO users (id, name, email) VALUES (1, 'Reader', 'reader@example.com');
+INSERT INTO book_categories (id, name, user_id) VALUES (1, 'Fiction', 1);
+INSERT INTO books (id, name, category_id, user_id) VALUES
+	(1, 'Filed', 1, 1),
+	(2, 'No category', 0, 1),
+	(3, 'Missing category', 42, 1);
+`
+
+func TestMigrateBaselineSchema(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Exec(baselineSchema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(db, 72*time.Hour); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var books []models.Book
+	if err := db.Order("id").Find(&books).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 3 {
+		t.Fatalf("got %d books, want 3", len(books))
+	}
+	if books[0].CategoryID == nil || *books[0].CategoryID != 1 {
+		t.Errorf("book 1 category = %v, want 1", books[0].CategoryID)
+	}
+	for _, book := range books[1:] {
+		if book.CategoryID != nil {
+			t.Errorf("book %d category = %d, want none", book.ID, *book.CategoryID)
+		}
+	}
+
+	// Migrating again finds nothing to repair.
+	if err := migrate(db, 72*time.Hour); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+}
+
+// duplicateHolds is the holds schema from before the unique index on active
+// holds, with users 1 and 2 each holding book 1 twice.
+const duplicateHolds = `
+CREATE TABLE copies (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	book_id bigint,
+	barcode varchar(64),
+	condition varchar(50),
+	location varchar(255),
+	status varchar(20) NOT NULL DEFAULT 'available'
+);
+CREATE TABLE holds (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	book_id bigint,
+	user_id bigint,
+	status varchar(20) NOT NULL,
+	copy_id bigint,
+	ready_at timestamptz,
+	expires_at timestamptz
+);
+INSERT INTO copies (id, book_id, barcode, status) VALUES
+	(10, 1, 'B10', 'on_hold'),
+	(11, 1, 'B11', 'on_hold'),
+	(12, 1, 'B12', 'on_hold');
+INSERT INTO holds (id, created_at, book_id, user_id, status, copy_id) VALUES
+	(1, '2024-01-01', 1, 1, 'waiting', NULL),
+	(2, '2024-01-02', 1, 1, 'ready', 10),
+	(3, '2024-01-01', 1, 2, 'ready', 11),
+	(4, '2024-01-03', 1, 2, 'ready', 12),
+	(5, '2024-01-02', 1, 3, 'waiting', NULL);
+`
+
+func TestRepairHolds(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Exec(duplicateHolds).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repairHolds(db, 72*time.Hour); err != nil {
+		t.Fatalf("repairHolds: %v", err)
+	}
+
+	// The newer holds of users 1 and 2 are cancelled, whether waiting or
+	// ready, and the copies they held go to the oldest waiting holds.
+	want := map[uint]struct {
+		status string
+		copyID uint
+	}{
+		1: {models.HoldStatusReady, 10},
+		2: {models.HoldStatusCancelled, 10},
+		3: {models.HoldStatusReady, 11},
+		4: {models.HoldStatusCancelled, 12},
+		5: {models.HoldStatusReady, 12},
+	}
+	var holds []models.Hold
+	if err := db.Order("id").Find(&holds).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, hold := range holds {
+		if hold.Status != want[hold.ID].status || hold.CopyID == nil || *hold.CopyID != want[hold.ID].copyID {
+			t.Errorf("hold %d is %s with copy %v, want %s with copy %d", hold.ID, hold.Status, hold.CopyID, want[hold.ID].status, want[hold.ID].copyID)
+		}
+	}
+
+	// Repairing again finds nothing to cancel.
+	if err := repairHolds(db, 72*time.Hour); err != nil {
+		t.Fatalf("second repairHolds: %v", err)
+	}
+}